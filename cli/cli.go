@@ -0,0 +1,314 @@
+// Package cli implements the command-line subcommands of the text expander.
+// Running the executable without arguments starts the tray application
+// instead.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"text-expander/config"
+	"text-expander/expander"
+	"text-expander/interop"
+)
+
+// command is a single subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{"simulate", "replay a recorded key session and print the resulting text", runSimulate},
+	{"record", "record keyboard events for later replay", runRecord},
+	{"convert", "convert a configuration between JSON, YAML and TOML", runConvert},
+	{"import", "import snippets from espanso, AutoHotkey, Beeftext, TextExpander or Alfred", runImport},
+	{"export", "export expansions to CSV, espanso or AutoHotkey", runExport},
+}
+
+// errUsage signals that the command line was invalid and usage was printed.
+var errUsage = errors.New("invalid usage")
+
+// Run executes the subcommand named by args[0] and returns the process exit
+// code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		if err := c.run(args[1:], stdout, stderr); err != nil {
+			if err == errUsage || err == flag.ErrHelp {
+				return 2
+			}
+			fmt.Fprintf(stderr, "%s: %v\n", c.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: TextExpander [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command the tray application starts. Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+}
+
+func defaultConfigPath() string {
+	return config.DefaultPath("config")
+}
+
+func runSimulate(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfgPath := fs.String("config", defaultConfigPath(), "configuration file")
+	realtime := fs.Bool("realtime", false, "replay with the recorded timing")
+	caret := fs.Bool("caret", false, "mark the caret position with |")
+	out := fs.String("o", "", "write the resulting text to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: TextExpander simulate [flags] <recording.jsonl | ->")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	var in io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	events, err := expander.ReadRecording(in)
+	if err != nil {
+		return fmt.Errorf("read recording: %w", err)
+	}
+
+	doc := expander.Simulate(cfg, events, expander.SimulateOptions{Realtime: *realtime})
+	text := doc.Text()
+	if *caret {
+		text = doc.TextWithCaret("|")
+	}
+	if *out != "" {
+		return os.WriteFile(*out, []byte(text+"\n"), 0o644)
+	}
+	fmt.Fprintln(stdout, text)
+	return nil
+}
+
+func runRecord(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("record", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	out := fs.String("o", "", "write the recording to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: TextExpander record [-o file]")
+		fmt.Fprintln(stderr, "Records every key press until interrupted with Ctrl+C. Recordings")
+		fmt.Fprintln(stderr, "contain everything typed, including passwords; handle them with care.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	w := stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	kb := expander.NewKeyboardHook()
+	kb.SetOnKeyPress(func(string) {})
+	kb.SetRecorder(expander.NewEventRecorder(w))
+	if err := kb.Start(); err != nil {
+		return err
+	}
+	defer kb.Stop()
+
+	fmt.Fprintln(stderr, "Recording; press Ctrl+C to stop.")
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
+	return nil
+}
+
+func runConvert(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	force := fs.Bool("f", false, "overwrite the output file if it exists")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: TextExpander convert [-f] <input> <output>")
+		fmt.Fprintln(stderr, "The formats are chosen by extension: .json, .yaml, .yml or .toml.")
+		fmt.Fprintln(stderr, "Expansions keep their order.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+
+	if _, err := config.FormatForPath(out); err != nil {
+		return err
+	}
+	if _, err := os.Stat(out); err == nil && !*force {
+		return fmt.Errorf("%s already exists; use -f to overwrite it", out)
+	}
+
+	cfg, err := config.ReadConfig(in)
+	if err != nil {
+		return err
+	}
+	if err := cfg.SaveTo(out); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote %d expansions to %s\n", len(cfg.GetExpansions()), out)
+	return nil
+}
+
+func runImport(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfgPath := fs.String("config", defaultConfigPath(), "configuration file to add the snippets to")
+	format := fs.String("format", "", "format of the files; detected from their extension when empty")
+	category := fs.String("category", "", "category for imported snippets that have none")
+	dryRun := fs.Bool("n", false, "report what would be imported without saving")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: TextExpander import [flags] <file>...")
+		fmt.Fprintln(stderr, "Formats:")
+		for _, f := range interop.Formats {
+			fmt.Fprintf(stderr, "  %-12s %s\n", f.Name, f.Description)
+		}
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	var report interop.Report
+	for _, path := range fs.Args() {
+		exps, err := interop.ImportFile(path, *format, &report)
+		if err != nil {
+			return err
+		}
+		for i := range exps {
+			if exps[i].Category == "" {
+				exps[i].Category = *category
+			}
+		}
+		interop.AddToConfig(cfg, exps, &report)
+	}
+
+	report.Write(stdout)
+	if *dryRun || report.Imported == 0 {
+		return nil
+	}
+	return cfg.Save()
+}
+
+func runExport(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfgPath := fs.String("config", defaultConfigPath(), "configuration file")
+	format := fs.String("format", "", "output format; chosen by the output's extension when empty")
+	categories := fs.String("category", "", "comma-separated categories to export; all when empty")
+	force := fs.Bool("f", false, "overwrite the output file if it exists")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: TextExpander export [flags] <output | ->")
+		fmt.Fprintln(stderr, "Writing to - prints the export and sends the report to stderr. Formats:")
+		for _, e := range interop.Exporters {
+			fmt.Fprintf(stderr, "  %-12s %s\n", e.Name, e.Description)
+		}
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	out := fs.Arg(0)
+
+	var filter []string
+	if *categories != "" {
+		filter = strings.Split(*categories, ",")
+	}
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	var report interop.Report
+	if out == "-" {
+		if *format == "" {
+			return fmt.Errorf("-format is required when writing to stdout")
+		}
+		e, err := interop.ExporterByName(*format)
+		if err != nil {
+			return err
+		}
+		if err := interop.Export(stdout, cfg, e, filter, &report); err != nil {
+			return err
+		}
+		report.Write(stderr)
+		return nil
+	}
+
+	if _, err := os.Stat(out); err == nil && !*force {
+		return fmt.Errorf("%s already exists; use -f to overwrite it", out)
+	}
+	if err := interop.ExportFile(cfg, out, *format, filter, &report); err != nil {
+		return err
+	}
+	report.Write(stdout)
+	return nil
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"text-expander/config"
+)
+
+func TestSimulatePrintsDocument(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "expansions.json")
+	recPath := filepath.Join(dir, "session.jsonl")
+
+	cfg := `{
+  "expansions": [{"trigger": ";hi", "replacement": "Hello{CURSOR}!"}],
+  "settings": {"enabled": true, "trigger_on_space": true}
+}`
+	rec := `{"t": 0, "key": ";"}
+{"t": 10, "key": "h"}
+{"t": 20, "key": "i"}
+{"t": 30, "key": "SPACE"}
+`
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(recPath, []byte(rec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"simulate", "-config", cfgPath, "-caret", recPath}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	if got := stdout.String(); got != "Hello|!\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestSimulateWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "expansions.json")
+	recPath := filepath.Join(dir, "session.jsonl")
+	outPath := filepath.Join(dir, "out.txt")
+
+	if err := os.WriteFile(cfgPath, []byte(`{"settings": {"enabled": true}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(recPath, []byte(`{"t": 0, "key": "a"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"simulate", "-config", cfgPath, "-o", outPath, recPath}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected nothing on stdout, got %q", stdout.String())
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a\n" {
+		t.Fatalf("unexpected file contents %q", data)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"bogus"}, &stdout, &stderr); code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+}
+
+func TestConvertKeepsExpansionOrder(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "expansions.json")
+	yml := filepath.Join(dir, "expansions.yaml")
+	out := filepath.Join(dir, "roundtrip.toml")
+
+	cfg := `{"expansions": [
+  {"trigger": ";z", "replacement": "line one\nline two"},
+  {"trigger": ";a", "replacement": "A"},
+  {"trigger": ";m", "replacement": "M"}
+]}`
+	if err := os.WriteFile(in, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range [][2]string{{in, yml}, {yml, out}} {
+		var stdout, stderr bytes.Buffer
+		if code := Run([]string{"convert", step[0], step[1]}, &stdout, &stderr); code != 0 {
+			t.Fatalf("convert %s: exit code %d, stderr: %s", step[0], code, stderr.String())
+		}
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	z, a, m := strings.Index(text, `";z"`), strings.Index(text, `";a"`), strings.Index(text, `";m"`)
+	if z < 0 || !(z < a && a < m) {
+		t.Fatalf("expansions out of order:\n%s", text)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"convert", in, out}, &stdout, &stderr); code == 0 {
+		t.Fatalf("expected convert to refuse overwriting %s", out)
+	}
+}
+
+func TestImportAddsSnippetsToConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "expansions.json")
+	if err := os.WriteFile(cfgPath, []byte(`{"expansions": [{"trigger": "btw", "replacement": "mine"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ahk := filepath.Join(dir, "hotstrings.ahk")
+	if err := os.WriteFile(ahk, []byte("::btw::by the way\n:*:omw::on my way\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"import", "-config", cfgPath, "-category", "AHK", ahk}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "Imported 1 expansions.") || !strings.Contains(out, "already exists: btw") {
+		t.Errorf("unexpected report:\n%s", out)
+	}
+
+	cfg, err := config.ReadConfig(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exps := cfg.GetExpansions()
+	if len(exps) != 2 || exps[0].Replacement != "mine" || exps[1].Trigger != "omw" || exps[1].Category != "AHK" {
+		t.Errorf("unexpected expansions after import: %+v", exps)
+	}
+}
+
+func TestExportFiltersByCategory(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "expansions.json")
+	cfg := `{"expansions": [
+  {"trigger": ";py", "replacement": "print({CLIPBOARD})", "category": "Python", "case_sensitive": true},
+  {"trigger": ";go", "replacement": "fmt.Println()", "category": "Go", "case_sensitive": true}
+]}`
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "python.yml")
+
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"export", "-config", cfgPath, "-category", "python", out}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Exported 1 expansions.") {
+		t.Errorf("unexpected report:\n%s", stdout.String())
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	if !strings.Contains(text, "print({{clipboard}})") || strings.Contains(text, ";go") {
+		t.Errorf("unexpected export:\n%s", text)
+	}
+
+	stdout.Reset()
+	if code := Run([]string{"export", "-config", cfgPath, out}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected overwriting without -f to fail, got exit code %d", code)
+	}
+}
@@ -1,6 +1,10 @@
 package config
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,19 +12,73 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
-
-	"github.com/fsnotify/fsnotify"
 )
 
 // Expansion defines a single trigger/replacement pair as stored in the config file.
 type Expansion struct {
+	// ID identifies the expansion for good, whatever its trigger becomes.
+	// Expansions without one are given one when the file is loaded.
+	ID            string `json:"id,omitempty"`
 	Trigger       string `json:"trigger"`
 	Replacement   string `json:"replacement"`
 	CaseSensitive bool   `json:"case_sensitive"`
 	Description   string `json:"description"`
-	Category      string `json:"category,omitempty"` // NEW: Category for filtering/organization
+	Category      string `json:"category,omitempty"` // groups expansions for filtering
+	OutputFormat  string `json:"output_format,omitempty"`
+	InjectMode    string `json:"inject_mode,omitempty"`
+	Hotkey        string `json:"hotkey,omitempty"` // inserts the replacement at the caret
+	Pack          string `json:"-"`                // name of the pack it comes from, if any
+}
+
+// Name identifies the expansion: its trigger, or its hotkey when it has no
+// trigger.
+func (e Expansion) Name() string {
+	if e.Trigger != "" {
+		return e.Trigger
+	}
+	return e.Hotkey
+}
+
+// Supported values for Expansion.OutputFormat. An empty format is treated as
+// plain text.
+const (
+	OutputFormatText     = "text"
+	OutputFormatMarkdown = "markdown"
+	OutputFormatHTML     = "html"
+)
+
+// Supported values for Expansion.InjectMode. An empty mode types short
+// replacements and pastes those longer than Settings.PasteThreshold.
+const (
+	InjectModeType  = "type"
+	InjectModePaste = "paste"
+)
+
+// InjectionProfile tunes how expansions are delivered to a particular
+// application. A profile applies when the active window's process name or
+// title matches; the first matching profile wins.
+type InjectionProfile struct {
+	Name        string `json:"name"`
+	Process     string `json:"process,omitempty"`      // process name, e.g. "WindowsTerminal.exe"
+	WindowTitle string `json:"window_title,omitempty"` // case-insensitive title substring
+
+	TypingDelay   int    `json:"typing_delay_ms,omitempty"` // per-keystroke delay; 0 keeps the default
+	InjectMode    string `json:"inject_mode,omitempty"`     // "type" or "paste"; see InjectMode constants
+	PasteShortcut string `json:"paste_shortcut,omitempty"`  // e.g. "ctrl+shift+v"
+	DeleteMode    string `json:"delete_mode,omitempty"`     // "backspace" (default) or "select"
+	NewlineKey    string `json:"newline_key,omitempty"`     // "enter" (default) or "shift+enter"
+
+	// AutoIndentCompensation skips indentation the target editor inserts on
+	// its own after each typed newline.
+	AutoIndentCompensation bool `json:"auto_indent_compensation,omitempty"`
 }
 
+// Supported values for InjectionProfile.DeleteMode.
+const (
+	DeleteModeBackspace = "backspace"
+	DeleteModeSelect    = "select"
+)
+
 // Settings contains global behaviour flags.
 type Settings struct {
 	Enabled           bool `json:"enabled"`
@@ -29,38 +87,101 @@ type Settings struct {
 	TriggerOnEnter    bool `json:"trigger_on_enter"`
 	ShowNotifications bool `json:"show_notifications"`
 	LogExpansions     bool `json:"log_expansions"`
+	// KeepTerminator types the space, tab or newline that ended a trigger
+	// again after the replacement instead of removing it with the trigger.
+	KeepTerminator bool `json:"keep_terminator,omitempty"`
+	// PasteThreshold is the replacement length, in characters, above which
+	// expansions without an explicit inject mode are pasted instead of
+	// typed. Zero disables automatic pasting.
+	PasteThreshold int `json:"paste_threshold"`
+	// MaxOutputSize is the longest replacement, in characters, inserted
+	// without asking. Zero disables the limit.
+	MaxOutputSize int `json:"max_output_size"`
+	// ConfirmLargeOutput asks before inserting replacements longer than
+	// MaxOutputSize instead of refusing them outright.
+	ConfirmLargeOutput bool `json:"confirm_large_output"`
+	// Hotkeys are global shortcuts for controlling the expander.
+	Hotkeys Hotkeys `json:"hotkeys"`
+	// Suggestions lists matching triggers while one is being typed.
+	Suggestions Suggestions `json:"suggestions"`
+	// HistoryLimit is the number of snapshots of earlier versions of the
+	// configuration file kept in the history directory. Zero keeps none.
+	HistoryLimit int `json:"history_limit"`
+	// OutputBackend selects how keystrokes are sent: "robotgo" (default) or
+	// "uinput" for Linux sessions, such as Wayland, where robotgo cannot type.
+	OutputBackend string `json:"output_backend,omitempty"`
+	// InputBackend selects how key presses are read: "hook" (default) or
+	// "evdev" for Linux sessions, such as Wayland, where the hook sees
+	// nothing.
+	InputBackend string `json:"input_backend,omitempty"`
+	// KeyboardLayout is the layout evdev input is translated with: "us"
+	// (default) or "us-intl".
+	KeyboardLayout string `json:"keyboard_layout,omitempty"`
 }
 
 // Config is the root configuration object for the application.
 type Config struct {
-	Expansions      []Expansion       `json:"expansions"`
-	CustomVariables map[string]string `json:"custom_variables"`
-	Settings        Settings          `json:"settings"`
+	Expansions      []Expansion        `json:"expansions"`
+	CustomVariables map[string]string  `json:"custom_variables"`
+	Settings        Settings           `json:"settings"`
+	Profiles        []InjectionProfile `json:"profiles,omitempty"`
+	// PackStates records the packs switched on or off in the settings, by
+	// file name, so the pack files themselves are never rewritten. Packs
+	// not listed keep the state their file gives them.
+	PackStates map[string]bool `json:"pack_states,omitempty"`
 
 	filePath string
+	// unloaded marks a configuration made by NewUnloadedConfig that has not
+	// been loaded from its file yet.
+	unloaded bool
+	// packs are the snippet packs loaded from the packs directory, and
+	// packErrors the pack files that could not be read.
+	packs      []Pack
+	packErrors []error
+	// warnings are the validation problems found on load that did not
+	// stop the configuration from loading.
+	warnings []Problem
 	mu       sync.RWMutex
+
+	// fileHash is the hash of the file contents the configuration was last
+	// loaded from or saved as, which Watch uses to tell its own saves from
+	// changes made elsewhere. It has its own lock because Save writes it
+	// while holding mu for reading only.
+	hashMu   sync.Mutex
+	fileHash [sha256.Size]byte
 }
 
 // LoadConfig loads configuration from the given path. If the file does not
-// exist, it is created with a default configuration. If the file is corrupted,
-// it is backed up and replaced with defaults.
+// exist, it is created with a default configuration. A file that cannot be
+// parsed is reported as a *ParseError with the line and column of the
+// problem, and one whose fields are invalid as a *ValidationError; the file
+// is left untouched in both cases so the user can fix it. With a
+// *ValidationError the configuration is returned as well, so an editor can
+// open it to repair it, but it must not be used to expand. Files from an
+// older schema version are migrated, and the original is kept next to the
+// file as <name>.v<version>.bak before the migrated configuration is saved.
+// Expansions without an ID are given one, and the file is saved to keep it.
 func LoadConfig(path string) (*Config, error) {
 	if path == "" {
 		return nil, errors.New("config path is required")
 	}
 
+	format, err := FormatForPath(path)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, fmt.Errorf("creating config dir: %w", err)
 	}
 
-	cfg := &Config{}
-
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Create default config.
-			cfg = defaultConfig()
+			cfg := defaultConfig()
 			cfg.filePath = path
+			cfg.loadPacks()
 			if err := cfg.Save(); err != nil {
 				return cfg, fmt.Errorf("saving default config: %w", err)
 			}
@@ -69,24 +190,127 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("reading config: %w", err)
 	}
 
+	var (
+		cfg  *Config
+		from = CurrentSchemaVersion
+	)
 	if len(data) == 0 {
 		cfg = defaultConfig()
-	} else if err := json.Unmarshal(data, cfg); err != nil {
-		// Backup corrupted file and fall back to defaults.
-		backup := path + ".bak"
-		_ = os.WriteFile(backup, data, 0o600)
-		cfg = defaultConfig()
+	} else if cfg, from, err = decodeConfig(path, data, format); err != nil {
+		var ve *ValidationError
+		if cfg == nil || !errors.As(err, &ve) {
+			return nil, err
+		}
 	}
 
-	if cfg.CustomVariables == nil {
-		cfg.CustomVariables = make(map[string]string)
+	cfg.filePath = path
+	cfg.setFileHash(sha256.Sum256(data))
+	cfg.loadPacks()
+	assigned := cfg.assignIDs()
+	if err != nil {
+		// Opened for repair: nothing is saved until the user saves.
+		return cfg, err
+	}
+
+	if from < CurrentSchemaVersion {
+		// Keep the file as it was before rewriting it in the new schema.
+		backup := fmt.Sprintf("%s.v%d.bak", path, from)
+		if err := os.WriteFile(backup, data, 0o600); err != nil {
+			return nil, fmt.Errorf("backing up config before migration: %w", err)
+		}
+		if err := cfg.Save(); err != nil {
+			return cfg, fmt.Errorf("saving migrated config: %w", err)
+		}
+	} else if assigned > 0 {
+		// Keep the new IDs, so they stay the same from one run to the next.
+		if err := cfg.Save(); err != nil {
+			return cfg, fmt.Errorf("saving expansion IDs: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// NewUnloadedConfig returns an empty configuration for the file at path, with
+// expansion turned off, to run with while the file cannot be loaded. Reload
+// fills it in once the file is fixed; until then it is never saved, so the
+// broken file is left for the user to repair.
+func NewUnloadedConfig(path string) *Config {
+	return &Config{
+		CustomVariables: make(map[string]string),
+		filePath:        path,
+		unloaded:        true,
+	}
+}
+
+// Loaded reports whether the configuration holds the contents of its file,
+// which is false for one from NewUnloadedConfig until it is reloaded.
+func (c *Config) Loaded() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.unloaded
+}
+
+// ReadConfig loads the configuration at path like LoadConfig, but reports a
+// missing or empty file instead of falling back to the defaults, and
+// migrates older files and assigns missing expansion IDs in memory only.
+func ReadConfig(path string) (*Config, error) {
+	format, err := FormatForPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
 	}
 
+	cfg, _, err := decodeConfig(path, data, format)
+	if err != nil {
+		return nil, err
+	}
 	cfg.filePath = path
+	cfg.loadPacks()
+	cfg.assignIDs()
 	return cfg, nil
 }
 
-// Save writes the configuration to disk atomically.
+// decodeConfig parses, migrates and validates the contents of the file at
+// path, returning the schema version the file had. A configuration that
+// fails validation is returned along with its *ValidationError.
+func decodeConfig(path string, data []byte, format string) (*Config, int, error) {
+	doc, err := decodeDocument(data, format)
+	if err != nil {
+		return nil, 0, newParseError(path, data, err)
+	}
+	from, err := migrate(doc)
+	if err != nil {
+		return nil, from, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg := &Config{}
+	if err := fromDocument(doc, cfg); err != nil {
+		// The error's offset is into the re-encoded document; decoding a
+		// JSON file itself again locates the mistyped field in the file.
+		if format == FormatJSON {
+			if jerr := json.Unmarshal(data, &Config{}); jerr != nil {
+				return nil, from, newParseError(path, data, jerr)
+			}
+		}
+		return nil, from, newParseError(path, nil, err)
+	}
+	if cfg.CustomVariables == nil {
+		cfg.CustomVariables = make(map[string]string)
+	}
+	if err := cfg.check(path); err != nil {
+		return cfg, from, err
+	}
+	return cfg, from, nil
+}
+
+// Save writes the configuration to disk atomically, in the format selected
+// by the file's extension. The whole file is rewritten, so comments and
+// formatting in a YAML or TOML file are lost. The file being replaced is
+// kept as a snapshot first (see Snapshots); failing to keep it is reported
+// after saving with an error wrapping ErrSnapshot. Saving what the file
+// already holds leaves it, and the history, alone.
 func (c *Config) Save() error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -94,63 +318,244 @@ func (c *Config) Save() error {
 	if c.filePath == "" {
 		return errors.New("config file path is not set")
 	}
+	if c.unloaded {
+		return fmt.Errorf("%s has not been loaded; fix it before saving", c.filePath)
+	}
+	data, err := c.encodeLocked(c.filePath)
+	if err != nil {
+		return err
+	}
+	if cur, err := os.ReadFile(c.filePath); err == nil && bytes.Equal(cur, data) {
+		c.setFileHash(sha256.Sum256(data))
+		return nil
+	}
+	snapErr := c.snapshotLocked()
+	if err := c.writeDataLocked(c.filePath, data); err != nil {
+		return err
+	}
+	if snapErr != nil {
+		return fmt.Errorf("config saved, but %w: %v", ErrSnapshot, snapErr)
+	}
+	return nil
+}
+
+// SaveTo writes the configuration to path, in the format selected by its
+// extension. The configuration keeps its own path.
+func (c *Config) SaveTo(path string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.writeLocked(path)
+}
+
+// writeLocked encodes the configuration and writes it to path. c.mu must be
+// held by the caller.
+func (c *Config) writeLocked(path string) error {
+	data, err := c.encodeLocked(path)
+	if err != nil {
+		return err
+	}
+	return c.writeDataLocked(path, data)
+}
 
-	tmpPath := c.filePath + ".tmp"
+// encodeLocked encodes the configuration in the format selected by the
+// extension of path. c.mu must be held by the caller.
+func (c *Config) encodeLocked(path string) ([]byte, error) {
+	format, err := FormatForPath(path)
+	if err != nil {
+		return nil, err
+	}
 
 	out := struct {
-		Expansions      []Expansion       `json:"expansions"`
-		CustomVariables map[string]string `json:"custom_variables"`
-		Settings        Settings          `json:"settings"`
+		SchemaVersion   int                `json:"schema_version"`
+		Expansions      []Expansion        `json:"expansions"`
+		CustomVariables map[string]string  `json:"custom_variables"`
+		Settings        Settings           `json:"settings"`
+		Profiles        []InjectionProfile `json:"profiles,omitempty"`
+		PackStates      map[string]bool    `json:"pack_states,omitempty"`
 	}{
+		SchemaVersion:   CurrentSchemaVersion,
 		Expansions:      c.Expansions,
 		CustomVariables: c.CustomVariables,
 		Settings:        c.Settings,
+		Profiles:        c.Profiles,
+		PackStates:      c.PackStates,
 	}
 
-	data, err := json.MarshalIndent(out, "", "  ")
+	data, err := marshal(out, format)
 	if err != nil {
-		return fmt.Errorf("marshal config: %w", err)
+		return nil, fmt.Errorf("marshal config: %w", err)
 	}
+	return data, nil
+}
 
+// writeDataLocked writes data to path atomically. c.mu must be held by the
+// caller.
+func (c *Config) writeDataLocked(path string, data []byte) error {
+	tmpPath := path + ".tmp"
 	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
 		return fmt.Errorf("write temp config: %w", err)
 	}
 
-	if err := os.Rename(tmpPath, c.filePath); err != nil {
+	if err := os.Rename(tmpPath, path); err != nil {
 		return fmt.Errorf("rename temp config: %w", err)
 	}
 
+	if path == c.filePath {
+		c.setFileHash(sha256.Sum256(data))
+	}
 	return nil
 }
 
-// AddExpansion adds a new expansion to the configuration, ensuring that the
-// trigger is not empty and not duplicated.
+// AddExpansion adds a new expansion to the configuration, ensuring that it
+// has a trigger or hotkey and that neither is already in use. An expansion
+// without an ID is given one.
 func (c *Config) AddExpansion(exp Expansion) error {
-	if strings.TrimSpace(exp.Trigger) == "" {
-		return errors.New("trigger cannot be empty")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if exp.ID == "" {
+		exp.ID = NewExpansionID()
 	}
+	if err := c.checkExpansionLocked(exp, -1); err != nil {
+		return err
+	}
+	c.Expansions = append(c.Expansions, exp)
+	return nil
+}
 
+// UpdateExpansion replaces the expansion with the given ID by exp, which
+// keeps the ID and its place in the list. exp is checked like a new
+// expansion first, and nothing changes if it is rejected.
+func (c *Config) UpdateExpansion(id string, exp Expansion) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for _, existing := range c.Expansions {
-		if existing.Trigger == exp.Trigger {
-			return fmt.Errorf("expansion with trigger %q already exists", exp.Trigger)
+	i := c.indexLocked(id)
+	if i < 0 {
+		return fmt.Errorf("expansion %s not found", id)
+	}
+	exp.ID = id
+	if err := c.checkExpansionLocked(exp, i); err != nil {
+		return err
+	}
+	c.Expansions[i] = exp
+	return nil
+}
+
+// ExpansionByID returns the user's expansion with the given ID.
+func (c *Config) ExpansionByID(id string) (Expansion, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if i := c.indexLocked(id); i >= 0 {
+		return c.Expansions[i], true
+	}
+	return Expansion{}, false
+}
+
+// RemoveExpansionByID removes the expansion with the given ID.
+func (c *Config) RemoveExpansionByID(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := c.indexLocked(id)
+	if i < 0 {
+		return fmt.Errorf("expansion %s not found", id)
+	}
+	c.Expansions = append(c.Expansions[:i], c.Expansions[i+1:]...)
+	return nil
+}
+
+// indexLocked returns the index of the expansion with the given ID, or -1.
+// c.mu must be held by the caller.
+func (c *Config) indexLocked(id string) int {
+	for i, exp := range c.Expansions {
+		if exp.ID == id {
+			return i
 		}
 	}
+	return -1
+}
+
+// NewExpansionID returns a new random expansion ID.
+func NewExpansionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
 
-	c.Expansions = append(c.Expansions, exp)
+// assignIDs gives a new ID to every expansion without one, and to all but
+// the first of expansions sharing one, as when an entry was copied by hand.
+// It returns how many it assigned.
+func (c *Config) assignIDs() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	seen := make(map[string]bool, len(c.Expansions))
+	for i := range c.Expansions {
+		if id := c.Expansions[i].ID; id == "" || seen[id] {
+			c.Expansions[i].ID = NewExpansionID()
+			n++
+		}
+		seen[c.Expansions[i].ID] = true
+	}
+	return n
+}
+
+// checkExpansionLocked reports why exp cannot be stored among the user's
+// expansions, ignoring the one at index skip, which it is to replace.
+// c.mu must be held by the caller.
+func (c *Config) checkExpansionLocked(exp Expansion, skip int) error {
+	if strings.TrimSpace(exp.Trigger) == "" && strings.TrimSpace(exp.Hotkey) == "" {
+		return errors.New("trigger and hotkey cannot both be empty")
+	}
+
+	var hotkey string
+	if exp.Hotkey != "" {
+		hk, err := ParseHotkey(exp.Hotkey)
+		if err != nil {
+			return err
+		}
+		hotkey = hk.String()
+	}
+
+	for action, binding := range c.Settings.Hotkeys.Bindings() {
+		if hk, err := ParseHotkey(binding); err == nil && hk.String() == hotkey {
+			return fmt.Errorf("hotkey %q is already used for %s", exp.Hotkey, action)
+		}
+	}
+	for i, existing := range c.Expansions {
+		if i == skip {
+			continue
+		}
+		if exp.ID != "" && existing.ID == exp.ID {
+			return fmt.Errorf("expansion ID %s is already used by %q", exp.ID, existing.Name())
+		}
+		if exp.Trigger != "" && existing.Trigger == exp.Trigger {
+			return fmt.Errorf("expansion with trigger %q already exists", exp.Trigger)
+		}
+		if hotkey == "" || existing.Hotkey == "" {
+			continue
+		}
+		if hk, err := ParseHotkey(existing.Hotkey); err == nil && hk.String() == hotkey {
+			return fmt.Errorf("hotkey %q is already used by %q", exp.Hotkey, existing.Name())
+		}
+	}
 	return nil
 }
 
-// RemoveExpansion removes an expansion by its trigger.
+// RemoveExpansion removes an expansion by its name (see Expansion.Name).
 func (c *Config) RemoveExpansion(trigger string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	index := -1
 	for i, exp := range c.Expansions {
-		if exp.Trigger == trigger {
+		if exp.Name() == trigger {
 			index = i
 			break
 		}
@@ -163,54 +568,6 @@ func (c *Config) RemoveExpansion(trigger string) error {
 	return nil
 }
 
-// Watch sets up a file watcher on the configuration file and calls the
-// callback whenever the file is modified or recreated. The callback is called
-// from a background goroutine.
-func (c *Config) Watch(callback func()) error {
-	c.mu.RLock()
-	path := c.filePath
-	c.mu.RUnlock()
-
-	if path == "" {
-		return errors.New("config file path is not set")
-	}
-
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return fmt.Errorf("create watcher: %w", err)
-	}
-
-	if err := watcher.Add(path); err != nil {
-		_ = watcher.Close()
-		return fmt.Errorf("watch config: %w", err)
-	}
-
-	go func() {
-		defer watcher.Close()
-
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
-					if callback != nil {
-						callback()
-					}
-				}
-			case _, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				// Errors are ignored here; caller can provide separate logging if desired.
-			}
-		}
-	}()
-
-	return nil
-}
-
 // ConfigPath returns the underlying configuration file path.
 func (c *Config) ConfigPath() string {
 	c.mu.RLock()
@@ -218,7 +575,8 @@ func (c *Config) ConfigPath() string {
 	return c.filePath
 }
 
-// GetExpansions returns a copy of the expansion slice.
+// GetExpansions returns a copy of the user's own expansions, the ones Save
+// writes; AllExpansions adds those of the snippet packs.
 func (c *Config) GetExpansions() []Expansion {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -242,6 +600,16 @@ func (c *Config) UpdateSettings(s Settings) {
 	c.Settings = s
 }
 
+// GetProfiles returns a copy of the injection profiles.
+func (c *Config) GetProfiles() []InjectionProfile {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	profiles := make([]InjectionProfile, len(c.Profiles))
+	copy(profiles, c.Profiles)
+	return profiles
+}
+
 // GetCustomVars returns a copy of the custom variable map.
 func (c *Config) GetCustomVars() map[string]string {
 	c.mu.RLock()
@@ -275,7 +643,7 @@ func (c *Config) DeleteCustomVar(key string) {
 
 // defaultConfig returns a Config populated with sensible defaults.
 func defaultConfig() *Config {
-	return &Config{
+	cfg := &Config{
 		Expansions: []Expansion{
 			{
 				Trigger:       ";email",
@@ -307,12 +675,40 @@ func defaultConfig() *Config {
 			"COMPANY": "Acme Corp",
 		},
 		Settings: Settings{
-			Enabled:           true,
-			TriggerOnSpace:    true,
-			TriggerOnTab:      true,
-			TriggerOnEnter:    true,
-			ShowNotifications: false,
-			LogExpansions:     true,
+			Enabled:            true,
+			TriggerOnSpace:     true,
+			TriggerOnTab:       true,
+			TriggerOnEnter:     true,
+			ShowNotifications:  false,
+			LogExpansions:      true,
+			PasteThreshold:     200,
+			MaxOutputSize:      20000,
+			ConfirmLargeOutput: true,
+			Hotkeys: Hotkeys{
+				Toggle:           "ctrl+alt+shift+e",
+				Pause:            "ctrl+alt+shift+p",
+				PauseMinutes:     DefaultPauseMinutes,
+				OpenManager:      "ctrl+alt+shift+m",
+				Reload:           "ctrl+alt+shift+r",
+				Palette:          "ctrl+alt+shift+space",
+				AcceptSuggestion: "ctrl+alt+space",
+			},
+			Suggestions: Suggestions{
+				Enabled:         true,
+				MinPrefixLength: DefaultSuggestionMinPrefix,
+				PrefixChar:      DefaultSuggestionPrefix,
+			},
+			HistoryLimit: DefaultHistoryLimit,
+		},
+		Profiles: []InjectionProfile{
+			{
+				Name:          "Terminals",
+				Process:       "WindowsTerminal.exe",
+				InjectMode:    InjectModePaste,
+				PasteShortcut: "ctrl+shift+v",
+			},
 		},
 	}
+	cfg.assignIDs()
+	return cfg
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -23,6 +24,25 @@ func TestLoadConfigCreatesDefault(t *testing.T) {
 	}
 }
 
+func TestLoadConfigKeepsDefaultsForMissingSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expansions.json")
+	data := `{"expansions": [], "settings": {"enabled": true}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig returned error: %v", err)
+	}
+	if got, want := cfg.GetSettings().PasteThreshold, defaultConfig().Settings.PasteThreshold; got != want {
+		t.Errorf("paste threshold = %d, want the default %d", got, want)
+	}
+	if s := cfg.GetSettings(); s.MaxOutputSize != defaultConfig().Settings.MaxOutputSize || !s.ConfirmLargeOutput {
+		t.Errorf("output size limit not defaulted: %d, confirm %v", s.MaxOutputSize, s.ConfirmLargeOutput)
+	}
+}
+
 func TestAddAndRemoveExpansion(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "expansions.json")
@@ -55,4 +75,155 @@ func TestAddAndRemoveExpansion(t *testing.T) {
 	if err := cfg.RemoveExpansion(";test"); err != nil {
 		t.Fatalf("RemoveExpansion returned error: %v", err)
 	}
-}
\ No newline at end of file
+}
+func TestParseHotkey(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "Ctrl+Alt+E", want: "ctrl+alt+e"},
+		{in: "shift+cmd+space", want: "shift+cmd+space"},
+		{in: "alt + ctrl + f9", want: "ctrl+alt+f9"},
+		{in: "f12", want: "f12"},
+		{in: "shift+e", wantErr: true},
+		{in: "f", wantErr: true},
+		{in: "ctrl+hyper+e", wantErr: true},
+		{in: "ctrl+alt+", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		h, err := ParseHotkey(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseHotkey(%q): expected error, got %v", tt.in, h)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseHotkey(%q): %v", tt.in, err)
+			continue
+		}
+		if got := h.String(); got != tt.want {
+			t.Errorf("ParseHotkey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHotkeysValidateRejectsDuplicates(t *testing.T) {
+	h := Hotkeys{Toggle: "ctrl+alt+e", Reload: "Alt+Ctrl+E"}
+	if err := h.Validate(); err == nil {
+		t.Fatalf("expected duplicate bindings to be rejected")
+	}
+
+	h.Reload = ""
+	if err := h.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDefaultHotkeysAreValid(t *testing.T) {
+	if err := defaultConfig().Settings.Hotkeys.Validate(); err != nil {
+		t.Fatalf("default hotkeys: %v", err)
+	}
+}
+
+func TestHotkeyConflicts(t *testing.T) {
+	cfg := &Config{
+		Expansions: []Expansion{
+			{Trigger: ";sig", Hotkey: "ctrl+alt+s", Replacement: "Regards"},
+			{Hotkey: "alt+ctrl+s", Replacement: "Cheers"},
+			{Hotkey: "ctrl+alt+shift+e", Replacement: "Toggle clash"},
+			{Hotkey: "shift+s", Replacement: "Invalid"},
+			{Hotkey: "ctrl+alt+d", Replacement: "Fine"},
+		},
+		Settings: Settings{Hotkeys: Hotkeys{Toggle: "ctrl+alt+shift+e"}},
+	}
+
+	conflicts := cfg.HotkeyConflicts()
+	if len(conflicts) != 3 {
+		t.Fatalf("expected 3 conflicts, got %v", conflicts)
+	}
+}
+
+func TestAddExpansionWithHotkeyOnly(t *testing.T) {
+	cfg := &Config{}
+	if err := cfg.AddExpansion(Expansion{Hotkey: "ctrl+alt+s", Replacement: "Regards"}); err != nil {
+		t.Fatalf("AddExpansion: %v", err)
+	}
+	if err := cfg.AddExpansion(Expansion{Hotkey: "Alt+Ctrl+S", Replacement: "Cheers"}); err == nil {
+		t.Fatalf("expected duplicate hotkey to be rejected")
+	}
+	if err := cfg.AddExpansion(Expansion{Replacement: "Nothing"}); err == nil {
+		t.Fatalf("expected expansion without trigger or hotkey to be rejected")
+	}
+	if err := cfg.RemoveExpansion("ctrl+alt+s"); err != nil {
+		t.Fatalf("RemoveExpansion by hotkey: %v", err)
+	}
+}
+
+func TestUpdateExpansion(t *testing.T) {
+	cfg := &Config{}
+	for _, exp := range []Expansion{
+		{Trigger: ";a", Replacement: "A"},
+		{Trigger: ";b", Replacement: "B"},
+	} {
+		if err := cfg.AddExpansion(exp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := cfg.GetExpansions()[0]
+	if a.ID == "" {
+		t.Fatal("expected AddExpansion to assign an ID")
+	}
+
+	// Renaming keeps the ID and the place in the list.
+	if err := cfg.UpdateExpansion(a.ID, Expansion{Trigger: ";renamed", Replacement: "A2"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetExpansions()[0]; got.ID != a.ID || got.Trigger != ";renamed" {
+		t.Fatalf("expected the expansion renamed in place, got %+v", got)
+	}
+
+	// A rejected update leaves the original untouched.
+	if err := cfg.UpdateExpansion(a.ID, Expansion{Trigger: ";b", Replacement: "dup"}); err == nil {
+		t.Fatal("expected a duplicate trigger to be rejected")
+	}
+	if got, _ := cfg.ExpansionByID(a.ID); got.Trigger != ";renamed" || got.Replacement != "A2" {
+		t.Errorf("rejected update changed the expansion: %+v", got)
+	}
+	if err := cfg.UpdateExpansion("missing", Expansion{Trigger: ";c"}); err == nil {
+		t.Error("expected an error for an unknown ID")
+	}
+}
+
+func TestLoadConfigAssignsIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expansions.json")
+	data := `{"schema_version": 2, "expansions": [
+		{"trigger": ";a", "replacement": "A"},
+		{"id": "same", "trigger": ";b", "replacement": "B"},
+		{"id": "same", "trigger": ";c", "replacement": "C"}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exps := cfg.GetExpansions()
+	if exps[0].ID == "" || exps[1].ID != "same" || exps[2].ID == "same" {
+		t.Fatalf("expected missing and duplicate IDs replaced, got %+v", exps)
+	}
+
+	// The IDs are saved, so they are the same the next time.
+	again, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, exp := range again.GetExpansions() {
+		if exp.ID != exps[i].ID {
+			t.Errorf("expansion %d: ID %q changed to %q", i, exps[i].ID, exp.ID)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats a configuration or pack file can be written in, chosen by its
+// extension.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// configFileNames are the names DefaultPath looks for, in order.
+var configFileNames = []string{"expansions.json", "expansions.yaml", "expansions.yml", "expansions.toml"}
+
+// DefaultPath returns the configuration file in dir: the first of
+// expansions.json, .yaml, .yml and .toml that exists, or expansions.json
+// when there is none yet.
+func DefaultPath(dir string) string {
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, configFileNames[0])
+}
+
+// FormatForPath returns the format selected by the extension of path.
+func FormatForPath(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	default:
+		return "", fmt.Errorf("unsupported configuration format %q: use .json, .yaml or .toml", ext)
+	}
+}
+
+// unmarshal decodes data in format into v. YAML and TOML are decoded into
+// generic values and passed through encoding/json, so every format uses the
+// JSON field names.
+func unmarshal(data []byte, format string, v any) error {
+	if format == FormatJSON {
+		return json.Unmarshal(data, v)
+	}
+	doc, err := decodeDocument(data, format)
+	if err != nil {
+		return err
+	}
+	return fromDocument(doc, v)
+}
+
+// marshal encodes v in format, keeping the field order of its JSON form.
+// Multi-line strings are written as YAML block scalars and TOML multi-line
+// strings.
+func marshal(v any, format string) ([]byte, error) {
+	if format == FormatJSON {
+		return json.MarshalIndent(v, "", "  ")
+	}
+
+	// JSON is YAML, so parsing it gives a tree in field order.
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(j, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("nothing to encode")
+	}
+	root := doc.Content[0]
+
+	switch format {
+	case FormatYAML:
+		setBlockStyle(root)
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(root); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatTOML:
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("toml: top level must be a table")
+		}
+		var buf bytes.Buffer
+		writeTOMLTable(&buf, nil, root)
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported configuration format %q", format)
+	}
+}
+
+// setBlockStyle drops the flow style JSON parses into and writes multi-line
+// strings as literal blocks where YAML can represent them.
+func setBlockStyle(n *yaml.Node) {
+	n.Style = 0
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" && strings.Contains(n.Value, "\n") {
+		n.Style = yaml.LiteralStyle
+		// Blank-only strings do not survive a round trip as blocks.
+		if strings.TrimSpace(n.Value) == "" {
+			n.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, c := range n.Content {
+		setBlockStyle(c)
+	}
+}
+
+// writeTOMLTable writes the mapping m as the table at path. TOML needs a
+// table's plain values before its sub-tables, so those follow, in the order
+// they appear in m.
+func writeTOMLTable(buf *bytes.Buffer, path []string, m *yaml.Node) {
+	var subs []int
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, val := m.Content[i], m.Content[i+1]
+		switch {
+		case val.Tag == "!!null":
+			// TOML has no null; leaving the key out decodes the same.
+		case val.Kind == yaml.MappingNode, isTableArray(val):
+			subs = append(subs, i)
+		default:
+			fmt.Fprintf(buf, "%s = %s\n", tomlKey(key.Value), tomlValue(val))
+		}
+	}
+
+	for _, i := range subs {
+		key, val := m.Content[i], m.Content[i+1]
+		sub := append(append([]string(nil), path...), tomlKey(key.Value))
+		name := strings.Join(sub, ".")
+		if val.Kind == yaml.MappingNode {
+			fmt.Fprintf(buf, "\n[%s]\n", name)
+			writeTOMLTable(buf, sub, val)
+			continue
+		}
+		for _, elem := range val.Content {
+			fmt.Fprintf(buf, "\n[[%s]]\n", name)
+			writeTOMLTable(buf, sub, elem)
+		}
+	}
+}
+
+// isTableArray reports whether n is a non-empty array of tables.
+func isTableArray(n *yaml.Node) bool {
+	return n.Kind == yaml.SequenceNode && len(n.Content) > 0 && n.Content[0].Kind == yaml.MappingNode
+}
+
+var bareTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(k string) string {
+	if bareTOMLKey.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+// tomlValue formats a scalar or an array of scalars.
+func tomlValue(n *yaml.Node) string {
+	if n.Kind == yaml.SequenceNode {
+		items := make([]string, len(n.Content))
+		for i, c := range n.Content {
+			items[i] = tomlValue(c)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	switch n.Tag {
+	case "!!str":
+		if strings.Contains(n.Value, "\n") {
+			return tomlMultiline(n.Value)
+		}
+		return tomlString(n.Value)
+	default: // !!int, !!float and !!bool are written the same in JSON and TOML
+		return n.Value
+	}
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			writeTOMLRune(&b, r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlMultiline quotes s as a TOML multi-line basic string, so its lines
+// stay lines in the file.
+func tomlMultiline(s string) string {
+	var b strings.Builder
+	b.WriteString("\"\"\"\n")
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteByte('\n')
+		default:
+			writeTOMLRune(&b, r)
+		}
+	}
+	b.WriteString(`"""`)
+	return b.String()
+}
+
+// writeTOMLRune writes r, escaping the control characters TOML does not
+// allow in strings.
+func writeTOMLRune(b *strings.Builder, r rune) {
+	switch {
+	case r == '\t':
+		b.WriteRune(r)
+	case r == '\r':
+		b.WriteString(`\r`)
+	case r < 0x20 || r == 0x7f:
+		fmt.Fprintf(b, `\u%04X`, r)
+	default:
+		b.WriteRune(r)
+	}
+}
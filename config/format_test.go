@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndLoadEveryFormat(t *testing.T) {
+	src := defaultConfig()
+	src.Expansions = []Expansion{
+		{Trigger: ";zeta", Replacement: "def main():\n    print(\"hi\")\n\treturn 0\n", Description: "multi-line"},
+		{Trigger: ";alpha", Replacement: `C:\path\to "file"`, Category: "Paths"},
+		{Trigger: ";yes", Replacement: "true"},
+		{Trigger: ";num", Replacement: "007", CaseSensitive: true},
+		{Trigger: ";uni", Replacement: "¯\\_(ツ)_/¯ \u00e9\r\n"},
+		{Trigger: ";br", Replacement: "\n\n"},
+		{Trigger: ";ws", Replacement: "  indented \nline with trailing space \n\n"},
+		{Hotkey: "ctrl+alt+q", Replacement: "quote \"\"\" marks"},
+	}
+	src.assignIDs()
+	src.CustomVariables = map[string]string{"name": "Ada", "odd key": "x"}
+
+	dir := t.TempDir()
+	for _, tt := range []struct{ name, marker string }{
+		{"expansions.json", `\n`},
+		{"expansions.yaml", "replacement: |"},
+		{"expansions.yml", "replacement: |"},
+		{"expansions.toml", `replacement = """`},
+	} {
+		path := filepath.Join(dir, tt.name)
+		if err := src.SaveTo(path); err != nil {
+			t.Fatalf("%s: SaveTo: %v", tt.name, err)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := string(raw)
+		if !strings.Contains(data, tt.marker) {
+			t.Errorf("%s: expected %q in output:\n%s", tt.name, tt.marker, data)
+		}
+
+		got, err := ReadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: ReadConfig: %v\n%s", tt.name, err, data)
+		}
+		if !reflect.DeepEqual(got.Expansions, src.Expansions) {
+			t.Errorf("%s: expansions changed:\ngot  %+v\nwant %+v", tt.name, got.Expansions, src.Expansions)
+		}
+		if !reflect.DeepEqual(got.CustomVariables, src.CustomVariables) {
+			t.Errorf("%s: custom variables changed: %v", tt.name, got.CustomVariables)
+		}
+		if !reflect.DeepEqual(got.Settings, src.Settings) || !reflect.DeepEqual(got.Profiles, src.Profiles) {
+			t.Errorf("%s: settings or profiles changed", tt.name)
+		}
+	}
+}
+
+func TestUnquotedScalarsAreReadAsText(t *testing.T) {
+	for _, tt := range []struct{ name, data string }{
+		{"expansions.yaml", "expansions:\n  - trigger: 123\n    replacement: 01234\n    case_sensitive: true\n  - trigger: ;pi\n    replacement: 3.140\ncustom_variables:\n  flag: yes\n  on: true\nsettings:\n  paste_threshold: 0o17\n"},
+		{"expansions.toml", "[[expansions]]\ntrigger = 123\nreplacement = 1234\ncase_sensitive = true\n\n[[expansions]]\ntrigger = \";pi\"\nreplacement = 3.14\n\n[custom_variables]\nflag = \"yes\"\non = true\n\n[settings]\npaste_threshold = 15\n"},
+	} {
+		cfg, err := ReadConfig(writeConfig(t, tt.name, tt.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		want := map[string]string{"123": "01234", ";pi": "3.140"}
+		if tt.name == "expansions.toml" {
+			want = map[string]string{"123": "1234", ";pi": "3.14"}
+		}
+		for _, exp := range cfg.Expansions {
+			if exp.Replacement != want[exp.Trigger] {
+				t.Errorf("%s: %q expands to %q, want %q", tt.name, exp.Trigger, exp.Replacement, want[exp.Trigger])
+			}
+		}
+		if len(cfg.Expansions) != 2 || !cfg.Expansions[0].CaseSensitive {
+			t.Errorf("%s: unexpected expansions %+v", tt.name, cfg.Expansions)
+		}
+		if got := cfg.CustomVariables; got["flag"] != "yes" || got["on"] != "true" {
+			t.Errorf("%s: unexpected custom variables %v", tt.name, got)
+		}
+		if got := cfg.Settings.PasteThreshold; got != 15 {
+			t.Errorf("%s: paste_threshold = %d, want 15", tt.name, got)
+		}
+	}
+}
+
+func TestFormatForPathRejectsUnknownExtensions(t *testing.T) {
+	if _, err := FormatForPath("expansions.ini"); err == nil {
+		t.Fatal("expected an error for .ini")
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "expansions.txt")); err == nil {
+		t.Fatal("expected LoadConfig to reject .txt")
+	}
+}
+
+func TestDefaultPathFindsExistingFormat(t *testing.T) {
+	dir := t.TempDir()
+	if got := DefaultPath(dir); got != filepath.Join(dir, "expansions.json") {
+		t.Fatalf("got %q for an empty directory", got)
+	}
+	if err := defaultConfig().SaveTo(filepath.Join(dir, "expansions.toml")); err != nil {
+		t.Fatal(err)
+	}
+	if got := DefaultPath(dir); got != filepath.Join(dir, "expansions.toml") {
+		t.Fatalf("got %q, want the TOML file", got)
+	}
+}
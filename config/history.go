@@ -0,0 +1,323 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// historyDirName is the directory, next to the configuration file, that
+	// holds snapshots of earlier versions of it.
+	historyDirName = "history"
+	// DefaultHistoryLimit is the number of snapshots kept unless the
+	// settings say otherwise.
+	DefaultHistoryLimit = 20
+	// snapshotLayout is the time format in snapshot file names, which sorts
+	// in time order.
+	snapshotLayout = "20060102-150405.000"
+)
+
+// ErrSnapshot is wrapped by the error Save returns when the configuration
+// was saved but the version it replaced could not be kept.
+var ErrSnapshot = errors.New("keeping a snapshot of the previous version failed")
+
+// timeNow is replaced by tests.
+var timeNow = time.Now
+
+// Snapshot is an earlier version of the configuration file, kept in the
+// history directory when the configuration was saved over it.
+type Snapshot struct {
+	Path string
+	Time time.Time
+}
+
+// Load reads the snapshot. Snapshots from older schema versions are
+// migrated in memory.
+func (s Snapshot) Load() (*Config, error) {
+	return ReadConfig(s.Path)
+}
+
+// HistoryDir returns the directory snapshots are kept in.
+func (c *Config) HistoryDir() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.historyDirLocked()
+}
+
+func (c *Config) historyDirLocked() string {
+	if c.filePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(c.filePath), historyDirName)
+}
+
+// Snapshots lists the snapshots of the configuration file, newest first.
+func (c *Config) Snapshots() ([]Snapshot, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshotsLocked()
+}
+
+func (c *Config) snapshotsLocked() ([]Snapshot, error) {
+	dir := c.historyDirLocked()
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	base := filepath.Base(c.filePath)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	var snaps []Snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		t, err := time.ParseInLocation(snapshotLayout, stamp, time.Local)
+		if err != nil {
+			continue // not a snapshot
+		}
+		snaps = append(snaps, Snapshot{Path: filepath.Join(dir, name), Time: t})
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Time.After(snaps[j].Time) })
+	return snaps, nil
+}
+
+// snapshotLocked copies the configuration file into the history directory
+// before it is overwritten, then removes the oldest snapshots beyond the
+// history limit. A file that is the same as the last snapshot is not
+// copied again. c.mu must be held by the caller.
+func (c *Config) snapshotLocked() error {
+	limit := c.Settings.HistoryLimit
+	if limit <= 0 || c.filePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.filePath)
+	if os.IsNotExist(err) || len(data) == 0 {
+		return nil // nothing to keep
+	}
+	if err != nil {
+		return err
+	}
+
+	snaps, err := c.snapshotsLocked()
+	if err != nil {
+		return err
+	}
+	// File names keep the time to the millisecond.
+	now := timeNow().Truncate(time.Millisecond)
+	if len(snaps) > 0 {
+		if last, err := os.ReadFile(snaps[0].Path); err == nil && bytes.Equal(last, data) {
+			return nil
+		}
+		// Saves within the same millisecond still get a snapshot each.
+		if !now.After(snaps[0].Time) {
+			now = snaps[0].Time.Add(time.Millisecond)
+		}
+	}
+
+	dir := c.historyDirLocked()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	base := filepath.Base(c.filePath)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext) + "-" + now.Format(snapshotLayout) + ext
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+
+	snaps = append([]Snapshot{{Path: path, Time: now}}, snaps...)
+	for _, old := range snaps[min(limit, len(snaps)):] {
+		if err := os.Remove(old.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreSnapshot replaces the expansions, custom variables, settings and
+// profiles with those of s and saves the result. The configuration being
+// replaced is snapshotted first, so a restore can itself be undone.
+func (c *Config) RestoreSnapshot(s Snapshot) error {
+	old, err := s.Load()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.filePath == "" {
+		return errors.New("config file path is not set")
+	}
+	if err := c.snapshotLocked(); err != nil {
+		return fmt.Errorf("keeping a snapshot of the current configuration: %w", err)
+	}
+	c.Expansions = old.Expansions
+	c.CustomVariables = old.CustomVariables
+	c.Settings = old.Settings
+	c.Profiles = old.Profiles
+	c.warnings = old.warnings
+	return c.writeLocked(c.filePath)
+}
+
+// RestoreExpansion undoes ch, making the expansion what it is in the
+// snapshot: an added expansion is removed, a removed one added back and a
+// modified one replaced, keeping its current ID.
+func (c *Config) RestoreExpansion(ch ExpansionChange) error {
+	switch ch.Kind {
+	case Added:
+		return c.RemoveExpansionByID(ch.Current.ID)
+	case Removed:
+		return c.AddExpansion(ch.Snapshot)
+	default:
+		return c.UpdateExpansion(ch.Current.ID, ch.Snapshot)
+	}
+}
+
+// sameExpansion reports whether a and b are the same apart from their IDs.
+func sameExpansion(a, b Expansion) bool {
+	a.ID, b.ID = "", ""
+	return a == b
+}
+
+// ChangeKind says how something differs between a snapshot and the current
+// configuration.
+type ChangeKind int
+
+const (
+	Added    ChangeKind = iota // only in the current configuration
+	Removed                    // only in the snapshot
+	Modified                   // in both, but different
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	default:
+		return "modified"
+	}
+}
+
+// ExpansionChange is an expansion that differs between a snapshot and the
+// current configuration.
+type ExpansionChange struct {
+	// Name is the expansion's current name, or its name in the snapshot
+	// when it was removed.
+	Name     string
+	Kind     ChangeKind
+	Snapshot Expansion // zero when Added
+	Current  Expansion // zero when Removed
+}
+
+// VariableChange is a custom variable that differs between a snapshot and
+// the current configuration.
+type VariableChange struct {
+	Name              string
+	Kind              ChangeKind
+	Snapshot, Current string
+}
+
+// Diff lists what changed between a snapshot and the current configuration.
+type Diff struct {
+	Expansions []ExpansionChange
+	Variables  []VariableChange
+	// Settings and Profiles report whether the settings or the injection
+	// profiles differ.
+	Settings, Profiles bool
+}
+
+// Empty reports whether the configurations are the same.
+func (d Diff) Empty() bool {
+	return len(d.Expansions) == 0 && len(d.Variables) == 0 && !d.Settings && !d.Profiles
+}
+
+// DiffConfigs compares snapshot with current. Expansions are matched by
+// ID, so one whose trigger changed shows as modified. Those left unmatched
+// are then matched by name, for snapshots saved before expansions had IDs.
+func DiffConfigs(snapshot, current *Config) Diff {
+	var d Diff
+
+	before, after := snapshot.GetExpansions(), current.GetExpansions()
+	match := make([]int, len(after)) // index in before, or -1
+	matched := make([]bool, len(before))
+	byID := make(map[string]int, len(before))
+	for i, exp := range before {
+		byID[exp.ID] = i
+	}
+	for i, exp := range after {
+		match[i] = -1
+		if j, ok := byID[exp.ID]; ok && exp.ID != "" {
+			match[i], matched[j] = j, true
+		}
+	}
+	byName := make(map[string]int)
+	for j, exp := range before {
+		if !matched[j] {
+			byName[exp.Name()] = j
+		}
+	}
+	for i, exp := range after {
+		if j, ok := byName[exp.Name()]; ok && match[i] < 0 && !matched[j] {
+			match[i], matched[j] = j, true
+		}
+	}
+
+	for i, exp := range after {
+		if match[i] < 0 {
+			d.Expansions = append(d.Expansions, ExpansionChange{Name: exp.Name(), Kind: Added, Current: exp})
+			continue
+		}
+		if old := before[match[i]]; !sameExpansion(old, exp) {
+			d.Expansions = append(d.Expansions, ExpansionChange{Name: exp.Name(), Kind: Modified, Snapshot: old, Current: exp})
+		}
+	}
+	for j, exp := range before {
+		if !matched[j] {
+			d.Expansions = append(d.Expansions, ExpansionChange{Name: exp.Name(), Kind: Removed, Snapshot: exp})
+		}
+	}
+	sort.Slice(d.Expansions, func(i, j int) bool { return d.Expansions[i].Name < d.Expansions[j].Name })
+
+	oldVars, newVars := snapshot.GetCustomVars(), current.GetCustomVars()
+	for name, v := range newVars {
+		old, ok := oldVars[name]
+		switch {
+		case !ok:
+			d.Variables = append(d.Variables, VariableChange{Name: name, Kind: Added, Current: v})
+		case old != v:
+			d.Variables = append(d.Variables, VariableChange{Name: name, Kind: Modified, Snapshot: old, Current: v})
+		}
+	}
+	for name, v := range oldVars {
+		if _, ok := newVars[name]; !ok {
+			d.Variables = append(d.Variables, VariableChange{Name: name, Kind: Removed, Snapshot: v})
+		}
+	}
+	sort.Slice(d.Variables, func(i, j int) bool { return d.Variables[i].Name < d.Variables[j].Name })
+
+	d.Settings = snapshot.GetSettings() != current.GetSettings()
+	d.Profiles = !reflect.DeepEqual(snapshot.GetProfiles(), current.GetProfiles())
+	return d
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClock makes timeNow return a time the test advances.
+func fakeClock(t *testing.T) *time.Time {
+	t.Helper()
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+	return &now
+}
+
+// newHistoryConfig creates a default configuration in a temporary
+// directory, keeping limit snapshots.
+func newHistoryConfig(t *testing.T, limit int) *Config {
+	t.Helper()
+	cfg, err := LoadConfig(filepath.Join(t.TempDir(), "expansions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := cfg.GetSettings()
+	s.HistoryLimit = limit
+	cfg.UpdateSettings(s)
+	return cfg
+}
+
+// saveVariable sets a custom variable and saves.
+func saveVariable(t *testing.T, cfg *Config, value string) {
+	t.Helper()
+	cfg.SetCustomVar("V", value)
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveKeepsSnapshotOfPreviousVersion(t *testing.T) {
+	now := fakeClock(t)
+	cfg := newHistoryConfig(t, 5)
+	original, err := os.ReadFile(cfg.ConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saveVariable(t, cfg, "one")
+	snaps, err := cfg.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 1 || !snaps[0].Time.Equal(*now) {
+		t.Fatalf("expected one snapshot taken now, got %+v", snaps)
+	}
+	if data, _ := os.ReadFile(snaps[0].Path); string(data) != string(original) {
+		t.Error("snapshot is not the version that was replaced")
+	}
+
+	// Every save keeps the version it replaces, however soon it follows,
+	// even within the same millisecond.
+	saveVariable(t, cfg, "two")
+	saveVariable(t, cfg, "three")
+	snaps, _ = cfg.Snapshots()
+	if len(snaps) != 3 || !snaps[0].Time.After(snaps[1].Time) {
+		t.Fatalf("expected three snapshots, newest first, got %+v", snaps)
+	}
+
+	// Saving unchanged contents adds none.
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cfg.Snapshots(); len(again) != 3 {
+		t.Fatalf("expected an unchanged save to add no snapshot, got %d", len(again))
+	}
+	old, err := snaps[0].Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := old.GetCustomVars()["V"]; v != "two" {
+		t.Errorf("expected the newest snapshot to hold %q, got %q", "two", v)
+	}
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	now := fakeClock(t)
+	cfg := newHistoryConfig(t, 3)
+	for _, v := range []string{"a", "b", "c", "d", "e", "f"} {
+		*now = now.Add(2 * time.Minute)
+		saveVariable(t, cfg, v)
+	}
+
+	snaps, err := cfg.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 3 {
+		t.Fatalf("expected 3 snapshots, got %d", len(snaps))
+	}
+	for i, want := range []string{"e", "d", "c"} {
+		old, err := snaps[i].Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := old.GetCustomVars()["V"]; v != want {
+			t.Errorf("snapshot %d: got %q, want %q", i, v, want)
+		}
+	}
+}
+
+func TestHistoryLimitZeroKeepsNoSnapshots(t *testing.T) {
+	now := fakeClock(t)
+	cfg := newHistoryConfig(t, 0)
+	for _, v := range []string{"a", "b"} {
+		*now = now.Add(2 * time.Minute)
+		saveVariable(t, cfg, v)
+	}
+	if _, err := os.Stat(cfg.HistoryDir()); !os.IsNotExist(err) {
+		t.Error("expected no history directory")
+	}
+}
+
+func TestDiffConfigs(t *testing.T) {
+	snapshot := &Config{
+		Expansions: []Expansion{
+			{Trigger: ";same", Replacement: "same"},
+			{Trigger: ";edit", Replacement: "before"},
+			{Trigger: ";gone", Replacement: "gone"},
+		},
+		CustomVariables: map[string]string{"NAME": "Ada", "OLD": "x"},
+	}
+	current := &Config{
+		Expansions: []Expansion{
+			{Trigger: ";new", Replacement: "new"},
+			{Trigger: ";edit", Replacement: "after"},
+			{Trigger: ";same", Replacement: "same"},
+		},
+		CustomVariables: map[string]string{"NAME": "Grace"},
+	}
+
+	d := DiffConfigs(snapshot, current)
+	want := []struct {
+		name string
+		kind ChangeKind
+	}{{";edit", Modified}, {";gone", Removed}, {";new", Added}}
+	if len(d.Expansions) != len(want) {
+		t.Fatalf("got %+v", d.Expansions)
+	}
+	for i, w := range want {
+		if c := d.Expansions[i]; c.Name != w.name || c.Kind != w.kind {
+			t.Errorf("change %d: got %s %s, want %s %s", i, c.Name, c.Kind, w.name, w.kind)
+		}
+	}
+	if d.Expansions[0].Snapshot.Replacement != "before" || d.Expansions[0].Current.Replacement != "after" {
+		t.Errorf("modified change has the wrong versions: %+v", d.Expansions[0])
+	}
+	if len(d.Variables) != 2 || d.Variables[0].Kind != Modified || d.Variables[1].Kind != Removed {
+		t.Errorf("unexpected variable changes: %+v", d.Variables)
+	}
+	if d.Settings || d.Profiles {
+		t.Error("settings and profiles are the same")
+	}
+	if !DiffConfigs(current, current).Empty() {
+		t.Error("expected no differences with itself")
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	now := fakeClock(t)
+	cfg := newHistoryConfig(t, 5)
+	saveVariable(t, cfg, "good")
+	*now = now.Add(2 * time.Minute)
+	if err := cfg.RemoveExpansion(";email"); err != nil {
+		t.Fatal(err)
+	}
+	saveVariable(t, cfg, "bad")
+
+	*now = now.Add(time.Second)
+	snaps, _ := cfg.Snapshots()
+	if err := cfg.RestoreSnapshot(snaps[0]); err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.GetCustomVars()["V"]; v != "good" {
+		t.Errorf("variable not restored: %q", v)
+	}
+	reloaded, err := ReadConfig(cfg.ConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.GetExpansions()) != len(defaultConfig().Expansions) {
+		t.Error("restored configuration not saved")
+	}
+
+	// The replaced version is kept, so the restore can be undone.
+	after, _ := cfg.Snapshots()
+	if len(after) != len(snaps)+1 {
+		t.Fatalf("expected a snapshot of the replaced version, got %d", len(after))
+	}
+	undo, err := after[0].Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := undo.GetCustomVars()["V"]; v != "bad" {
+		t.Errorf("expected the replaced version, got %q", v)
+	}
+}
+
+func TestDiffMatchesExpansionsByID(t *testing.T) {
+	snapshot := &Config{Expansions: []Expansion{
+		{ID: "a", Trigger: ";addr", Replacement: "1 Main St"},
+		// Saved before expansions had IDs, then given one on reading.
+		{ID: "old", Trigger: ";sig", Replacement: "Ada"},
+	}}
+	current := &Config{Expansions: []Expansion{
+		{ID: "a", Trigger: ";home", Replacement: "1 Main St"},
+		{ID: "b", Trigger: ";addr", Replacement: "2 High St"},
+		{ID: "new", Trigger: ";sig", Replacement: "Ada"},
+	}}
+
+	d := DiffConfigs(snapshot, current)
+	if len(d.Expansions) != 2 {
+		t.Fatalf("expected a rename and an addition, got %+v", d.Expansions)
+	}
+	if c := d.Expansions[0]; c.Name != ";addr" || c.Kind != Added || c.Current.ID != "b" {
+		t.Errorf("expected the new ;addr added, got %+v", c)
+	}
+	if c := d.Expansions[1]; c.Name != ";home" || c.Kind != Modified || c.Snapshot.Trigger != ";addr" {
+		t.Errorf("expected ;addr renamed to ;home, got %+v", c)
+	}
+}
+
+func TestRestoreExpansion(t *testing.T) {
+	snapshot := &Config{Expansions: []Expansion{
+		{ID: "1", Trigger: ";edit", Replacement: "before"},
+		{ID: "2", Trigger: ";gone", Replacement: "gone"},
+	}}
+	cfg := &Config{Expansions: []Expansion{
+		{ID: "1", Trigger: ";edited", Replacement: "after"},
+		{ID: "3", Trigger: ";new", Replacement: "new"},
+	}}
+
+	changes := DiffConfigs(snapshot, cfg).Expansions
+	for _, c := range changes {
+		if err := cfg.RestoreExpansion(c); err != nil {
+			t.Fatalf("%s: %v", c.Name, err)
+		}
+	}
+	if !DiffConfigs(snapshot, cfg).Empty() {
+		t.Errorf("expected the snapshot's expansions, got %+v", cfg.GetExpansions())
+	}
+	if exp, ok := cfg.ExpansionByID("1"); !ok || exp.Trigger != ";edit" {
+		t.Errorf("expected the edited expansion to keep its ID, got %+v", exp)
+	}
+	// Changes are sorted by name, so ";new", which was removed, is last.
+	if err := cfg.RestoreExpansion(changes[len(changes)-1]); err == nil {
+		t.Error("expected an error removing an expansion twice")
+	}
+}
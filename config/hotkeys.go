@@ -0,0 +1,209 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hotkeys holds the global shortcut bindings, written like "ctrl+alt+e".
+// An empty binding disables that shortcut.
+type Hotkeys struct {
+	Toggle       string `json:"toggle,omitempty"`        // enable or disable expansions
+	Pause        string `json:"pause,omitempty"`         // pause expansions for PauseMinutes
+	PauseMinutes int    `json:"pause_minutes,omitempty"` // 0 uses DefaultPauseMinutes
+	OpenManager  string `json:"open_manager,omitempty"`  // open the configuration window
+	Reload       string `json:"reload,omitempty"`        // reload the configuration file
+	Palette      string `json:"palette,omitempty"`       // search and insert a snippet
+	// AcceptSuggestion inserts the top inline suggestion for the trigger
+	// being typed.
+	AcceptSuggestion string `json:"accept_suggestion,omitempty"`
+}
+
+// DefaultPauseMinutes is used when Hotkeys.PauseMinutes is not set.
+const DefaultPauseMinutes = 15
+
+// Names of the actions a hotkey can be bound to.
+const (
+	HotkeyToggle      = "toggle"
+	HotkeyPause       = "pause"
+	HotkeyOpenManager = "open_manager"
+	HotkeyReload      = "reload"
+	HotkeyPalette     = "palette"
+	HotkeyAccept      = "accept_suggestion"
+)
+
+// HotkeyActions lists the action names in the order they are registered, so
+// an earlier action wins a contested binding.
+var HotkeyActions = []string{HotkeyToggle, HotkeyPause, HotkeyOpenManager, HotkeyReload, HotkeyPalette, HotkeyAccept}
+
+// Bindings returns the configured bindings keyed by action name, skipping
+// empty ones.
+func (h Hotkeys) Bindings() map[string]string {
+	m := make(map[string]string, len(HotkeyActions))
+	for action, binding := range map[string]string{
+		HotkeyToggle:      h.Toggle,
+		HotkeyPause:       h.Pause,
+		HotkeyOpenManager: h.OpenManager,
+		HotkeyReload:      h.Reload,
+		HotkeyPalette:     h.Palette,
+		HotkeyAccept:      h.AcceptSuggestion,
+	} {
+		if strings.TrimSpace(binding) != "" {
+			m[action] = binding
+		}
+	}
+	return m
+}
+
+// PauseDuration returns the pause length in minutes.
+func (h Hotkeys) PauseDuration() int {
+	if h.PauseMinutes > 0 {
+		return h.PauseMinutes
+	}
+	return DefaultPauseMinutes
+}
+
+// Hotkey is a parsed binding: a key plus the modifiers held with it.
+type Hotkey struct {
+	Ctrl, Shift, Alt, Meta bool
+	Key                    string // lower-case key name, see HotkeyKeys
+}
+
+// HotkeyKeys lists the key names a hotkey can use besides letters, digits
+// and f1-f12.
+var HotkeyKeys = []string{
+	"space", "enter", "tab", "escape", "backspace", "insert", "delete",
+	"home", "end", "pageup", "pagedown", "up", "down", "left", "right",
+}
+
+// ParseHotkey parses a binding such as "ctrl+shift+f9". At least one of
+// Ctrl, Alt or Meta is required unless the key is a function key, so a
+// hotkey never swallows ordinary typing.
+func ParseHotkey(s string) (Hotkey, error) {
+	var h Hotkey
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "+")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if i == len(parts)-1 {
+			if !validHotkeyKey(part) {
+				return Hotkey{}, fmt.Errorf("hotkey %q: unknown key %q", s, part)
+			}
+			h.Key = part
+			break
+		}
+		switch part {
+		case "ctrl", "control":
+			h.Ctrl = true
+		case "shift":
+			h.Shift = true
+		case "alt", "option":
+			h.Alt = true
+		case "cmd", "meta", "super", "win":
+			h.Meta = true
+		default:
+			return Hotkey{}, fmt.Errorf("hotkey %q: unknown modifier %q", s, part)
+		}
+	}
+
+	isFunctionKey := len(h.Key) > 1 && h.Key[0] == 'f'
+	if !h.Ctrl && !h.Alt && !h.Meta && !isFunctionKey {
+		return Hotkey{}, fmt.Errorf("hotkey %q: needs Ctrl, Alt or Cmd", s)
+	}
+	return h, nil
+}
+
+// String formats the hotkey in canonical form.
+func (h Hotkey) String() string {
+	var parts []string
+	if h.Ctrl {
+		parts = append(parts, "ctrl")
+	}
+	if h.Alt {
+		parts = append(parts, "alt")
+	}
+	if h.Shift {
+		parts = append(parts, "shift")
+	}
+	if h.Meta {
+		parts = append(parts, "cmd")
+	}
+	return strings.Join(append(parts, h.Key), "+")
+}
+
+func validHotkeyKey(k string) bool {
+	if len(k) == 1 && (k[0] >= 'a' && k[0] <= 'z' || k[0] >= '0' && k[0] <= '9') {
+		return true
+	}
+	for i := 1; i <= 12; i++ {
+		if k == fmt.Sprintf("f%d", i) {
+			return true
+		}
+	}
+	for _, name := range HotkeyKeys {
+		if k == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks every binding and reports the first invalid or duplicated
+// one.
+func (h Hotkeys) Validate() error {
+	seen := make(map[string]string)
+	for _, action := range HotkeyActions {
+		binding, ok := h.Bindings()[action]
+		if !ok {
+			continue
+		}
+		hk, err := ParseHotkey(binding)
+		if err != nil {
+			return err
+		}
+		if other, dup := seen[hk.String()]; dup {
+			return fmt.Errorf("hotkey %q is bound to both %s and %s", binding, other, action)
+		}
+		seen[hk.String()] = action
+	}
+	return nil
+}
+
+// HotkeyConflicts reports expansion hotkeys that cannot be registered: those
+// that do not parse and those already taken by a global hotkey or by an
+// earlier expansion. The conflicting bindings are ignored at run time.
+func (c *Config) HotkeyConflicts() []error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	owners := make(map[string]string)
+	for _, action := range HotkeyActions {
+		binding, ok := c.Settings.Hotkeys.Bindings()[action]
+		if !ok {
+			continue
+		}
+		if hk, err := ParseHotkey(binding); err == nil {
+			if _, taken := owners[hk.String()]; !taken {
+				owners[hk.String()] = "the " + action + " action"
+			}
+		}
+	}
+
+	exps, _ := c.mergePacksLocked()
+	var errs []error
+	for _, exp := range exps {
+		if exp.Hotkey == "" {
+			continue
+		}
+		hk, err := ParseHotkey(exp.Hotkey)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("expansion %q: %w", exp.Name(), err))
+			continue
+		}
+		if owner, taken := owners[hk.String()]; taken {
+			errs = append(errs, fmt.Errorf("expansion %q: hotkey %q is already used by %s", exp.Name(), exp.Hotkey, owner))
+			continue
+		}
+		owners[hk.String()] = fmt.Sprintf("expansion %q", exp.Name())
+	}
+	return errs
+}
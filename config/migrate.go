@@ -0,0 +1,370 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentSchemaVersion is the schema_version this build reads and writes.
+// Files without one are version 0.
+const CurrentSchemaVersion = 2
+
+// migration upgrades a decoded configuration by one schema version.
+type migration struct {
+	description string
+	apply       func(doc map[string]any) error
+}
+
+// migrations[v] turns a version v document into version v+1. Migrations
+// work on the generic document rather than on Config, so they can read
+// fields that no longer exist and tell a missing field from a zero one.
+var migrations = []migration{
+	0: {"fill in settings added since the first release", fillMissingSettings},
+	1: {"fill in the snapshot history limit", fillHistoryLimit},
+}
+
+// ErrNewerSchema is returned for configuration files written by a newer
+// version of the application, which this one could only damage.
+var ErrNewerSchema = fmt.Errorf("configuration was written by a newer version (schema version above %d)", CurrentSchemaVersion)
+
+// decodeDocument decodes data in format into a generic document.
+func decodeDocument(data []byte, format string) (map[string]any, error) {
+	doc := make(map[string]any)
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+	case FormatYAML:
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, err
+		}
+		// Decoding the tree reports what parsing lets through, such as
+		// keys given twice.
+		if err := root.Decode(&doc); err != nil {
+			return nil, err
+		}
+		v, err := yamlValue(&root)
+		if err != nil {
+			return nil, err
+		}
+		doc, _ = v.(map[string]any)
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported configuration format %q", format)
+	}
+	if doc == nil {
+		doc = make(map[string]any)
+	}
+	return doc, nil
+}
+
+// fromDocument decodes a generic document into v through encoding/json, so
+// every format uses the JSON field names. Numbers and booleans where v has
+// a string are taken as text, since YAML and TOML read an unquoted
+// "replacement: 90210" as a number.
+func fromDocument(doc map[string]any, v any) error {
+	j, err := json.Marshal(asStrings(doc, reflect.TypeOf(v)))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, v)
+}
+
+// yamlScalar is a plain YAML number or boolean, kept with the text it was
+// written as so that one read as a string keeps its leading zeros and
+// trailing digits.
+type yamlScalar struct {
+	text  string
+	value any
+}
+
+func (s yamlScalar) MarshalJSON() ([]byte, error) { return json.Marshal(s.value) }
+
+// yamlValue converts a YAML tree into the generic values yaml.v3 would
+// decode it to, except that numbers and booleans are yamlScalars.
+func yamlValue(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(n.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(n.Alias)
+	case yaml.SequenceNode:
+		list := make([]any, len(n.Content))
+		for i, c := range n.Content {
+			v, err := yamlValue(c)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = v
+		}
+		return list, nil
+	case yaml.MappingNode:
+		m := make(map[string]any)
+		var merged []map[string]any
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			v, err := yamlValue(val)
+			if err != nil {
+				return nil, err
+			}
+			if key.Tag == "!!merge" {
+				// Keys merged in give way to the mapping's own.
+				switch v := v.(type) {
+				case map[string]any:
+					merged = append(merged, v)
+				case []any:
+					for _, e := range v {
+						if e, ok := e.(map[string]any); ok {
+							merged = append(merged, e)
+						}
+					}
+				}
+				continue
+			}
+			m[key.Value] = v
+		}
+		for _, src := range merged {
+			for k, v := range src {
+				if _, ok := m[k]; !ok {
+					m[k] = v
+				}
+			}
+		}
+		return m, nil
+	}
+
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	switch v.(type) {
+	case int, int64, uint64, float64, bool:
+		return yamlScalar{text: n.Value, value: v}, nil
+	}
+	return v, nil
+}
+
+// asStrings returns v with the numbers and booleans found where t has a
+// string turned into text. v is a generic value as decodeDocument returns
+// them.
+func asStrings(v any, t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		switch s := v.(type) {
+		case yamlScalar:
+			return s.text
+		case json.Number:
+			return s.String()
+		case int64:
+			return strconv.FormatInt(s, 10)
+		case float64:
+			return strconv.FormatFloat(s, 'g', -1, 64)
+		case bool:
+			return strconv.FormatBool(s)
+		}
+	case reflect.Slice, reflect.Array:
+		switch list := v.(type) {
+		case []any:
+			out := make([]any, len(list))
+			for i, e := range list {
+				out[i] = asStrings(e, t.Elem())
+			}
+			return out
+		case []map[string]any: // TOML arrays of tables
+			out := make([]any, len(list))
+			for i, e := range list {
+				out[i] = asStrings(e, t.Elem())
+			}
+			return out
+		}
+	case reflect.Map:
+		if m, ok := v.(map[string]any); ok {
+			out := make(map[string]any, len(m))
+			for k, e := range m {
+				out[k] = asStrings(e, t.Elem())
+			}
+			return out
+		}
+	case reflect.Struct:
+		if m, ok := v.(map[string]any); ok {
+			out := make(map[string]any, len(m))
+			for k, e := range m {
+				if f, ok := jsonField(t, k); ok {
+					e = asStrings(e, f.Type)
+				}
+				out[k] = e
+			}
+			return out
+		}
+	}
+	return v
+}
+
+// jsonField finds the field of struct type t that encoding/json decodes
+// key into, preferring an exact match of its name as encoding/json does.
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var fold reflect.StructField
+	found := false
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return f, true
+		}
+		if !found && strings.EqualFold(name, key) {
+			fold, found = f, true
+		}
+	}
+	return fold, found
+}
+
+// schemaVersion returns the document's schema_version, or 0 when it has
+// none.
+func schemaVersion(doc map[string]any) (int, error) {
+	raw, ok := doc["schema_version"]
+	if !ok {
+		return 0, nil
+	}
+	var f float64
+	switch v := raw.(type) {
+	case json.Number:
+		var err error
+		if f, err = v.Float64(); err != nil {
+			return 0, fmt.Errorf("schema_version: %w", err)
+		}
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case float64:
+		f = v
+	case yamlScalar:
+		return schemaVersion(map[string]any{"schema_version": v.value})
+	default:
+		return 0, fmt.Errorf("schema_version must be a number, not %v", raw)
+	}
+	if f < 0 || f != math.Trunc(f) {
+		return 0, fmt.Errorf("invalid schema_version %v", raw)
+	}
+	return int(f), nil
+}
+
+// migrate upgrades doc to CurrentSchemaVersion in place and returns the
+// version it had.
+func migrate(doc map[string]any) (int, error) {
+	from, err := schemaVersion(doc)
+	if err != nil {
+		return 0, err
+	}
+	if from > CurrentSchemaVersion {
+		return from, ErrNewerSchema
+	}
+	for v := from; v < CurrentSchemaVersion; v++ {
+		if err := migrations[v].apply(doc); err != nil {
+			return from, fmt.Errorf("migrating schema version %d to %d (%s): %w", v, v+1, migrations[v].description, err)
+		}
+	}
+	doc["schema_version"] = CurrentSchemaVersion
+	return from, nil
+}
+
+// fillMissingSettings migrates version 0. Settings such as paste_threshold,
+// max_output_size and the hotkeys were added without a version, so older
+// files lack them and would decode to zero values that switch the features
+// off; the defaults are filled in for every setting the file does not
+// mention. Hotkeys are left out of the file when cleared, so they are only
+// filled in when the file has no hotkeys table at all.
+func fillMissingSettings(doc map[string]any) error {
+	j, err := json.Marshal(defaultConfig().Settings)
+	if err != nil {
+		return err
+	}
+	var defaults map[string]any
+	if err := json.Unmarshal(j, &defaults); err != nil {
+		return err
+	}
+
+	settings, err := settingsTable(doc)
+	if err != nil {
+		return err
+	}
+	if _, ok := settings["hotkeys"]; ok {
+		delete(defaults, "hotkeys")
+	}
+	fillMissing(settings, defaults)
+	return nil
+}
+
+// fillHistoryLimit migrates version 1, which kept no snapshots: without it,
+// history_limit would decode to zero and turn them off. Other settings a
+// version 1 file leaves out stay off as before.
+func fillHistoryLimit(doc map[string]any) error {
+	settings, err := settingsTable(doc)
+	if err != nil {
+		return err
+	}
+	if _, ok := settings["history_limit"]; !ok {
+		settings["history_limit"] = DefaultHistoryLimit
+	}
+	return nil
+}
+
+// settingsTable returns the document's settings, adding an empty table if
+// it has none.
+func settingsTable(doc map[string]any) (map[string]any, error) {
+	settings, ok := doc["settings"].(map[string]any)
+	if doc["settings"] != nil && !ok {
+		return nil, fmt.Errorf("settings must be a table, not %v", doc["settings"])
+	}
+	if settings == nil {
+		settings = make(map[string]any)
+		doc["settings"] = settings
+	}
+	return settings, nil
+}
+
+// fillMissing copies the keys of src missing from dst, descending into
+// tables both have.
+func fillMissing(dst, src map[string]any) {
+	for k, v := range src {
+		existing, ok := dst[k]
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		sub, dstIsMap := existing.(map[string]any)
+		subSrc, srcIsMap := v.(map[string]any)
+		if dstIsMap && srcIsMap {
+			fillMissing(sub, subSrc)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadFixture copies testdata/name into a temporary directory, keeping its
+// extension, and loads it.
+func loadFixture(t *testing.T, name string) (*Config, string, []byte) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "expansions"+filepath.Ext(name))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("%s: LoadConfig: %v", name, err)
+	}
+	return cfg, path, data
+}
+
+func TestMigrationsCoverEveryVersion(t *testing.T) {
+	if len(migrations) != CurrentSchemaVersion {
+		t.Fatalf("%d migrations registered for schema version %d", len(migrations), CurrentSchemaVersion)
+	}
+	for v, m := range migrations {
+		if m.apply == nil || m.description == "" {
+			t.Errorf("migration from version %d is incomplete", v)
+		}
+	}
+}
+
+func TestMigrateV0FillsNewSettings(t *testing.T) {
+	cfg, path, original := loadFixture(t, "v0.json")
+	defaults := defaultConfig().Settings
+
+	s := cfg.GetSettings()
+	// Settings the file has are kept...
+	if s.TriggerOnTab || s.LogExpansions || !s.ShowNotifications {
+		t.Errorf("existing settings changed: %+v", s)
+	}
+	// ...and those added since get their defaults instead of zero values.
+	if s.PasteThreshold != defaults.PasteThreshold || s.MaxOutputSize != defaults.MaxOutputSize || !s.ConfirmLargeOutput {
+		t.Errorf("size settings not filled in: %+v", s)
+	}
+	if s.Hotkeys != defaults.Hotkeys || s.Suggestions != defaults.Suggestions {
+		t.Errorf("hotkeys or suggestions not filled in: %+v", s)
+	}
+	if exps := cfg.GetExpansions(); len(exps) != 2 || exps[1].Category != "Python" {
+		t.Errorf("expansions changed: %+v", exps)
+	}
+
+	backup, err := os.ReadFile(path + ".v0.bak")
+	if err != nil {
+		t.Fatalf("expected a backup of the original file: %v", err)
+	}
+	if string(backup) != string(original) {
+		t.Error("backup differs from the original file")
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), `"schema_version": 2`) || !strings.Contains(string(saved), `"paste_threshold": 200`) {
+		t.Errorf("migrated file not saved:\n%s", saved)
+	}
+
+	// Loading the migrated file again changes nothing.
+	if err := os.Remove(path + ".v0.bak"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".v0.bak"); !os.IsNotExist(err) {
+		t.Error("expected no second migration")
+	}
+}
+
+func TestMigrateV0WithoutSettings(t *testing.T) {
+	cfg, path, _ := loadFixture(t, "v0.yaml")
+
+	if got, want := cfg.GetSettings(), defaultConfig().Settings; got != want {
+		t.Errorf("expected default settings, got %+v", got)
+	}
+	if exps := cfg.GetExpansions(); len(exps) != 1 || exps[0].Replacement != "Best regards,\n{NAME}" {
+		t.Errorf("expansions changed: %+v", exps)
+	}
+	if _, err := os.Stat(path + ".v0.bak"); err != nil {
+		t.Errorf("expected a backup: %v", err)
+	}
+}
+
+func TestMigrateV0KeepsClearedHotkeys(t *testing.T) {
+	path := writeConfig(t, "expansions.json", `{"settings": {"hotkeys": {"toggle": "ctrl+alt+t"}}}`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.GetSettings().Hotkeys, (Hotkeys{Toggle: "ctrl+alt+t"}); got != want {
+		t.Errorf("cleared hotkeys were filled in: %+v", got)
+	}
+}
+
+func TestMigrateV1FillsHistoryLimitOnly(t *testing.T) {
+	cfg, path, _ := loadFixture(t, "v1.toml")
+
+	s := cfg.GetSettings()
+	if s.HistoryLimit != DefaultHistoryLimit {
+		t.Errorf("history limit not filled in: %d", s.HistoryLimit)
+	}
+	// Settings a version 1 file leaves out are not version 0 omissions.
+	if s.PasteThreshold != 0 || s.ConfirmLargeOutput || s.Hotkeys.Toggle != "" || s.Hotkeys.OpenManager != "" {
+		t.Errorf("other settings changed: %+v", s)
+	}
+	if _, err := os.Stat(path + ".v1.bak"); err != nil {
+		t.Errorf("expected a backup: %v", err)
+	}
+}
+
+func TestCurrentVersionIsNotMigrated(t *testing.T) {
+	cfg, path, original := loadFixture(t, "v2.toml")
+
+	s := cfg.GetSettings()
+	// Zero values in a current file are deliberate.
+	if s.PasteThreshold != 0 || s.MaxOutputSize != 500 || s.ConfirmLargeOutput || s.Suggestions.Enabled || s.HistoryLimit != 0 {
+		t.Errorf("settings changed: %+v", s)
+	}
+	if s.Hotkeys.Toggle != "" || s.Hotkeys.OpenManager != "" {
+		t.Errorf("hotkeys filled in: %+v", s.Hotkeys)
+	}
+
+	if _, err := os.Stat(path + ".v2.bak"); !os.IsNotExist(err) {
+		t.Error("expected no backup for a current file")
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != string(original) {
+		t.Error("current file was rewritten on load")
+	}
+}
+
+func TestNewerSchemaIsRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expansions.json")
+	data := []byte(`{"schema_version": 99, "expansions": []}`)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadConfig(path); !errors.Is(err, ErrNewerSchema) {
+		t.Fatalf("expected ErrNewerSchema, got %v", err)
+	}
+	if _, err := ReadConfig(path); !errors.Is(err, ErrNewerSchema) {
+		t.Fatalf("expected ErrNewerSchema from ReadConfig, got %v", err)
+	}
+	if saved, _ := os.ReadFile(path); string(saved) != string(data) {
+		t.Error("newer file was modified")
+	}
+}
+
+func TestReadConfigMigratesInMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expansions.json")
+	data, err := os.ReadFile(filepath.Join("testdata", "v0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetSettings().PasteThreshold != defaultConfig().Settings.PasteThreshold {
+		t.Error("expected ReadConfig to migrate")
+	}
+	if saved, _ := os.ReadFile(path); string(saved) != string(data) {
+		t.Error("ReadConfig modified the file")
+	}
+	if _, err := os.Stat(path + ".v0.bak"); !os.IsNotExist(err) {
+		t.Error("ReadConfig wrote a backup")
+	}
+}
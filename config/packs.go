@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// packsDirName is the directory, next to the configuration file, that holds
+// snippet packs.
+const packsDirName = "packs"
+
+// Pack is a set of expansions shipped in its own file under the packs
+// directory, so collections can be shared and switched on and off as a unit.
+type Pack struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Namespace is put in front of every trigger in the pack, so a pack
+	// with namespace ";py." and trigger "def" expands ";py.def".
+	Namespace  string      `json:"namespace,omitempty"`
+	Enabled    bool        `json:"enabled"`
+	Expansions []Expansion `json:"expansions"`
+
+	// File is the pack's file name within the packs directory.
+	File string `json:"-"`
+}
+
+// LoadPack reads a pack file. Packs are enabled unless they say otherwise,
+// and a pack without a name is named after its file.
+func LoadPack(path string) (Pack, error) {
+	format, err := FormatForPath(path)
+	if err != nil {
+		return Pack{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Pack{}, err
+	}
+	p := Pack{Enabled: true}
+	if err := unmarshal(data, format, &p); err != nil {
+		return Pack{}, fmt.Errorf("pack %s: %w", filepath.Base(path), err)
+	}
+	p.File = filepath.Base(path)
+	if strings.TrimSpace(p.Name) == "" {
+		p.Name = strings.TrimSuffix(p.File, filepath.Ext(p.File))
+	}
+	return p, nil
+}
+
+// namespaced returns the pack's expansions with their triggers namespaced
+// and their Pack set. Pack files are not written back, so an expansion
+// without an ID is given one made from the pack's file and its name.
+func (p Pack) namespaced() []Expansion {
+	exps := make([]Expansion, len(p.Expansions))
+	for i, exp := range p.Expansions {
+		if exp.ID == "" {
+			exp.ID = "pack:" + p.File + ":" + exp.Name()
+		}
+		if exp.Trigger != "" {
+			exp.Trigger = p.Namespace + exp.Trigger
+		}
+		exp.Pack = p.Name
+		exps[i] = exp
+	}
+	return exps
+}
+
+// PacksDir returns the directory snippet packs are loaded from.
+func (c *Config) PacksDir() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.packsDirLocked()
+}
+
+func (c *Config) packsDirLocked() string {
+	if c.filePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(c.filePath), packsDirName)
+}
+
+// loadPacks reads every JSON, YAML and TOML file in the packs directory in
+// name order, which is also their order of precedence. A pack switched on or
+// off in the settings takes that state over its own. A missing directory
+// means no packs; packs that cannot be read are skipped and reported by
+// PackConflicts.
+func (c *Config) loadPacks() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.packs, c.packErrors = nil, nil
+	dir := c.packsDirLocked()
+	if dir == "" {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*"))
+	sort.Strings(paths)
+	for _, path := range paths {
+		if _, err := FormatForPath(path); err != nil {
+			continue
+		}
+		p, err := LoadPack(path)
+		if err != nil {
+			c.packErrors = append(c.packErrors, err)
+			continue
+		}
+		if on, ok := c.PackStates[p.File]; ok {
+			p.Enabled = on
+		}
+		c.packs = append(c.packs, p)
+	}
+}
+
+// Packs returns the loaded packs, enabled or not, in order of precedence.
+func (c *Config) Packs() []Pack {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	packs := make([]Pack, len(c.packs))
+	copy(packs, c.packs)
+	return packs
+}
+
+// SetPackEnabled switches the pack loaded from file on or off and saves the
+// configuration to keep the choice. The pack file is left as it is.
+func (c *Config) SetPackEnabled(file string, enabled bool) error {
+	c.mu.Lock()
+	found := false
+	for i := range c.packs {
+		if c.packs[i].File == file {
+			c.packs[i].Enabled = enabled
+			found = true
+		}
+	}
+	if found {
+		states := make(map[string]bool, len(c.PackStates)+1)
+		for f, on := range c.PackStates {
+			states[f] = on
+		}
+		states[file] = enabled
+		c.PackStates = states
+	}
+	c.mu.Unlock()
+
+	if !found {
+		return fmt.Errorf("pack %q not found", file)
+	}
+	return c.Save()
+}
+
+// AllExpansions returns the expansions in effect: the user's own, followed by
+// those of the enabled packs with their triggers namespaced. The user's
+// expansions take precedence over packs, and earlier packs over later ones;
+// a pack expansion whose trigger is already taken is left out and reported
+// by PackConflicts.
+func (c *Config) AllExpansions() []Expansion {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	exps, _ := c.mergePacksLocked()
+	return exps
+}
+
+// PackConflicts reports packs that could not be loaded and pack expansions
+// left out because their trigger is already taken.
+func (c *Config) PackConflicts() []error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, conflicts := c.mergePacksLocked()
+	return append(append([]error(nil), c.packErrors...), conflicts...)
+}
+
+// mergePacksLocked implements AllExpansions and PackConflicts. c.mu must be
+// held by the caller. Triggers are compared ignoring case, since one that
+// is not case sensitive expands whatever case it is typed in.
+func (c *Config) mergePacksLocked() ([]Expansion, []error) {
+	exps := append([]Expansion(nil), c.Expansions...)
+
+	owners := make(map[string]string, len(exps))
+	for _, exp := range c.Expansions {
+		if exp.Trigger != "" {
+			owners[strings.ToLower(exp.Trigger)] = "your configuration"
+		}
+	}
+
+	var conflicts []error
+	for _, p := range c.packs {
+		if !p.Enabled {
+			continue
+		}
+		for _, exp := range p.namespaced() {
+			if exp.Trigger != "" {
+				key := strings.ToLower(exp.Trigger)
+				if owner, taken := owners[key]; taken {
+					conflicts = append(conflicts, fmt.Errorf("pack %q: trigger %q is already defined in %s", p.Name, exp.Trigger, owner))
+					continue
+				}
+				owners[key] = fmt.Sprintf("pack %q", p.Name)
+			}
+			exps = append(exps, exp)
+		}
+	}
+	return exps, conflicts
+}
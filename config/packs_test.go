@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writePack writes a pack file into the packs directory next to cfgPath.
+func writePack(t *testing.T, cfgPath, name, content string) {
+	t.Helper()
+
+	dir := filepath.Join(filepath.Dir(cfgPath), packsDirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPacksAreMergedWithPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expansions.json")
+	if err := os.WriteFile(path, []byte(`{"expansions": [{"trigger": ";py.def", "replacement": "mine"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writePack(t, path, "a-python.json", `{
+		"name": "Python", "version": "1.0", "namespace": ";py.",
+		"expansions": [
+			{"trigger": "def", "replacement": "def ():"},
+			{"trigger": "main", "replacement": "if __name__ == '__main__':"}
+		]
+	}`)
+	writePack(t, path, "b-more.json", `{
+		"name": "More", "namespace": ";py.",
+		"expansions": [{"trigger": "main", "replacement": "other"}, {"trigger": "cls", "replacement": "class"}]
+	}`)
+	writePack(t, path, "c-off.json", `{"name": "Off", "enabled": false, "expansions": [{"trigger": ";off", "replacement": "x"}]}`)
+	writePack(t, path, "d-broken.json", `{"name": `)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]Expansion)
+	for _, exp := range cfg.AllExpansions() {
+		got[exp.Trigger] = exp
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 expansions in effect, got %v", got)
+	}
+	if exp := got[";py.def"]; exp.Replacement != "mine" || exp.Pack != "" {
+		t.Errorf("the user's expansion must win over packs, got %+v", exp)
+	}
+	if exp := got[";py.main"]; exp.Pack != "Python" {
+		t.Errorf("the earlier pack must win, got %+v", exp)
+	}
+	if exp := got[";py.cls"]; exp.Pack != "More" {
+		t.Errorf("expected ;py.cls from More, got %+v", exp)
+	}
+	if len(cfg.GetExpansions()) != 1 {
+		t.Errorf("pack expansions must not become user expansions")
+	}
+
+	conflicts := cfg.PackConflicts()
+	if len(conflicts) != 3 {
+		t.Fatalf("expected a load error and 2 conflicts, got %v", conflicts)
+	}
+	if !strings.Contains(conflicts[0].Error(), "d-broken.json") {
+		t.Errorf("expected the broken pack first, got %v", conflicts[0])
+	}
+}
+
+func TestSetPackEnabledPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expansions.json")
+	const pack = `{"expansions": [{"trigger": ";hey", "replacement": "Hey!"}]}`
+	writePack(t, path, "greetings.json", pack)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packs := cfg.Packs()
+	if len(packs) != 1 || packs[0].Name != "greetings" || !packs[0].Enabled {
+		t.Fatalf("unexpected packs: %+v", packs)
+	}
+	n := len(cfg.AllExpansions())
+
+	if err := cfg.SetPackEnabled("greetings.json", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(cfg.AllExpansions()); got != n-1 {
+		t.Fatalf("expected the disabled pack to be dropped, got %d expansions, want %d", got, n-1)
+	}
+
+	reloaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := reloaded.Packs(); len(p) != 1 || p[0].Enabled {
+		t.Fatalf("expected the pack to stay disabled, got %+v", p)
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), packsDirName, "greetings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != pack {
+		t.Errorf("the pack file must not be rewritten, got %s", data)
+	}
+}
+
+func TestPackConflictsIgnoreCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expansions.json")
+	if err := os.WriteFile(path, []byte(`{"expansions": [{"trigger": ";sig", "replacement": "mine"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writePack(t, path, "sigs.json", `{"expansions": [{"trigger": ";SIG", "replacement": "theirs"}]}`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(cfg.AllExpansions()); got != 1 {
+		t.Errorf("expected the pack's ;SIG to be left out, got %d expansions", got)
+	}
+	if conflicts := cfg.PackConflicts(); len(conflicts) != 1 {
+		t.Errorf("expected one conflict, got %v", conflicts)
+	}
+}
+
+func TestWatchSeesNewPacks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expansions.json")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan struct{}, 16)
+	stop, err := cfg.Watch(func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	writePack(t, path, "new.json", `{"expansions": []}`)
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a new pack to be reported")
+	}
+}
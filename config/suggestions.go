@@ -0,0 +1,36 @@
+package config
+
+// Suggestions configures the inline suggestions shown while a trigger is
+// being typed.
+type Suggestions struct {
+	Enabled bool `json:"enabled"`
+	// MinPrefixLength is how many characters of a trigger, counting the
+	// prefix character, must be typed before suggestions appear. Zero uses
+	// DefaultSuggestionMinPrefix.
+	MinPrefixLength int `json:"min_prefix_length,omitempty"`
+	// PrefixChar starts a partial trigger; empty uses
+	// DefaultSuggestionPrefix.
+	PrefixChar string `json:"prefix_char,omitempty"`
+}
+
+// Defaults used when the corresponding Suggestions field is not set.
+const (
+	DefaultSuggestionMinPrefix = 3
+	DefaultSuggestionPrefix    = ";"
+)
+
+// MinPrefix returns the minimum partial trigger length.
+func (s Suggestions) MinPrefix() int {
+	if s.MinPrefixLength > 0 {
+		return s.MinPrefixLength
+	}
+	return DefaultSuggestionMinPrefix
+}
+
+// Prefix returns the character that starts a partial trigger.
+func (s Suggestions) Prefix() string {
+	if s.PrefixChar != "" {
+		return s.PrefixChar
+	}
+	return DefaultSuggestionPrefix
+}
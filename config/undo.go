@@ -0,0 +1,324 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// undoLimit is the number of steps an UndoStack keeps.
+	undoLimit = 100
+	// settingsMergeWindow is how close together settings changes must be to
+	// be undone as one step, so typing a number is not undone digit by
+	// digit.
+	settingsMergeWindow = 2 * time.Second
+)
+
+// Command is a change to a configuration that can be taken back. Apply and
+// Revert leave the configuration unchanged when they fail, and neither
+// saves.
+type Command interface {
+	Apply(c *Config) error
+	Revert(c *Config) error
+	// String describes the change, as in "Delete ;sig".
+	String() string
+}
+
+// merger is implemented by commands that can absorb the command done after
+// them into a single step.
+type merger interface {
+	merge(next Command) bool
+}
+
+// UndoStack applies commands to a configuration and saves it after each
+// one, keeping them so they can be undone and redone. Each step is saved
+// on its own: if saving fails the step is taken back, so the configuration
+// in memory always matches the file.
+type UndoStack struct {
+	cfg *Config
+
+	mu         sync.Mutex
+	undo, redo []Command
+}
+
+// NewUndoStack returns an empty undo stack for cfg.
+func NewUndoStack(cfg *Config) *UndoStack {
+	return &UndoStack{cfg: cfg}
+}
+
+// Do applies cmd, saves, and makes it the step to undo.
+func (s *UndoStack) Do(cmd Command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := cmd.Apply(s.cfg); err != nil {
+		return err
+	}
+	err := s.save(cmd.Revert)
+	if err != nil && !errors.Is(err, ErrSnapshot) {
+		return err
+	}
+	s.redo = nil
+	if n := len(s.undo); n > 0 {
+		if m, ok := s.undo[n-1].(merger); ok && m.merge(cmd) {
+			return err
+		}
+	}
+	s.undo = append(s.undo, cmd)
+	if len(s.undo) > undoLimit {
+		s.undo = s.undo[len(s.undo)-undoLimit:]
+	}
+	return err
+}
+
+// Undo takes back the last step and saves, returning its description. A
+// step that can no longer be taken back, because the configuration was
+// changed some other way, is dropped.
+func (s *UndoStack) Undo() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.undo) == 0 {
+		return "", errors.New("nothing to undo")
+	}
+	cmd := s.undo[len(s.undo)-1]
+	if err := cmd.Revert(s.cfg); err != nil {
+		s.undo = s.undo[:len(s.undo)-1]
+		return cmd.String(), fmt.Errorf("cannot undo %s: %w", cmd, err)
+	}
+	err := s.save(cmd.Apply)
+	if err != nil && !errors.Is(err, ErrSnapshot) {
+		return cmd.String(), err
+	}
+	s.undo = s.undo[:len(s.undo)-1]
+	s.redo = append(s.redo, cmd)
+	return cmd.String(), err
+}
+
+// Redo applies the last undone step again and saves, returning its
+// description.
+func (s *UndoStack) Redo() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.redo) == 0 {
+		return "", errors.New("nothing to redo")
+	}
+	cmd := s.redo[len(s.redo)-1]
+	if err := cmd.Apply(s.cfg); err != nil {
+		s.redo = s.redo[:len(s.redo)-1]
+		return cmd.String(), fmt.Errorf("cannot redo %s: %w", cmd, err)
+	}
+	err := s.save(cmd.Revert)
+	if err != nil && !errors.Is(err, ErrSnapshot) {
+		return cmd.String(), err
+	}
+	s.redo = s.redo[:len(s.redo)-1]
+	s.undo = append(s.undo, cmd)
+	return cmd.String(), err
+}
+
+// save saves the configuration, calling rollback to take the step back if
+// it could not be written. An error wrapping ErrSnapshot means it was.
+func (s *UndoStack) save(rollback func(*Config) error) error {
+	err := s.cfg.Save()
+	if err != nil && !errors.Is(err, ErrSnapshot) {
+		_ = rollback(s.cfg)
+	}
+	return err
+}
+
+// CanUndo reports whether there is a step to undo, and describes it.
+func (s *UndoStack) CanUndo() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.undo) == 0 {
+		return "", false
+	}
+	return s.undo[len(s.undo)-1].String(), true
+}
+
+// CanRedo reports whether there is a step to redo, and describes it.
+func (s *UndoStack) CanRedo() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.redo) == 0 {
+		return "", false
+	}
+	return s.redo[len(s.redo)-1].String(), true
+}
+
+// Clear forgets every step, for when the configuration was replaced in a
+// way the stack cannot take back.
+func (s *UndoStack) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.undo, s.redo = nil, nil
+}
+
+// AddExpansionCommand adds exp to the user's expansions. It is given an ID
+// now if it has none, so redoing the step adds the same expansion.
+func AddExpansionCommand(exp Expansion) Command {
+	if exp.ID == "" {
+		exp.ID = NewExpansionID()
+	}
+	return &addExpansion{exp: exp}
+}
+
+type addExpansion struct {
+	exp Expansion
+}
+
+func (a *addExpansion) Apply(c *Config) error  { return c.AddExpansion(a.exp) }
+func (a *addExpansion) Revert(c *Config) error { return c.RemoveExpansionByID(a.exp.ID) }
+func (a *addExpansion) String() string         { return "Add " + a.exp.Name() }
+
+// EditExpansionCommand replaces the expansion old with updated, in place.
+// The expansion keeps old's ID.
+func EditExpansionCommand(old, updated Expansion) Command {
+	updated.ID = old.ID
+	return &editExpansion{old: old, updated: updated}
+}
+
+type editExpansion struct {
+	old, updated Expansion
+}
+
+func (e *editExpansion) Apply(c *Config) error  { return c.UpdateExpansion(e.old.ID, e.updated) }
+func (e *editExpansion) Revert(c *Config) error { return c.UpdateExpansion(e.old.ID, e.old) }
+func (e *editExpansion) String() string         { return "Edit " + e.updated.Name() }
+
+// DeleteExpansionCommand removes the expansion with the given ID. Undoing it
+// puts the expansion back where it was.
+func DeleteExpansionCommand(id string) Command {
+	return &deleteExpansion{id: id}
+}
+
+type deleteExpansion struct {
+	id string
+	// exp and index are what Apply removed.
+	exp   Expansion
+	index int
+}
+
+func (d *deleteExpansion) Apply(c *Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := c.indexLocked(d.id)
+	if i < 0 {
+		return fmt.Errorf("expansion %s not found", d.id)
+	}
+	d.exp, d.index = c.Expansions[i], i
+	c.Expansions = append(c.Expansions[:i], c.Expansions[i+1:]...)
+	return nil
+}
+
+func (d *deleteExpansion) Revert(c *Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkExpansionLocked(d.exp, -1); err != nil {
+		return err
+	}
+	i := min(d.index, len(c.Expansions))
+	c.Expansions = append(c.Expansions[:i], append([]Expansion{d.exp}, c.Expansions[i:]...)...)
+	return nil
+}
+
+func (d *deleteExpansion) String() string {
+	if d.exp.ID == "" {
+		return "Delete expansion"
+	}
+	return "Delete " + d.exp.Name()
+}
+
+// SetVariableCommand sets the custom variable key to value.
+func SetVariableCommand(key, value string) Command {
+	return &setVariable{key: key, value: value}
+}
+
+// DeleteVariableCommand removes the custom variable key.
+func DeleteVariableCommand(key string) Command {
+	return &setVariable{key: key, remove: true}
+}
+
+type setVariable struct {
+	key, value string
+	remove     bool
+	// prev and existed are what Apply replaced.
+	prev    string
+	existed bool
+}
+
+func (v *setVariable) Apply(c *Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v.prev, v.existed = c.CustomVariables[v.key]
+	if v.remove {
+		if !v.existed {
+			return fmt.Errorf("variable %q not found", v.key)
+		}
+		delete(c.CustomVariables, v.key)
+		return nil
+	}
+	if c.CustomVariables == nil {
+		c.CustomVariables = make(map[string]string)
+	}
+	c.CustomVariables[v.key] = v.value
+	return nil
+}
+
+func (v *setVariable) Revert(c *Config) error {
+	if v.existed {
+		c.SetCustomVar(v.key, v.prev)
+	} else {
+		c.DeleteCustomVar(v.key)
+	}
+	return nil
+}
+
+func (v *setVariable) String() string {
+	if v.remove {
+		return "Delete {" + v.key + "}"
+	}
+	return "Set {" + v.key + "}"
+}
+
+// SettingsCommand replaces the settings. Settings changes made in quick
+// succession are undone together.
+func SettingsCommand(s Settings) Command {
+	return &changeSettings{after: s, at: timeNow()}
+}
+
+type changeSettings struct {
+	before, after Settings
+	at            time.Time
+}
+
+func (s *changeSettings) Apply(c *Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.before = c.Settings
+	c.Settings = s.after
+	return nil
+}
+
+func (s *changeSettings) Revert(c *Config) error {
+	c.UpdateSettings(s.before)
+	return nil
+}
+
+func (s *changeSettings) String() string { return "Change settings" }
+
+func (s *changeSettings) merge(next Command) bool {
+	n, ok := next.(*changeSettings)
+	if !ok || n.at.Sub(s.at) > settingsMergeWindow {
+		return false
+	}
+	s.after, s.at = n.after, n.at
+	return true
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// names lists the expansion names of cfg, in order.
+func names(cfg *Config) []string {
+	var out []string
+	for _, exp := range cfg.GetExpansions() {
+		out = append(out, exp.Name())
+	}
+	return out
+}
+
+// onDisk reads back the configuration cfg last saved.
+func onDisk(t *testing.T, cfg *Config) *Config {
+	t.Helper()
+	saved, err := ReadConfig(cfg.ConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return saved
+}
+
+func TestUndoRedoExpansions(t *testing.T) {
+	cfg := newHistoryConfig(t, 0)
+	stack := NewUndoStack(cfg)
+	before := names(cfg)
+	exps := cfg.GetExpansions()
+
+	steps := []Command{
+		AddExpansionCommand(Expansion{Trigger: ";new", Replacement: "new"}),
+		EditExpansionCommand(exps[1], Expansion{Trigger: ";today", Replacement: "{DATE}"}),
+		DeleteExpansionCommand(exps[0].ID),
+	}
+	for _, cmd := range steps {
+		if err := stack.Do(cmd); err != nil {
+			t.Fatalf("%s: %v", cmd, err)
+		}
+	}
+	want := []string{";today", ";sig", ";shrug", ";new"}
+	if got := names(onDisk(t, cfg)); !slices.Equal(got, want) {
+		t.Fatalf("after the steps, saved %v, want %v", got, want)
+	}
+
+	for i := len(steps) - 1; i >= 0; i-- {
+		name, err := stack.Undo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != steps[i].String() {
+			t.Errorf("undid %q, want %q", name, steps[i])
+		}
+	}
+	// Deleted and edited expansions go back where they were.
+	if got := names(onDisk(t, cfg)); !slices.Equal(got, before) {
+		t.Fatalf("after undoing everything, saved %v, want %v", got, before)
+	}
+	if _, ok := stack.CanUndo(); ok {
+		t.Error("expected nothing left to undo")
+	}
+
+	for range steps {
+		if _, err := stack.Redo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := names(onDisk(t, cfg)); !slices.Equal(got, want) {
+		t.Fatalf("after redoing everything, saved %v, want %v", got, want)
+	}
+	if exp, ok := onDisk(t, cfg).ExpansionByID(exps[1].ID); !ok || exp.Trigger != ";today" {
+		t.Errorf("expected the edited expansion to keep its ID, got %+v", exp)
+	}
+}
+
+func TestNewStepClearsRedo(t *testing.T) {
+	cfg := newHistoryConfig(t, 0)
+	stack := NewUndoStack(cfg)
+	if err := stack.Do(SetVariableCommand("NAME", "Ada")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stack.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if got := onDisk(t, cfg).GetCustomVars()["NAME"]; got != "John Doe" {
+		t.Errorf("variable not restored: %q", got)
+	}
+	if desc, ok := stack.CanRedo(); !ok || desc != "Set {NAME}" {
+		t.Errorf("expected to redo Set {NAME}, got %q %v", desc, ok)
+	}
+
+	if err := stack.Do(DeleteVariableCommand("COMPANY")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stack.CanRedo(); ok {
+		t.Error("expected a new step to clear redo")
+	}
+	if _, err := stack.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if got := onDisk(t, cfg).GetCustomVars()["COMPANY"]; got != "Acme Corp" {
+		t.Errorf("deleted variable not restored: %q", got)
+	}
+}
+
+func TestRejectedStepChangesNothing(t *testing.T) {
+	cfg := newHistoryConfig(t, 0)
+	stack := NewUndoStack(cfg)
+	before := names(cfg)
+
+	if err := stack.Do(AddExpansionCommand(Expansion{Trigger: ";sig", Replacement: "dup"})); err == nil {
+		t.Fatal("expected a duplicate trigger to be rejected")
+	}
+	email := cfg.GetExpansions()[0]
+	edited := email
+	edited.Trigger = ";date"
+	if err := stack.Do(EditExpansionCommand(email, edited)); err == nil {
+		t.Fatal("expected an edit onto an existing trigger to be rejected")
+	}
+	if got := names(cfg); !slices.Equal(got, before) {
+		t.Errorf("expansions changed: %v", got)
+	}
+	if _, ok := stack.CanUndo(); ok {
+		t.Error("rejected steps must not be undoable")
+	}
+}
+
+func TestFailedSaveTakesStepBack(t *testing.T) {
+	cfg := newHistoryConfig(t, 0)
+	stack := NewUndoStack(cfg)
+	// Saving into a directory that does not exist fails.
+	cfg.filePath = filepath.Join(t.TempDir(), "missing", "expansions.json")
+
+	if err := stack.Do(DeleteExpansionCommand(cfg.GetExpansions()[0].ID)); err == nil {
+		t.Fatal("expected the save to fail")
+	}
+	if names(cfg)[0] != ";email" {
+		t.Error("expected the unsaved step to be taken back")
+	}
+	if _, ok := stack.CanUndo(); ok {
+		t.Error("unsaved steps must not be undoable")
+	}
+}
+
+func TestSettingsChangesMerge(t *testing.T) {
+	now := fakeClock(t)
+	cfg := newHistoryConfig(t, 0)
+	stack := NewUndoStack(cfg)
+	original := cfg.GetSettings().PasteThreshold
+
+	// Typing "250" saves after each digit.
+	for _, n := range []int{2, 25, 250} {
+		s := cfg.GetSettings()
+		s.PasteThreshold = n
+		if err := stack.Do(SettingsCommand(s)); err != nil {
+			t.Fatal(err)
+		}
+		*now = now.Add(300 * time.Millisecond)
+	}
+	*now = now.Add(time.Minute)
+	s := cfg.GetSettings()
+	s.LogExpansions = !s.LogExpansions
+	if err := stack.Do(SettingsCommand(s)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stack.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetSettings(); got.PasteThreshold != 250 || got.LogExpansions == s.LogExpansions {
+		t.Fatalf("expected only the later change undone, got %+v", got)
+	}
+	if _, err := stack.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if got := onDisk(t, cfg).GetSettings().PasteThreshold; got != original {
+		t.Errorf("expected the typed number undone in one step, got %d", got)
+	}
+}
+
+func TestUndoDropsStaleStep(t *testing.T) {
+	cfg := newHistoryConfig(t, 0)
+	stack := NewUndoStack(cfg)
+	if err := stack.Do(AddExpansionCommand(Expansion{Trigger: ";new", Replacement: "new"})); err != nil {
+		t.Fatal(err)
+	}
+	// Changed behind the stack's back, as by an import or a restore.
+	if err := cfg.RemoveExpansion(";new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stack.Undo(); err == nil {
+		t.Fatal("expected the stale step to fail")
+	}
+	if _, ok := stack.CanUndo(); ok {
+		t.Error("expected the stale step to be dropped")
+	}
+}
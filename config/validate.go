@@ -0,0 +1,254 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ParseError is a configuration file that cannot be decoded, with the
+// position of the problem where the decoder reports one.
+type ParseError struct {
+	Path   string
+	Line   int // 1-based; 0 when unknown
+	Column int // 1-based; 0 when unknown
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+	default:
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// yamlLine finds the line number yaml.v3 puts in its messages.
+var yamlLine = regexp.MustCompile(`^yaml: (?:unmarshal errors:\n\s*)?line (\d+): `)
+
+// newParseError locates err, returned while decoding data, in the file.
+func newParseError(path string, data []byte, err error) *ParseError {
+	pe := &ParseError{Path: path, Err: err}
+
+	var (
+		syntax *json.SyntaxError
+		typ    *json.UnmarshalTypeError
+		tomlE  toml.ParseError
+	)
+	switch {
+	case errors.As(err, &syntax):
+		pe.Line, pe.Column = position(data, syntax.Offset)
+	case errors.As(err, &typ):
+		pe.Err = fmt.Errorf("%s: cannot use a %s here, expected %s", typ.Field, typ.Value, typ.Type)
+		pe.Line, pe.Column = position(data, typ.Offset)
+	case errors.As(err, &tomlE):
+		pe.Line, pe.Column = tomlE.Position.Line, tomlE.Position.Col
+		pe.Err = errors.New(tomlE.Message)
+	default:
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			pe.Line, _ = strconv.Atoi(m[1])
+			pe.Err = errors.New(err.Error()[len(m[0]):])
+		}
+	}
+	return pe
+}
+
+// position converts the offset JSON decoders report, just past the
+// offending byte, into a 1-based line and column.
+func position(data []byte, offset int64) (line, col int) {
+	if data == nil {
+		return 0, 0
+	}
+	i := int(offset) - 1
+	if i > len(data) {
+		i = len(data)
+	}
+	if i < 0 {
+		i = 0
+	}
+	before := data[:i]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = i - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// Problem is a field that fails validation.
+type Problem struct {
+	Field   string // e.g. `expansions[3] ";sig"` or "settings.paste_threshold"
+	Message string
+	// Warning problems are reported but do not stop the configuration
+	// from loading.
+	Warning bool
+}
+
+func (p Problem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+// ValidationError lists the problems that stop a configuration from
+// loading.
+type ValidationError struct {
+	Path     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.String()
+	}
+	if len(msgs) == 1 {
+		return fmt.Sprintf("%s: %s", e.Path, msgs[0])
+	}
+	return fmt.Sprintf("%s: %d problems: %s", e.Path, len(msgs), strings.Join(msgs, "; "))
+}
+
+// builtinVariables are the template variables the expander always knows.
+var builtinVariables = map[string]bool{"DATE": true, "TIME": true, "DATETIME": true, "CLIPBOARD": true, "CURSOR": true}
+
+// variableToken matches what looks like a variable: an upper-case name in
+// braces. Other braces, as in code snippets, are ordinary text.
+var variableToken = regexp.MustCompile(`\{([A-Z][A-Z0-9_]*)\}`)
+
+// cursorMarkers counts the {CURSOR} markers in replacement. Tokens are read
+// the way the template processor reads them, from a brace to the next
+// closing one, so a {{} escape is never taken for part of a marker.
+func cursorMarkers(replacement string) int {
+	n := 0
+	for rest := replacement; ; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			return n
+		}
+		end := strings.IndexByte(rest[open+1:], '}')
+		if end < 0 {
+			return n
+		}
+		if strings.EqualFold(rest[open+1:open+1+end], "CURSOR") {
+			n++
+		}
+		rest = rest[open+1+end+1:]
+	}
+}
+
+// Validate checks the user's expansions and settings. Problems that are not
+// warnings make LoadConfig reject the file.
+func (c *Config) Validate() []Problem {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var problems []Problem
+	add := func(warning bool, field, format string, args ...any) {
+		problems = append(problems, Problem{field, fmt.Sprintf(format, args...), warning})
+	}
+
+	triggers := make(map[string]int)
+	for i, exp := range c.Expansions {
+		field := fmt.Sprintf("expansions[%d]", i)
+		if name := exp.Name(); name != "" {
+			field += " " + strconv.Quote(name)
+		}
+
+		if strings.TrimSpace(exp.Trigger) == "" && strings.TrimSpace(exp.Hotkey) == "" {
+			add(false, field, "needs a trigger or a hotkey")
+		}
+		if exp.Trigger != "" {
+			if first, dup := triggers[exp.Trigger]; dup {
+				add(true, field, "trigger is already used by expansions[%d], which is expanded instead", first)
+			} else {
+				triggers[exp.Trigger] = i
+			}
+		}
+		switch exp.OutputFormat {
+		case "", OutputFormatText, OutputFormatMarkdown, OutputFormatHTML:
+		default:
+			add(false, field, "unknown output_format %q; use text, markdown or html", exp.OutputFormat)
+		}
+		switch exp.InjectMode {
+		case "", InjectModeType, InjectModePaste:
+		default:
+			add(false, field, "unknown inject_mode %q; use type or paste", exp.InjectMode)
+		}
+
+		if exp.Replacement == "" {
+			add(true, field, "replacement is empty")
+		}
+		if n := cursorMarkers(exp.Replacement); n > 1 {
+			add(false, field, "replacement has %d {CURSOR} markers; only one is allowed", n)
+		}
+		for _, m := range variableToken.FindAllStringSubmatch(exp.Replacement, -1) {
+			if _, custom := c.CustomVariables[m[1]]; !builtinVariables[m[1]] && !custom {
+				add(true, field, "unknown variable {%s} will be typed as written", m[1])
+			}
+		}
+	}
+
+	s := c.Settings
+	if s.PasteThreshold < 0 {
+		add(false, "settings.paste_threshold", "must not be negative")
+	}
+	if s.MaxOutputSize < 0 {
+		add(false, "settings.max_output_size", "must not be negative")
+	}
+	if s.HistoryLimit < 0 {
+		add(false, "settings.history_limit", "must not be negative")
+	}
+	if s.Suggestions.MinPrefixLength < 0 {
+		add(false, "settings.suggestions.min_prefix_length", "must not be negative")
+	}
+	if err := s.Hotkeys.Validate(); err != nil {
+		add(true, "settings.hotkeys", "%v; the hotkey is ignored", err)
+	}
+
+	for i, p := range c.Profiles {
+		field := fmt.Sprintf("profiles[%d] %q", i, p.Name)
+		switch p.InjectMode {
+		case "", InjectModeType, InjectModePaste:
+		default:
+			add(false, field, "unknown inject_mode %q; use type or paste", p.InjectMode)
+		}
+		switch p.DeleteMode {
+		case "", DeleteModeBackspace, DeleteModeSelect:
+		default:
+			add(false, field, "unknown delete_mode %q; use backspace or select", p.DeleteMode)
+		}
+	}
+	return problems
+}
+
+// Warnings returns the validation warnings found when the configuration
+// was loaded.
+func (c *Config) Warnings() []Problem {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Problem(nil), c.warnings...)
+}
+
+// check validates a freshly decoded configuration, keeping its
+// warnings and returning its errors as a *ValidationError.
+func (c *Config) check(path string) error {
+	var errs []Problem
+	c.warnings = nil
+	for _, p := range c.Validate() {
+		if p.Warning {
+			c.warnings = append(c.warnings, p)
+		} else {
+			errs = append(errs, p)
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Path: path, Problems: errs}
+	}
+	return nil
+}
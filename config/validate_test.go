@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes data to a file called name in a temporary directory.
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		name, data string
+		line, col  int
+	}{
+		{"expansions.json", "{\n  \"expansions\": [\n    {\"trigger\": \";a\" \"replacement\": \"x\"}\n  ]\n}\n", 3, 22},
+		{"expansions.json", "{\n  \"expansions\": [],\n}\n", 3, 1},
+		{"expansions.json", "{\n  \"settings\": {\n    \"paste_threshold\": \"lots\"\n  }\n}\n", 3, 29},
+		{"expansions.yaml", "expansions:\n  - trigger: ;a\n    replacement: x\n  bad\n", 4, 0},
+		{"expansions.yaml", "expansions: []\nsettings: {}\nexpansions: []\n", 3, 0},
+		{"expansions.toml", "[[expansions]]\ntrigger = \";a\"\nreplacement = x\n", 3, 15},
+	}
+	for _, tt := range tests {
+		path := writeConfig(t, tt.name, tt.data)
+		_, err := LoadConfig(path)
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: expected a ParseError, got %v", tt.name, err)
+			continue
+		}
+		if pe.Line != tt.line || pe.Column != tt.col {
+			t.Errorf("%s: got %d:%d, want %d:%d (%v)", tt.name, pe.Line, pe.Column, tt.line, tt.col, err)
+		}
+		if !strings.HasPrefix(err.Error(), path+":") {
+			t.Errorf("%s: error does not start with the path: %v", tt.name, err)
+		}
+		if saved, _ := os.ReadFile(path); string(saved) != tt.data {
+			t.Errorf("%s: broken file was modified", tt.name)
+		}
+		if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no backup", tt.name)
+		}
+	}
+}
+
+func TestTypeErrorNamesField(t *testing.T) {
+	path := writeConfig(t, "expansions.json", `{"settings": {"paste_threshold": "lots"}}`)
+	_, err := ReadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "settings.paste_threshold: cannot use a string here") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	path := writeConfig(t, "expansions.json", `{
+  "schema_version": 2,
+  "expansions": [
+    {"trigger": ";a", "replacement": "one"},
+    {"trigger": "", "replacement": "orphan"},
+    {"trigger": ";a", "replacement": "two"},
+    {"trigger": ";c", "replacement": "{CURSOR}x{cursor}"},
+    {"trigger": ";d", "replacement": "x", "output_format": "rtf"}
+  ],
+  "settings": {"paste_threshold": -1}
+}`)
+	cfg, err := LoadConfig(path)
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if cfg == nil || len(cfg.GetExpansions()) != 5 {
+		t.Fatalf("expected the configuration to be returned for repair, got %+v", cfg)
+	}
+	want := []string{
+		"expansions[1]: needs a trigger or a hotkey",
+		`expansions[3] ";c": replacement has 2 {CURSOR} markers`,
+		`expansions[4] ";d": unknown output_format "rtf"`,
+		"settings.paste_threshold: must not be negative",
+	}
+	if len(ve.Problems) != len(want) {
+		t.Fatalf("got %d problems, want %d: %v", len(ve.Problems), len(want), err)
+	}
+	for i, w := range want {
+		if got := ve.Problems[i].String(); !strings.HasPrefix(got, w) {
+			t.Errorf("problem %d: got %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestValidationWarningsDoNotStopLoading(t *testing.T) {
+	path := writeConfig(t, "expansions.json", `{
+  "schema_version": 2,
+  "expansions": [
+    {"trigger": ";sig", "replacement": "{NAME} {NAMEE} {DATE} func() {}"},
+    {"trigger": ";blank", "replacement": ""},
+    {"trigger": ";sig", "replacement": "again"}
+  ],
+  "custom_variables": {"NAME": "Ada"},
+  "settings": {"hotkeys": {"toggle": "ctrl+nope"}}
+}`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := cfg.Warnings()
+	if len(warnings) != 4 {
+		t.Fatalf("expected 4 warnings, got %v", warnings)
+	}
+	for i, want := range []string{"{NAMEE}", "empty", "already used by expansions[0]", "ignored"} {
+		if !strings.Contains(warnings[i].Message, want) {
+			t.Errorf("warning %d: got %q, want it to mention %q", i, warnings[i], want)
+		}
+	}
+}
+
+func TestCursorMarkersSkipEscapedBraces(t *testing.T) {
+	tests := []struct {
+		replacement string
+		want        int
+	}{
+		{"Hello{CURSOR}!", 1},
+		{"{CURSOR}x{cursor}", 2},
+		{"type {{}CURSOR} for the caret: {CURSOR}", 1},
+		{"{{}{CURSOR}}", 1},
+		{"{{CURSOR}{CURSOR}", 1},
+		{"{CURSOR", 0},
+	}
+	for _, tt := range tests {
+		if got := cursorMarkers(tt.replacement); got != tt.want {
+			t.Errorf("cursorMarkers(%q) = %d, want %d", tt.replacement, got, tt.want)
+		}
+	}
+}
+
+func TestShippedConfigIsValid(t *testing.T) {
+	cfg, err := ReadConfig("expansions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := cfg.Warnings(); len(w) > 0 {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+	if problems := defaultConfig().Validate(); len(problems) > 0 {
+		t.Errorf("default config has problems: %v", problems)
+	}
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long Watch waits after the last change before
+// looking at the files, so that a save that writes, truncates and renames
+// is seen once. Tests shorten it.
+var watchDebounce = 150 * time.Millisecond
+
+// Watch calls callback whenever the configuration file or a snippet pack
+// has changed, from a background goroutine. The callback is expected to
+// call Reload.
+//
+// The directories are watched rather than the file, so the watch survives
+// editors that save by writing a new file and renaming it over the old one.
+// Bursts of changes are reported once, when they have settled, and only if
+// the contents differ from what was last reported or from what this
+// configuration last loaded or saved, so its own saves are not reported.
+// The packs directory is created if needed, and watched again if it is
+// removed and recreated. A configuration file that has been removed is not
+// reported until it is back.
+//
+// The returned function stops watching.
+func (c *Config) Watch(callback func()) (stop func(), err error) {
+	c.mu.RLock()
+	path := c.filePath
+	packsDir := c.packsDirLocked()
+	c.mu.RUnlock()
+
+	if path == "" {
+		return nil, errors.New("config file path is not set")
+	}
+	path = filepath.Clean(path)
+	dir := filepath.Dir(path)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("create watcher: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		_ = watcher.Close()
+		return nil, fmt.Errorf("watch config: %w", err)
+	}
+	// Packs are optional; a directory that cannot be watched is skipped.
+	if err := os.MkdirAll(packsDir, 0o755); err == nil {
+		_ = watcher.Add(packsDir)
+	}
+
+	// last is the hash of the file as last reported or as this
+	// configuration last had it, and own the configuration's hash when
+	// last looked at.
+	own := c.getFileHash()
+	last := own
+	lastPacks := hashPacks(packsDir)
+	debounce := watchDebounce
+
+	// changed looks at the files once the changes have settled. It asks to
+	// look again when the file could not be read, as happens on Windows
+	// while another program still has it open.
+	changed := func() (report, retry bool) {
+		if h := c.getFileHash(); h != own {
+			// Loaded or saved since: that is what the file should hold.
+			own, last = h, h
+		}
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if h := sha256.Sum256(data); h != last {
+				last, report = h, true
+			}
+		case !os.IsNotExist(err):
+			retry = true
+		}
+		if h := hashPacks(packsDir); h != lastPacks {
+			lastPacks, report = h, true
+		}
+		return report, retry
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer watcher.Close()
+
+		var (
+			timer *time.Timer
+			fire  <-chan time.Time
+		)
+		schedule := func() {
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(debounce)
+			fire = timer.C
+		}
+
+		for {
+			select {
+			case <-done:
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(event.Name)
+				switch {
+				case name == path:
+					if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+						schedule()
+					}
+				case name == packsDir:
+					// Watches end with their directory; watch the new one.
+					if event.Op&fsnotify.Create != 0 {
+						_ = watcher.Add(packsDir)
+					}
+					schedule()
+				case filepath.Dir(name) == packsDir:
+					if _, err := FormatForPath(name); err == nil && event.Op != fsnotify.Chmod {
+						schedule()
+					}
+				}
+			case <-fire:
+				fire = nil
+				report, retry := changed()
+				if retry {
+					schedule()
+				}
+				if report && callback != nil {
+					callback()
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				// Errors are ignored here; caller can provide separate logging if desired.
+			}
+		}
+	}()
+
+	return func() { close(done) }, nil
+}
+
+// Reload reads the configuration file again and replaces the configuration
+// with it, as LoadConfig would load it. If the file cannot be loaded the
+// configuration is left as it was and the error returned.
+func (c *Config) Reload() error {
+	path := c.ConfigPath()
+	if path == "" {
+		return errors.New("config file path is not set")
+	}
+	fresh, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.Expansions = fresh.Expansions
+	c.CustomVariables = fresh.CustomVariables
+	c.Settings = fresh.Settings
+	c.Profiles = fresh.Profiles
+	c.PackStates = fresh.PackStates
+	c.packs = fresh.packs
+	c.packErrors = fresh.packErrors
+	c.warnings = fresh.warnings
+	c.unloaded = false
+	c.mu.Unlock()
+	c.setFileHash(fresh.getFileHash())
+	return nil
+}
+
+func (c *Config) getFileHash() [sha256.Size]byte {
+	c.hashMu.Lock()
+	defer c.hashMu.Unlock()
+	return c.fileHash
+}
+
+func (c *Config) setFileHash(h [sha256.Size]byte) {
+	c.hashMu.Lock()
+	defer c.hashMu.Unlock()
+	c.fileHash = h
+}
+
+// hashPacks hashes the names and contents of the pack files in dir.
+func hashPacks(dir string) [sha256.Size]byte {
+	h := sha256.New()
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if _, err := FormatForPath(e.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", e.Name(), len(data))
+		h.Write(data)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// watchConfig loads a default configuration in a temporary directory and
+// watches it with a short debounce, counting the changes reported. The
+// callback reloads, as the expander's does.
+func watchConfig(t *testing.T) (*Config, chan struct{}) {
+	t.Helper()
+	watchDebounce = 30 * time.Millisecond
+	t.Cleanup(func() { watchDebounce = 150 * time.Millisecond })
+
+	cfg := newHistoryConfig(t, 0)
+	changed := make(chan struct{}, 16)
+	stop, err := cfg.Watch(func() {
+		if err := cfg.Reload(); err != nil {
+			t.Errorf("reload: %v", err)
+		}
+		changed <- struct{}{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stop)
+	return cfg, changed
+}
+
+// expectChanges waits for changes to be reported and fails unless there
+// were exactly n.
+func expectChanges(t *testing.T, changed chan struct{}, n int) {
+	t.Helper()
+	got := 0
+	timeout := time.After(2 * time.Second)
+	for got < n {
+		select {
+		case <-changed:
+			got++
+		case <-timeout:
+			t.Fatalf("got %d changes, want %d", got, n)
+		}
+	}
+	select {
+	case <-changed:
+		t.Fatalf("got more than %d changes", n)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+// replaceFile writes data beside path and renames it over path, as editors
+// such as vim and VS Code save.
+func replaceFile(t *testing.T, path, data string) {
+	t.Helper()
+	tmp := path + ".swp"
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchSurvivesRenameReplace(t *testing.T) {
+	cfg, changed := watchConfig(t)
+	path := cfg.ConfigPath()
+
+	for _, trigger := range []string{";one", ";two"} {
+		replaceFile(t, path, `{"schema_version": 2, "expansions": [{"id": "x", "trigger": "`+trigger+`", "replacement": "x"}]}`)
+		expectChanges(t, changed, 1)
+		if exps := cfg.GetExpansions(); len(exps) != 1 || exps[0].Trigger != trigger {
+			t.Fatalf("expected %s to be loaded, got %+v", trigger, exps)
+		}
+	}
+}
+
+func TestWatchDebouncesWrites(t *testing.T) {
+	cfg, changed := watchConfig(t)
+	path := cfg.ConfigPath()
+
+	// Writing in pieces fires several events for one save.
+	data := `{"schema_version": 2, "expansions": [{"id": "x", "trigger": ";x", "replacement": "x"}]}`
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data); i += 16 {
+		if _, err := f.WriteString(data[i:min(i+16, len(data))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+	expectChanges(t, changed, 1)
+}
+
+func TestWatchSkipsUnchangedContent(t *testing.T) {
+	cfg, changed := watchConfig(t)
+	path := cfg.ConfigPath()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaceFile(t, path, string(data))
+	expectChanges(t, changed, 0)
+
+	// A file removed and not replaced is left alone.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, changed, 0)
+}
+
+func TestWatchIgnoresOwnSaves(t *testing.T) {
+	cfg, changed := watchConfig(t)
+
+	cfg.SetCustomVar("NAME", "Ada")
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, changed, 0)
+
+	// Changes made elsewhere afterwards are still seen, even back to what
+	// the configuration once held.
+	saved, err := os.ReadFile(cfg.ConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SetCustomVar("NAME", "Grace")
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, changed, 0)
+	replaceFile(t, cfg.ConfigPath(), string(saved))
+	expectChanges(t, changed, 1)
+	if got := cfg.GetCustomVars()["NAME"]; got != "Ada" {
+		t.Errorf("expected the file's variable, got %q", got)
+	}
+}
+
+func TestWatchRearmsRecreatedPacksDir(t *testing.T) {
+	cfg, changed := watchConfig(t)
+	dir := cfg.PacksDir()
+
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, changed, 0)
+	writePack(t, cfg.ConfigPath(), "new.json", `{"expansions": [{"trigger": "x", "replacement": "x"}]}`)
+	expectChanges(t, changed, 1)
+	if p := cfg.Packs(); len(p) != 1 {
+		t.Fatalf("expected the new pack to be loaded, got %+v", p)
+	}
+
+	// Later changes inside the recreated directory are seen too.
+	if err := os.WriteFile(filepath.Join(dir, "new.json"), []byte(`{"expansions": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, changed, 1)
+}
+
+func TestUnloadedConfigIsFilledInByReload(t *testing.T) {
+	broken := `{"expansions": [`
+	path := writeConfig(t, "expansions.json", broken)
+
+	cfg := NewUnloadedConfig(path)
+	if cfg.Loaded() || cfg.GetSettings().Enabled {
+		t.Fatalf("expected an unloaded configuration with expansion off")
+	}
+	if err := cfg.Save(); err == nil {
+		t.Fatal("expected saving an unloaded configuration to fail")
+	}
+	if data, _ := os.ReadFile(path); string(data) != broken {
+		t.Fatalf("broken file was replaced: %q", data)
+	}
+	if err := cfg.Reload(); err == nil {
+		t.Fatal("expected reloading the broken file to fail")
+	}
+
+	fixed := `{"schema_version": 2, "expansions": [{"id": "a", "trigger": ";a", "replacement": "x"}], "settings": {"enabled": true}}`
+	if err := os.WriteFile(path, []byte(fixed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Loaded() || !cfg.GetSettings().Enabled || len(cfg.GetExpansions()) != 1 {
+		t.Fatalf("expected the fixed file to be loaded, got %+v", cfg.GetExpansions())
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+}
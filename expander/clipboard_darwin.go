@@ -0,0 +1,17 @@
+package expander
+
+import (
+	"encoding/hex"
+	"os/exec"
+	"strings"
+)
+
+// setRichClipboard stores the fragment as public.html together with a
+// plain-text fallback using AppleScript.
+func setRichClipboard(htmlOut, plain string) error {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(plain)
+	script := `set the clipboard to {«class HTML»:«data HTML` +
+		strings.ToUpper(hex.EncodeToString([]byte(htmlOut))) +
+		`», string:"` + escaped + `"}`
+	return exec.Command("osascript", "-e", script).Run()
+}
@@ -0,0 +1,39 @@
+//go:build !windows && !darwin
+
+package expander
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// setRichClipboard offers the fragment as text/html with plain as the
+// plain-text fallback. With an X display, including XWayland, the
+// clipboard is served directly so both can be offered. Otherwise xclip or
+// wl-copy store the HTML alone, as neither can offer several MIME types at
+// once.
+func setRichClipboard(htmlOut, plain string) error {
+	if os.Getenv("DISPLAY") != "" {
+		if err := setX11Clipboard(htmlOut, plain); err == nil {
+			return nil
+		}
+	}
+
+	candidates := [][]string{
+		{"xclip", "-selection", "clipboard", "-t", "text/html"},
+		{"wl-copy", "--type", "text/html"},
+	}
+
+	for _, args := range candidates {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(htmlOut)
+		return cmd.Run()
+	}
+
+	return errors.New("no HTML-capable clipboard tool found (install xclip or wl-clipboard)")
+}
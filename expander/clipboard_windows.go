@@ -0,0 +1,79 @@
+package expander
+
+import (
+	"errors"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	user32                     = syscall.NewLazyDLL("user32.dll")
+	kernel32                   = syscall.NewLazyDLL("kernel32.dll")
+	procOpenClipboard          = user32.NewProc("OpenClipboard")
+	procCloseClipboard         = user32.NewProc("CloseClipboard")
+	procEmptyClipboard         = user32.NewProc("EmptyClipboard")
+	procSetClipboardData       = user32.NewProc("SetClipboardData")
+	procRegisterClipboardFormW = user32.NewProc("RegisterClipboardFormatW")
+	procGlobalAlloc            = kernel32.NewProc("GlobalAlloc")
+	procGlobalFree             = kernel32.NewProc("GlobalFree")
+	procGlobalLock             = kernel32.NewProc("GlobalLock")
+	procGlobalUnlock           = kernel32.NewProc("GlobalUnlock")
+)
+
+const (
+	cfUnicodeText = 13
+	gmemMoveable  = 0x0002
+)
+
+// setRichClipboard stores the fragment as "HTML Format" together with a
+// CF_UNICODETEXT fallback in a single clipboard transaction.
+func setRichClipboard(htmlOut, plain string) error {
+	name, err := syscall.UTF16PtrFromString("HTML Format")
+	if err != nil {
+		return err
+	}
+	htmlFormat, _, _ := procRegisterClipboardFormW.Call(uintptr(unsafe.Pointer(name)))
+	if htmlFormat == 0 {
+		return errors.New("register HTML clipboard format failed")
+	}
+
+	if r, _, err := procOpenClipboard.Call(0); r == 0 {
+		return err
+	}
+	defer procCloseClipboard.Call()
+
+	if r, _, err := procEmptyClipboard.Call(); r == 0 {
+		return err
+	}
+
+	htmlBytes := append([]byte(cfHTML(htmlOut)), 0)
+	if err := setClipboardBytes(htmlFormat, htmlBytes); err != nil {
+		return err
+	}
+
+	text := utf16.Encode([]rune(plain + "\x00"))
+	textBytes := unsafe.Slice((*byte)(unsafe.Pointer(&text[0])), len(text)*2)
+	return setClipboardBytes(cfUnicodeText, textBytes)
+}
+
+func setClipboardBytes(format uintptr, data []byte) error {
+	h, _, err := procGlobalAlloc.Call(gmemMoveable, uintptr(len(data)))
+	if h == 0 {
+		return err
+	}
+
+	p, _, err := procGlobalLock.Call(h)
+	if p == 0 {
+		procGlobalFree.Call(h)
+		return err
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(p)), len(data)), data)
+	procGlobalUnlock.Call(h)
+
+	if r, _, err := procSetClipboardData.Call(format, h); r == 0 {
+		procGlobalFree.Call(h)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+//go:build !windows && !darwin
+
+package expander
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// maxX11ClipboardSize keeps the contents within a single ChangeProperty
+// request. Larger contents would need the INCR protocol and are left to
+// the clipboard tools instead.
+const maxX11ClipboardSize = 200 * 1024
+
+// setX11Clipboard takes ownership of the X11 CLIPBOARD selection and offers
+// htmlOut as text/html and plain under the plain-text targets, so that
+// applications without rich-text support still paste something. The
+// contents are served from a background goroutine until another client
+// takes the selection over.
+func setX11Clipboard(htmlOut, plain string) error {
+	if len(htmlOut) > maxX11ClipboardSize || len(plain) > maxX11ClipboardSize {
+		return errors.New("contents too large for the X11 clipboard")
+	}
+
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return fmt.Errorf("connect to X server: %w", err)
+	}
+
+	atoms, err := internAtoms(conn, "CLIPBOARD", "TARGETS", "UTF8_STRING",
+		"text/html", "text/plain", "text/plain;charset=utf-8")
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	clip, targets := atoms[0], atoms[1]
+	htmlTarget := atoms[3]
+	plainTargets := []xproto.Atom{atoms[2], atoms[4], atoms[5], xproto.AtomString}
+
+	screen := xproto.Setup(conn).DefaultScreen(conn)
+	win, err := xproto.NewWindowId(conn)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("create clipboard window: %w", err)
+	}
+	if err := xproto.CreateWindowChecked(conn, 0, win, screen.Root, 0, 0, 1, 1, 0,
+		xproto.WindowClassInputOnly, 0, 0, nil).Check(); err != nil {
+		conn.Close()
+		return fmt.Errorf("create clipboard window: %w", err)
+	}
+	if err := xproto.SetSelectionOwnerChecked(conn, win, clip, xproto.TimeCurrentTime).Check(); err != nil {
+		conn.Close()
+		return fmt.Errorf("take clipboard: %w", err)
+	}
+	owner, err := xproto.GetSelectionOwner(conn, clip).Reply()
+	if err != nil || owner.Owner != win {
+		conn.Close()
+		return errors.New("take clipboard: another client owns it")
+	}
+
+	offered := append([]xproto.Atom{targets, htmlTarget}, plainTargets...)
+	go func() {
+		defer conn.Close()
+		for {
+			ev, err := conn.WaitForEvent()
+			if ev == nil && err == nil {
+				return // connection closed
+			}
+			switch ev := ev.(type) {
+			case xproto.SelectionClearEvent:
+				return
+			case xproto.SelectionRequestEvent:
+				prop := ev.Property
+				if prop == xproto.AtomNone {
+					prop = ev.Target // obsolete clients
+				}
+				switch {
+				case ev.Target == targets:
+					buf := make([]byte, 4*len(offered))
+					for i, a := range offered {
+						binary.LittleEndian.PutUint32(buf[4*i:], uint32(a))
+					}
+					xproto.ChangeProperty(conn, xproto.PropModeReplace, ev.Requestor, prop,
+						xproto.AtomAtom, 32, uint32(len(offered)), buf)
+				case ev.Target == htmlTarget:
+					xproto.ChangeProperty(conn, xproto.PropModeReplace, ev.Requestor, prop,
+						ev.Target, 8, uint32(len(htmlOut)), []byte(htmlOut))
+				case slices.Contains(plainTargets, ev.Target):
+					xproto.ChangeProperty(conn, xproto.PropModeReplace, ev.Requestor, prop,
+						ev.Target, 8, uint32(len(plain)), []byte(plain))
+				default:
+					prop = xproto.AtomNone
+				}
+				notify := xproto.SelectionNotifyEvent{
+					Time:      ev.Time,
+					Requestor: ev.Requestor,
+					Selection: ev.Selection,
+					Target:    ev.Target,
+					Property:  prop,
+				}
+				xproto.SendEvent(conn, false, ev.Requestor, xproto.EventMaskNoEvent, string(notify.Bytes()))
+			}
+		}
+	}()
+	return nil
+}
+
+// internAtoms looks up the atoms for names, in order.
+func internAtoms(conn *xgb.Conn, names ...string) ([]xproto.Atom, error) {
+	atoms := make([]xproto.Atom, len(names))
+	for i, name := range names {
+		reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			return nil, fmt.Errorf("intern atom %s: %w", name, err)
+		}
+		atoms[i] = reply.Atom
+	}
+	return atoms, nil
+}
@@ -0,0 +1,245 @@
+package expander
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Document is an in-memory text field with a caret and an optional
+// selection. It stands in for the focused application when expansions are
+// simulated: user keys and injected input are applied to it the way a plain
+// text editor would.
+type Document struct {
+	mu     sync.Mutex
+	text   []rune
+	caret  int
+	anchor int // selection start, or -1 when nothing is selected
+	clip   string
+
+	// undo holds snapshots taken before each run of edits from the same
+	// source, so undoing an expansion restores the typed trigger.
+	undo       []docState
+	lastSource string
+}
+
+type docState struct {
+	text  []rune
+	caret int
+}
+
+// Edit sources used to group undo steps.
+const (
+	sourceUser     = "user"
+	sourceInjected = "injected"
+)
+
+// NewDocument creates an empty document.
+func NewDocument() *Document {
+	return &Document{anchor: -1}
+}
+
+// Text returns the document's contents.
+func (d *Document) Text() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return string(d.text)
+}
+
+// Caret returns the caret position in runes.
+func (d *Document) Caret() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.caret
+}
+
+// TextWithCaret returns the contents with marker inserted at the caret.
+func (d *Document) TextWithCaret(marker string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return string(d.text[:d.caret]) + marker + string(d.text[d.caret:])
+}
+
+// ApplyKey applies a logical key typed by the user.
+func (d *Document) ApplyKey(key string) {
+	switch key {
+	case KeyBackspace:
+		d.backspace(sourceUser)
+	case KeySpace:
+		d.insert(sourceUser, " ")
+	case KeyEnter:
+		d.insert(sourceUser, "\n")
+	case KeyTab:
+		d.insert(sourceUser, "\t")
+	case KeyEscape:
+	default:
+		if len([]rune(key)) == 1 {
+			d.insert(sourceUser, key)
+		}
+	}
+}
+
+// ApplyShortcut applies an editing shortcut such as "ctrl+z" or "ctrl+v"
+// pressed by the user. Unknown shortcuts are ignored.
+func (d *Document) ApplyShortcut(shortcut string) {
+	d.shortcut(sourceUser, shortcut)
+}
+
+// Undo reverts the most recent run of edits.
+func (d *Document) Undo() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.undo) == 0 {
+		return
+	}
+	s := d.undo[len(d.undo)-1]
+	d.undo = d.undo[:len(d.undo)-1]
+	d.text, d.caret, d.anchor = s.text, s.caret, -1
+	d.lastSource = ""
+}
+
+func (d *Document) shortcut(source, shortcut string) {
+	key, mods := splitShortcut(shortcut)
+	var ctrl, shift bool
+	for _, m := range mods {
+		switch m {
+		case InjectCtrl, "control", InjectCmd:
+			ctrl = true
+		case InjectShift:
+			shift = true
+		}
+	}
+
+	switch {
+	case ctrl && key == "z":
+		d.Undo()
+	case ctrl && key == "a":
+		d.mu.Lock()
+		d.anchor, d.caret = 0, len(d.text)
+		d.mu.Unlock()
+	case ctrl && key == "v", shift && key == "insert":
+		d.mu.Lock()
+		clip := d.clip
+		d.mu.Unlock()
+		d.insert(source, clip)
+	case key == InjectBackspace:
+		d.backspace(source)
+	case key == InjectEnter:
+		d.insert(source, "\n")
+	case key == InjectTab:
+		d.insert(source, "\t")
+	case key == InjectLeft:
+		d.move(-1, shift)
+	case key == InjectRight:
+		d.move(1, shift)
+	}
+}
+
+// snapshot records an undo step when the edit source changes. d.mu must be
+// held.
+func (d *Document) snapshot(source string) {
+	if source == d.lastSource {
+		return
+	}
+	d.undo = append(d.undo, docState{text: append([]rune(nil), d.text...), caret: d.caret})
+	d.lastSource = source
+}
+
+// deleteSelection removes the selected text, if any. d.mu must be held.
+func (d *Document) deleteSelection() bool {
+	if d.anchor < 0 || d.anchor == d.caret {
+		d.anchor = -1
+		return false
+	}
+	from, to := d.anchor, d.caret
+	if from > to {
+		from, to = to, from
+	}
+	d.text = append(d.text[:from], d.text[to:]...)
+	d.caret, d.anchor = from, -1
+	return true
+}
+
+func (d *Document) insert(source, s string) {
+	if s == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.snapshot(source)
+	d.deleteSelection()
+	r := []rune(s)
+	d.text = append(d.text[:d.caret], append(r, d.text[d.caret:]...)...)
+	d.caret += len(r)
+}
+
+func (d *Document) backspace(source string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.snapshot(source)
+	if d.deleteSelection() || d.caret == 0 {
+		return
+	}
+	d.text = append(d.text[:d.caret-1], d.text[d.caret:]...)
+	d.caret--
+}
+
+func (d *Document) move(delta int, extend bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if extend && d.anchor < 0 {
+		d.anchor = d.caret
+	} else if !extend {
+		d.anchor = -1
+	}
+	d.caret += delta
+	if d.caret < 0 {
+		d.caret = 0
+	}
+	if d.caret > len(d.text) {
+		d.caret = len(d.text)
+	}
+}
+
+// DocumentInjector is an Injector that edits a Document instead of sending
+// input to the desktop.
+type DocumentInjector struct {
+	doc *Document
+}
+
+// NewDocumentInjector returns an injector that writes into doc.
+func NewDocumentInjector(doc *Document) *DocumentInjector {
+	return &DocumentInjector{doc: doc}
+}
+
+// TypeText inserts text at the caret.
+func (d *DocumentInjector) TypeText(text string, _ time.Duration) error {
+	d.doc.insert(sourceInjected, text)
+	return nil
+}
+
+// TapKey applies a key or shortcut to the document.
+func (d *DocumentInjector) TapKey(key string, modifiers ...string) error {
+	d.doc.shortcut(sourceInjected, strings.Join(append(append([]string(nil), modifiers...), key), "+"))
+	return nil
+}
+
+// Paste inserts the plain-text part of content at the caret. The shortcut
+// only matters to real applications.
+func (d *DocumentInjector) Paste(content ClipboardContent, _ string) error {
+	d.doc.mu.Lock()
+	d.doc.clip = content.Text
+	d.doc.mu.Unlock()
+	d.doc.insert(sourceInjected, content.Text)
+	return nil
+}
+
+// MoveCursor moves the caret by delta runes.
+func (d *DocumentInjector) MoveCursor(delta int, _ time.Duration) error {
+	d.doc.move(delta, false)
+	return nil
+}
@@ -0,0 +1,515 @@
+package expander
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"unicode"
+	"unsafe"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Keyboard layouts the evdev backend can translate, selected through
+// config.Settings.KeyboardLayout.
+const (
+	LayoutUS     = "us"
+	LayoutUSIntl = "us-intl"
+)
+
+// More Linux input event codes, in addition to those in keymap.go.
+const (
+	evKeyRightShift = 54
+	evKeyCapsLock   = 58
+	evKeyKPEnter    = 96
+	evKeyRightCtrl  = 97
+	evKeyRightAlt   = 100
+	evKeyPageUp     = 104
+	evKeyPageDown   = 109
+	evKeyInsert     = 110
+	evKeyRightMeta  = 126
+)
+
+// inputEvent mirrors struct input_event from linux/input.h. Its time is a
+// struct timeval of two longs, so the size and offsets depend on the word
+// size and are taken from this struct rather than written out.
+type inputEvent struct {
+	Time  [2]uintptr
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// Size of struct input_event and offsets of its fields.
+const (
+	evdevEventSize = unsafe.Sizeof(inputEvent{})
+	evdevTypeOff   = unsafe.Offsetof(inputEvent{}.Type)
+	evdevCodeOff   = unsafe.Offsetof(inputEvent{}.Code)
+	evdevValueOff  = unsafe.Offsetof(inputEvent{}.Value)
+)
+
+// evdevVC maps Linux key codes to libuiohook key codes for the keys where
+// they differ; below 89 the two are the same set 1 scan codes.
+var evdevVC = map[uint16]uint16{
+	evKeyKPEnter:  vcKPEnter,
+	evKeyHome:     0x0E47,
+	evKeyUp:       0xE048,
+	evKeyPageUp:   0x0E49,
+	evKeyLeft:     0xE04B,
+	evKeyRight:    0xE04D,
+	evKeyEnd:      0x0E4F,
+	evKeyDown:     0xE050,
+	evKeyPageDown: 0x0E51,
+	evKeyInsert:   0x0E52,
+	evKeyDelete:   0x0E53,
+}
+
+// evdevLayout holds the characters each key produces at the four shift
+// levels: plain, Shift, AltGr and AltGr+Shift. A combining mark marks a dead
+// key applying that accent.
+type evdevLayout [4]map[uint16]rune
+
+// Shift levels of an evdevLayout.
+const (
+	levelPlain = iota
+	levelShift
+	levelAltGr
+	levelAltGrShift
+)
+
+// newEvdevLayout returns the built-in table for a layout name. An empty name
+// selects US.
+func newEvdevLayout(name string) (evdevLayout, error) {
+	var l evdevLayout
+	for i := range l {
+		l[i] = make(map[uint16]rune)
+	}
+	for r, ks := range usLayout {
+		if r < ' ' || r == ' ' {
+			continue
+		}
+		if ks.shift {
+			l[levelShift][ks.code] = r
+		} else {
+			l[levelPlain][ks.code] = r
+		}
+	}
+
+	switch strings.ToLower(name) {
+	case "", LayoutUS:
+	case LayoutUSIntl:
+		for code, chars := range usIntlKeys {
+			for level, r := range chars {
+				if r != 0 {
+					l[level][code] = r
+				}
+			}
+		}
+	default:
+		return evdevLayout{}, fmt.Errorf("unknown keyboard layout %q", name)
+	}
+	return l, nil
+}
+
+// usIntlKeys lists the keys where US-International differs from US, per
+// level. Zero entries keep the US character.
+var usIntlKeys = map[uint16][4]rune{
+	40: {'\u0301', '\u0308', '´', '¨'}, // ' " (dead acute, diaeresis)
+	41: {'\u0300', '\u0303', 0, 0},     // ` ~ (dead grave, tilde)
+	7:  {0, '\u0302', '¼', 0},          // 6 ^ (dead circumflex)
+	2:  {0, 0, '¡', '¹'},
+	3:  {0, 0, '²', 0},
+	4:  {0, 0, '³', 0},
+	5:  {0, 0, '¤', '£'},
+	6:  {0, 0, '€', 0},
+	8:  {0, 0, '½', 0},
+	9:  {0, 0, '¾', 0},
+	10: {0, 0, '‘', 0},
+	11: {0, 0, '’', 0},
+	12: {0, 0, '¥', 0},
+	13: {0, 0, '×', '÷'},
+	16: {0, 0, 'ä', 'Ä'},
+	17: {0, 0, 'å', 'Å'},
+	18: {0, 0, 'é', 'É'},
+	19: {0, 0, '®', 0},
+	20: {0, 0, 'þ', 'Þ'},
+	21: {0, 0, 'ü', 'Ü'},
+	22: {0, 0, 'ú', 'Ú'},
+	23: {0, 0, 'í', 'Í'},
+	24: {0, 0, 'ó', 'Ó'},
+	25: {0, 0, 'ö', 'Ö'},
+	26: {0, 0, '«', 0},
+	27: {0, 0, '»', 0},
+	30: {0, 0, 'á', 'Á'},
+	31: {0, 0, 'ß', '§'},
+	32: {0, 0, 'ð', 'Ð'},
+	38: {0, 0, 'ø', 'Ø'},
+	39: {0, 0, '¶', '°'},
+	44: {0, 0, 'æ', 'Æ'},
+	46: {0, 0, '©', '¢'},
+	49: {0, 0, 'ñ', 'Ñ'},
+	50: {0, 0, 'µ', 0},
+	51: {0, 0, 'ç', 'Ç'},
+	53: {0, 0, '¿', 0},
+}
+
+// usIntlSpacing is what a dead key types when it does not combine with the
+// next character, as on US-International.
+var usIntlSpacing = map[rune]rune{
+	'\u0300': '`',
+	'\u0301': '\'',
+	'\u0302': '^',
+	'\u0303': '~',
+	'\u0308': '"',
+}
+
+// usIntlCombines lists the letters each dead key composes with. Anything
+// else types the accent followed by the character, so "'s" stays as typed.
+var usIntlCombines = map[rune]string{
+	'\u0300': "aeiouAEIOU",
+	'\u0301': "aeiouyAEIOUY",
+	'\u0302': "aeiouAEIOU",
+	'\u0303': "anoANO",
+	'\u0308': "aeiouyAEIOU",
+}
+
+// evdevDecoder turns key events from evdev keyboards into logical keys. It
+// tracks modifiers and Caps Lock itself and composes dead keys, since evdev
+// reports physical keys rather than text.
+type evdevDecoder struct {
+	layout   evdevLayout
+	held     map[uint16]bool
+	capsLock bool
+	dead     rune // combining mark of a pending dead key, 0 if none
+}
+
+func newEvdevDecoder(layout evdevLayout) *evdevDecoder {
+	return &evdevDecoder{layout: layout, held: make(map[uint16]bool)}
+}
+
+func (d *evdevDecoder) shift() bool {
+	return d.held[evKeyLeftShift] || d.held[evKeyRightShift]
+}
+
+func (d *evdevDecoder) ctrl() bool {
+	return d.held[evKeyLeftCtrl] || d.held[evKeyRightCtrl]
+}
+
+func (d *evdevDecoder) meta() bool {
+	return d.held[evKeyLeftMeta] || d.held[evKeyRightMeta]
+}
+
+// anyModifier reports whether any modifier key is held.
+func (d *evdevDecoder) anyModifier() bool {
+	for _, held := range d.held {
+		if held {
+			return true
+		}
+	}
+	return false
+}
+
+// modifier updates the modifier state for code and reports whether code is
+// a modifier key.
+func (d *evdevDecoder) modifier(code uint16, value int32) bool {
+	switch code {
+	case evKeyLeftShift, evKeyRightShift, evKeyLeftCtrl, evKeyRightCtrl,
+		evKeyLeftAlt, evKeyRightAlt, evKeyLeftMeta, evKeyRightMeta:
+		d.held[code] = value != 0
+		return true
+	case evKeyCapsLock:
+		if value == 1 {
+			d.capsLock = !d.capsLock
+		}
+		return true
+	}
+	return false
+}
+
+// hotkey returns the combination formed by pressing code with the modifiers
+// currently held. Right Alt counts as Alt, as it does for the hook.
+func (d *evdevDecoder) hotkey(code uint16) Hotkey {
+	vc, ok := evdevVC[code]
+	if !ok {
+		vc = code
+	}
+	return Hotkey{
+		Keycode: vc,
+		Ctrl:    d.ctrl(),
+		Shift:   d.shift(),
+		Alt:     d.held[evKeyLeftAlt] || d.held[evKeyRightAlt],
+		Meta:    d.meta(),
+	}
+}
+
+// press returns the logical keys typed by pressing, or auto-repeating, the
+// non-modifier key code.
+func (d *evdevDecoder) press(code uint16) []string {
+	switch code {
+	case evKeyBackspace:
+		if d.dead != 0 {
+			d.dead = 0
+			return nil
+		}
+		return []string{KeyBackspace}
+	case evKeyEsc:
+		d.dead = 0
+		return []string{KeyEscape}
+	case evKeyEnter, evKeyKPEnter:
+		return d.flush(KeyEnter)
+	case evKeyTab:
+		return d.flush(KeyTab)
+	case evKeySpace:
+		if d.ctrl() || d.meta() || d.held[evKeyLeftAlt] {
+			return nil
+		}
+		if d.dead != 0 {
+			r := d.spacing(d.dead)
+			d.dead = 0
+			return []string{string(r)}
+		}
+		return []string{KeySpace}
+	}
+
+	// Shortcuts are not text; AltGr on its own selects a level.
+	if d.ctrl() || d.meta() || d.held[evKeyLeftAlt] {
+		return nil
+	}
+
+	r, ok := d.char(code)
+	if !ok {
+		return nil
+	}
+	if unicode.Is(unicode.Mn, r) {
+		out := d.flush()
+		d.dead = r
+		return out
+	}
+	if d.dead == 0 {
+		return []string{string(r)}
+	}
+
+	mark := d.dead
+	d.dead = 0
+	if c, ok := compose(mark, r); ok {
+		return []string{string(c)}
+	}
+	return []string{string(d.spacing(mark)), string(r)}
+}
+
+// compose applies a dead key's accent to r. An acute on c gives a cedilla,
+// as on US-International.
+func compose(mark, r rune) (rune, bool) {
+	if mark == '\u0301' && (r == 'c' || r == 'C') {
+		return r - 'c' + '\u00E7', true
+	}
+	if !strings.ContainsRune(usIntlCombines[mark], r) {
+		return 0, false
+	}
+	c := []rune(norm.NFC.String(string(r) + string(mark)))
+	if len(c) != 1 {
+		return 0, false
+	}
+	return c[0], true
+}
+
+// char looks up the character code produces at the current shift level.
+func (d *evdevDecoder) char(code uint16) (rune, bool) {
+	level := levelPlain
+	if d.held[evKeyRightAlt] {
+		level = levelAltGr
+	}
+	shift := d.shift()
+	if r, ok := d.layout[levelPlain][code]; ok && d.capsLock && unicode.IsLetter(r) {
+		shift = !shift
+	}
+	if shift {
+		level++
+	}
+
+	if r, ok := d.layout[level][code]; ok {
+		return r, true
+	}
+	if level == levelAltGrShift {
+		// Fall back to the upper case of the AltGr character.
+		if r, ok := d.layout[levelAltGr][code]; ok {
+			return unicode.ToUpper(r), true
+		}
+	}
+	return 0, false
+}
+
+// spacing returns the character a dead key types on its own.
+func (d *evdevDecoder) spacing(mark rune) rune {
+	if r, ok := usIntlSpacing[mark]; ok {
+		return r
+	}
+	if r, ok := spacingAccents[mark]; ok {
+		return r
+	}
+	return mark
+}
+
+// flush emits a pending dead key as typed, followed by keys.
+func (d *evdevDecoder) flush(keys ...string) []string {
+	if d.dead == 0 {
+		return keys
+	}
+	r := d.spacing(d.dead)
+	d.dead = 0
+	return append([]string{string(r)}, keys...)
+}
+
+// evdevKeyEvent is an EV_KEY record from one of the devices.
+type evdevKeyEvent struct {
+	code  uint16
+	value int32 // 0 release, 1 press, 2 auto-repeat
+	// capsLock, if set, makes the event the Caps Lock LED state of a device
+	// that was just opened instead of a key.
+	capsLock *bool
+}
+
+// readEvdevEvents reads struct input_event records from r and sends the
+// EV_KEY ones to out until r fails or ends.
+func readEvdevEvents(r io.Reader, out chan<- evdevKeyEvent) error {
+	var buf [evdevEventSize]byte
+	for {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		typ := binary.LittleEndian.Uint16(buf[evdevTypeOff:])
+		if typ != evKey {
+			continue
+		}
+		out <- evdevKeyEvent{
+			code:  binary.LittleEndian.Uint16(buf[evdevCodeOff:]),
+			value: int32(binary.LittleEndian.Uint32(buf[evdevValueOff:])),
+		}
+	}
+}
+
+// EvdevKeyboard is a Keyboard that reads every keyboard under /dev/input
+// through evdev. Unlike KeyboardHook it works on Wayland, but needs read
+// access to the devices (typically membership of the "input" group) and
+// translates keys with a built-in layout table instead of the system's.
+// Keyboards plugged in while it runs are picked up automatically.
+type EvdevKeyboard struct {
+	layout evdevLayout
+	events chan evdevKeyEvent
+
+	mu         sync.Mutex
+	onKeyPress func(key string)
+	recorder   *EventRecorder
+	hotkeys    map[Hotkey]func()
+	running    bool
+	stop       chan struct{}
+	devices    map[string]io.Closer
+	modsHeld   bool // a modifier key is held, as last decoded
+
+	dispatching bool // the decoding goroutine has been started
+}
+
+// NewEvdevKeyboard creates an evdev keyboard translating with the named
+// layout (LayoutUS or LayoutUSIntl).
+func NewEvdevKeyboard(layout string) (*EvdevKeyboard, error) {
+	l, err := newEvdevLayout(layout)
+	if err != nil {
+		return nil, err
+	}
+	return &EvdevKeyboard{
+		layout:  l,
+		events:  make(chan evdevKeyEvent, eventQueueSize),
+		devices: make(map[string]io.Closer),
+	}, nil
+}
+
+// SetOnKeyPress sets the callback invoked on each key press.
+// The callback is called from a background goroutine.
+func (k *EvdevKeyboard) SetOnKeyPress(cb func(key string)) {
+	k.mu.Lock()
+	k.onKeyPress = cb
+	k.mu.Unlock()
+}
+
+// SetRecorder makes the keyboard write every logical key to rec. Pass nil to
+// stop recording.
+func (k *EvdevKeyboard) SetRecorder(rec *EventRecorder) {
+	k.mu.Lock()
+	k.recorder = rec
+	k.mu.Unlock()
+}
+
+// SetHotkeys replaces the global hotkeys. Each function runs on its own
+// goroutine when its combination is pressed; the key press is not passed on
+// as text.
+func (k *EvdevKeyboard) SetHotkeys(hotkeys map[Hotkey]func()) {
+	k.mu.Lock()
+	k.hotkeys = hotkeys
+	k.mu.Unlock()
+}
+
+// ObservesInjected reports false: the virtual keyboard of the uinput
+// injector is skipped, and other injectors do not go through evdev.
+func (k *EvdevKeyboard) ObservesInjected() bool {
+	return false
+}
+
+// ModifiersHeld reports whether a modifier key is held down.
+func (k *EvdevKeyboard) ModifiersHeld() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.modsHeld
+}
+
+// dispatch decodes events from all devices until events is closed.
+func (k *EvdevKeyboard) dispatch(events <-chan evdevKeyEvent) {
+	d := newEvdevDecoder(k.layout)
+	for ev := range events {
+		k.handle(d, ev)
+	}
+}
+
+func (k *EvdevKeyboard) handle(d *evdevDecoder, ev evdevKeyEvent) {
+	if ev.capsLock != nil {
+		d.capsLock = *ev.capsLock
+		return
+	}
+	if d.modifier(ev.code, ev.value) {
+		k.mu.Lock()
+		k.modsHeld = d.anyModifier()
+		k.mu.Unlock()
+		return
+	}
+	if ev.value == 0 {
+		return
+	}
+
+	k.mu.Lock()
+	cb, rec, hotkeys := k.onKeyPress, k.recorder, k.hotkeys
+	k.mu.Unlock()
+
+	if ev.value == 1 {
+		if fn, ok := hotkeys[d.hotkey(ev.code)]; ok {
+			go fn()
+			return
+		}
+	}
+
+	for _, key := range d.press(ev.code) {
+		if rec != nil {
+			if err := rec.RecordKey(key); err != nil {
+				log.Printf("EvdevKeyboard: recording failed: %v", err)
+			}
+		}
+		if cb != nil {
+			cb(key)
+		}
+	}
+}
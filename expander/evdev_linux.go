@@ -0,0 +1,193 @@
+package expander
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// evdevDir is where the kernel exposes input devices.
+const evdevDir = "/dev/input"
+
+// uinputDeviceName is the name UinputInjector gives its virtual keyboard;
+// reading it back would make injected text look typed.
+const uinputDeviceName = "text-expander virtual keyboard"
+
+// evdev ioctl requests (linux/input.h).
+func eviocgname(size uintptr) uintptr { return 2<<30 | size<<16 | 'E'<<8 | 0x06 }
+func eviocgbit(ev, size uintptr) uintptr {
+	return 2<<30 | size<<16 | 'E'<<8 | (0x20 + ev)
+}
+func eviocgled(size uintptr) uintptr { return 2<<30 | size<<16 | 'E'<<8 | 0x19 }
+
+// ledCapsLock is the Caps Lock bit of the EVIOCGLED state.
+const ledCapsLock = 0x01
+
+// Start opens every keyboard under /dev/input and watches the directory for
+// keyboards plugged in later.
+func (k *EvdevKeyboard) Start() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.running {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create device watcher: %w", err)
+	}
+	if err := watcher.Add(evdevDir); err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("watch %s: %w", evdevDir, err)
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(evdevDir, "event*"))
+	for _, path := range paths {
+		k.openDeviceLocked(path)
+	}
+	if len(k.devices) == 0 {
+		_ = watcher.Close()
+		return fmt.Errorf("no readable keyboards in %s; is the user in the input group?", evdevDir)
+	}
+
+	k.running = true
+	k.stop = make(chan struct{})
+	if !k.dispatching {
+		k.dispatching = true
+		go k.dispatch(k.events)
+	}
+	go k.watch(watcher, k.stop)
+	return nil
+}
+
+// Stop closes all devices.
+func (k *EvdevKeyboard) Stop() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !k.running {
+		return
+	}
+	close(k.stop)
+	for path, dev := range k.devices {
+		_ = dev.Close()
+		delete(k.devices, path)
+	}
+	k.running = false
+}
+
+// watch opens keyboards as they appear until stop is closed.
+func (k *EvdevKeyboard) watch(watcher *fsnotify.Watcher, stop chan struct{}) {
+	defer watcher.Close()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Create == 0 || !strings.HasPrefix(filepath.Base(event.Name), "event") {
+				continue
+			}
+			// udev fixes the permissions shortly after the node appears.
+			time.Sleep(500 * time.Millisecond)
+			k.mu.Lock()
+			if k.running {
+				k.openDeviceLocked(event.Name)
+			}
+			k.mu.Unlock()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("EvdevKeyboard: device watcher error: %v", err)
+		}
+	}
+}
+
+// openDeviceLocked starts reading path if it is a keyboard. k.mu must be
+// held by the caller.
+func (k *EvdevKeyboard) openDeviceLocked(path string) {
+	if _, ok := k.devices[path]; ok {
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsPermission(err) {
+			log.Printf("EvdevKeyboard: open %s: %v", path, err)
+		}
+		return
+	}
+
+	name := deviceName(f)
+	if name == uinputDeviceName || !isKeyboard(f) {
+		f.Close()
+		return
+	}
+
+	log.Printf("EvdevKeyboard: reading %s (%s)", path, name)
+	k.devices[path] = f
+
+	// Caps Lock may already be on; the decoder only sees it toggle.
+	if caps, ok := capsLockLit(f); ok {
+		select {
+		case k.events <- evdevKeyEvent{capsLock: &caps}:
+		default:
+		}
+	}
+	go func() {
+		// Unplugging the device ends the read with ENODEV.
+		if err := readEvdevEvents(f, k.events); err != nil && !errors.Is(err, os.ErrClosed) {
+			log.Printf("EvdevKeyboard: %s closed: %v", path, err)
+		}
+		k.mu.Lock()
+		if k.devices[path] == f {
+			delete(k.devices, path)
+			f.Close()
+		}
+		k.mu.Unlock()
+	}()
+}
+
+// deviceName returns the name the driver reports for the device.
+func deviceName(f *os.File) string {
+	var buf [256]byte
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), eviocgname(uintptr(len(buf))), uintptr(unsafe.Pointer(&buf[0]))); errno != 0 {
+		return ""
+	}
+	return string(bytes.TrimRight(buf[:], "\x00"))
+}
+
+// capsLockLit reads the device's Caps Lock LED. ok is false if the device
+// has no LEDs to read.
+func capsLockLit(f *os.File) (lit, ok bool) {
+	var leds [2]byte // (LED_MAX + 1) / 8
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), eviocgled(uintptr(len(leds))), uintptr(unsafe.Pointer(&leds[0]))); errno != 0 {
+		return false, false
+	}
+	return leds[0]&ledCapsLock != 0, true
+}
+
+// isKeyboard reports whether the device has letter keys, which tells
+// keyboards apart from mice, power buttons and the like.
+func isKeyboard(f *os.File) bool {
+	var bits [64]byte // KEY_MAX / 8
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), eviocgbit(evKey, uintptr(len(bits))), uintptr(unsafe.Pointer(&bits[0]))); errno != 0 {
+		return false
+	}
+	has := func(code uint16) bool { return bits[code/8]&(1<<(code%8)) != 0 }
+	return has(30) && has(44) && has(evKeySpace) // A, Z, Space
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package expander
+
+import "errors"
+
+// Start fails: evdev is only available on Linux.
+func (k *EvdevKeyboard) Start() error {
+	return errors.New("evdev input backend is only supported on Linux")
+}
+
+// Stop does nothing.
+func (k *EvdevKeyboard) Stop() {}
@@ -0,0 +1,180 @@
+package expander
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// evdevRecord is one struct input_event written to a synthetic device file.
+type evdevRecord struct {
+	typ, code uint16
+	value     int32
+}
+
+// Helpers building records for a key press, release and tap.
+func evDown(code uint16) evdevRecord { return evdevRecord{evKey, code, 1} }
+func evUp(code uint16) evdevRecord   { return evdevRecord{evKey, code, 0} }
+func evTap(code uint16) []evdevRecord {
+	// Real devices interleave EV_MSC scan codes and EV_SYN reports.
+	return []evdevRecord{{0x04, 4, 0x70000 + int32(code)}, evDown(code), {evSyn, synReport, 0}, evUp(code), {evSyn, synReport, 0}}
+}
+
+// writeEvdevFile writes records in the kernel's binary format.
+func writeEvdevFile(t *testing.T, groups ...[]evdevRecord) string {
+	t.Helper()
+
+	var data []byte
+	for _, g := range groups {
+		for _, r := range g {
+			var buf [evdevEventSize]byte
+			binary.LittleEndian.PutUint16(buf[evdevTypeOff:], r.typ)
+			binary.LittleEndian.PutUint16(buf[evdevCodeOff:], r.code)
+			binary.LittleEndian.PutUint32(buf[evdevValueOff:], uint32(r.value))
+			data = append(data, buf[:]...)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "event0")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readEvdevFile plays a device file through kb and returns the keys typed.
+func readEvdevFile(t *testing.T, kb *EvdevKeyboard, path string) []string {
+	t.Helper()
+
+	var keys []string
+	kb.SetOnKeyPress(func(key string) { keys = append(keys, key) })
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events := make(chan evdevKeyEvent, 1024)
+	if err := readEvdevEvents(f, events); err != nil {
+		t.Fatalf("readEvdevEvents: %v", err)
+	}
+	close(events)
+
+	d := newEvdevDecoder(kb.layout)
+	for ev := range events {
+		kb.handle(d, ev)
+	}
+	return keys
+}
+
+func newTestEvdevKeyboard(t *testing.T, layout string) *EvdevKeyboard {
+	t.Helper()
+
+	kb, err := NewEvdevKeyboard(layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kb
+}
+
+// Linux key codes used below.
+const (
+	keyA, keyC, keyE, keyH, keyI, keyO, keyS = 30, 46, 18, 35, 23, 24, 31
+	key1, keyApostrophe, keySemicolon        = 2, 40, 39
+)
+
+func TestEvdevUSLayout(t *testing.T) {
+	kb := newTestEvdevKeyboard(t, LayoutUS)
+	path := writeEvdevFile(t,
+		[]evdevRecord{evDown(evKeyLeftShift)}, evTap(keySemicolon), evTap(keyH), []evdevRecord{evUp(evKeyLeftShift)},
+		evTap(keyI), evTap(key1),
+		evTap(evKeyCapsLock), evTap(keyA), evTap(evKeyCapsLock),
+		// Auto-repeat types the key again.
+		[]evdevRecord{evDown(keyO), {evKey, keyO, 2}, evUp(keyO)},
+		evTap(evKeyBackspace), evTap(evKeySpace), evTap(evKeyKPEnter),
+	)
+
+	got := readEvdevFile(t, kb, path)
+	want := []string{":", "H", "i", "1", "A", "o", "o", KeyBackspace, KeySpace, KeyEnter}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEvdevCapsLockStateAtOpen(t *testing.T) {
+	kb := newTestEvdevKeyboard(t, LayoutUS)
+	var keys []string
+	kb.SetOnKeyPress(func(key string) { keys = append(keys, key) })
+
+	on := true
+	d := newEvdevDecoder(kb.layout)
+	kb.handle(d, evdevKeyEvent{capsLock: &on})
+	kb.handle(d, evdevKeyEvent{code: keyA, value: 1})
+
+	if want := []string{"A"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("got %q, want %q", keys, want)
+	}
+}
+
+func TestEvdevUSIntlDeadKeysAndAltGr(t *testing.T) {
+	kb := newTestEvdevKeyboard(t, LayoutUSIntl)
+	path := writeEvdevFile(t,
+		evTap(keyApostrophe), evTap(keyE), // é
+		evTap(keyApostrophe), evTap(keyS), // 's
+		evTap(keyApostrophe), evTap(evKeySpace), // '
+		evTap(keyApostrophe), evTap(keyC), // ç
+		[]evdevRecord{evDown(evKeyLeftShift)}, evTap(keyApostrophe), []evdevRecord{evUp(evKeyLeftShift)}, evTap(keyO), // ö
+		evTap(keyApostrophe), evTap(evKeyBackspace), evTap(keyA), // dead key cancelled
+		[]evdevRecord{evDown(evKeyRightAlt)}, evTap(keyS), // ß
+		[]evdevRecord{evDown(evKeyLeftShift)}, evTap(keyE), []evdevRecord{evUp(evKeyLeftShift), evUp(evKeyRightAlt)}, // É
+	)
+
+	got := readEvdevFile(t, kb, path)
+	want := []string{"é", "'", "s", "'", "ç", "ö", "a", "ß", "É"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEvdevShortcutsAndHotkeys(t *testing.T) {
+	kb := newTestEvdevKeyboard(t, LayoutUS)
+	fired := make(chan struct{}, 2)
+	kb.SetHotkeys(map[Hotkey]func(){
+		{Keycode: 0x1F, Ctrl: true, Alt: true}: func() { fired <- struct{}{} },
+	})
+
+	path := writeEvdevFile(t,
+		[]evdevRecord{evDown(evKeyLeftCtrl)}, evTap(keyC), // Ctrl+C is not text
+		[]evdevRecord{evDown(evKeyLeftAlt)}, evTap(keyS), []evdevRecord{evUp(evKeyLeftAlt), evUp(evKeyLeftCtrl)},
+		evTap(keyS),
+	)
+
+	got := readEvdevFile(t, kb, path)
+	if want := []string{"s"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the hotkey to fire")
+	}
+}
+
+func TestEvdevHotkeyCodesMatchHook(t *testing.T) {
+	d := newEvdevDecoder(evdevLayout{})
+	for name, code := range map[string]uint16{"a": keyA, "f12": 88, "home": evKeyHome, "down": evKeyDown, "insert": evKeyInsert} {
+		if got, want := d.hotkey(code).Keycode, hotkeyKeycodes[name]; got != want {
+			t.Errorf("%s: evdev code %d maps to %#x, want %#x", name, code, got, want)
+		}
+	}
+}
+
+func TestNewEvdevKeyboardRejectsUnknownLayout(t *testing.T) {
+	if _, err := NewEvdevKeyboard("dvorak"); err == nil {
+		t.Fatalf("expected an error for an unknown layout")
+	}
+}
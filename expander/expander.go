@@ -1,14 +1,15 @@
 package expander
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"sync"
 	"time"
 	"unicode/utf8"
 
-	"github.com/go-vgo/robotgo"
-
 	"text-expander/config"
 	"text-expander/utils"
 )
@@ -16,50 +17,140 @@ import (
 // Expansion is an alias to the config-level Expansion type for convenience.
 type Expansion = config.Expansion
 
-// Expander ties together buffer management, keyboard hooks, template
-// processing, and configuration to implement text expansion.
+// allowExpansion decides whether an expansion may run in the current
+// context. It is a variable so tests can bypass the window checks.
+var allowExpansion = utils.ShouldAllowExpansion
+
+// Expander ties together buffer management, keyboard hooks, output
+// injection, template processing, and configuration to implement text
+// expansion.
 type Expander struct {
 	expansions map[string]Expansion
 	buffer     *Buffer
 	keyboard   Keyboard
+	injector   Injector
 	config     *config.Config
 	template   *TemplateProcessor
 	logger     *utils.Logger
 
-	mu          sync.RWMutex
-	running     bool
-	inExpansion bool
-	notifyFunc  func(trigger, replacement string) // Callback for notifications
+	// events feeds key presses to the worker goroutine, which owns the
+	// buffer and performs expansions one at a time. Sends hold sendMu for
+	// reading so Close can close it once closed is set.
+	events chan keyEvent
+	sendMu sync.RWMutex
+	closed bool
+	// echo lists, in order, the logical keys the last expansion injected
+	// that have not yet come back through the hook.
+	echo      []string
+	echoUntil time.Time
+	// retriggers holds triggers found in the last expansion's output, which
+	// may not fire again until retriggerUntil.
+	retriggers     map[string]bool
+	retriggerUntil time.Time
+
+	// window and allow report the focused window and whether expanding is
+	// permitted; simulations replace them.
+	window func() windowInfo
+	allow  func() bool
+	// echoes is false when the keyboard never sees injected input, so no
+	// echo must be expected.
+	echoes bool
+
+	mu         sync.RWMutex
+	running    bool
+	cancel     context.CancelFunc                // Aborts the running expansion, if any
+	notifyFunc func(trigger, replacement string) // Callback for notifications
+	abortFunc  func(trigger string, err error)   // Callback for aborted expansions
+	hotkeyFunc func(action string)               // Callback for global hotkeys
+	inputErr   error                             // Why the configured input backend is not used
+	stopWatch  func()                            // Stops watching the config file
+	// configErr is why the last reload failed, nil once a reload succeeds;
+	// configErrFunc is told whenever it changes.
+	configErr     error
+	configErrFunc func(err error)
+	// suggestFunc is told about inline suggestions; suggestPartial and
+	// suggestions are the last ones reported and belong to the worker.
+	suggestFunc    func(partial string, exps []Expansion)
+	suggestPartial string
+	suggestions    []Expansion
+	// sizeConfirmed waives the output size limit for the expansion the
+	// worker is running, once the user has confirmed it.
+	sizeConfirmed bool
+
+	pausedUntil time.Time
 }
 
-// NewExpander constructs a new Expander for the given configuration.
+// injectionObserver is implemented by keyboards that know whether input sent
+// through the Injector is reported back to them. Keyboards that do not
+// implement it are assumed to see it, as a global hook does.
+type injectionObserver interface {
+	ObservesInjected() bool
+}
+
+// ErrFocusChanged is reported when the focused window changes between
+// matching a trigger and typing its replacement.
+var ErrFocusChanged = errors.New("focused window changed")
+
+// ErrInputFallback is returned by Start, wrapped with the reason, when the
+// configured input backend could not be used and the keyboard hook was
+// started instead. The expander is running when it is returned.
+var ErrInputFallback = errors.New("input backend unavailable, using the keyboard hook")
+
+// NewExpander constructs a new Expander for the given configuration, using
+// the input and output backends selected in its settings.
 func NewExpander(cfg *config.Config) *Expander {
-	return NewExpanderWithKeyboard(cfg, NewKeyboardHook())
+	var kb Keyboard = NewKeyboardHook()
+	var inputErr error
+	if cfg != nil {
+		settings := cfg.GetSettings()
+		k, err := NewKeyboard(settings.InputBackend, settings.KeyboardLayout)
+		if err != nil {
+			log.Printf("input backend %q unavailable, using the hook: %v", settings.InputBackend, err)
+			inputErr = err
+		} else {
+			kb = k
+		}
+	}
+	e := NewExpanderWithKeyboard(cfg, kb)
+	e.inputErr = inputErr
+
+	if cfg != nil {
+		backend := cfg.GetSettings().OutputBackend
+		inj, err := NewInjector(backend)
+		if err != nil {
+			log.Printf("output backend %q unavailable, using robotgo: %v", backend, err)
+		} else {
+			e.SetInjector(inj)
+		}
+	}
+	return e
 }
 
 // NewExpanderWithKeyboard allows injecting a custom keyboard implementation;
-// this is primarily useful for testing.
+// this is primarily useful for testing. Output goes through robotgo until
+// SetInjector is called.
 func NewExpanderWithKeyboard(cfg *config.Config, kb Keyboard) *Expander {
 	e := &Expander{
 		expansions: make(map[string]Expansion),
 		buffer:     NewBuffer(50),
 		keyboard:   kb,
+		injector:   NewRobotInjector(),
 		config:     cfg,
 		template:   NewTemplateProcessor(),
+		events:     make(chan keyEvent, eventQueueSize),
+		window:     func() windowInfo { return activeWindow() },
+		allow:      func() bool { return allowExpansion() },
 	}
+	e.setKeyboardLocked(kb)
 
 	e.reloadFromConfigLocked()
-
-	// Wire keyboard callback.
-	if hook, ok := kb.(*KeyboardHook); ok {
-		hook.SetOnKeyPress(e.OnKeyPress)
-	}
+	go e.runWorker()
 
 	// Watch config file for changes and hot-reload.
 	if cfg != nil {
-		_ = cfg.Watch(func() {
-			e.ReloadConfig()
-		})
+		if stop, err := cfg.Watch(e.ReloadConfig); err == nil {
+			e.stopWatch = stop
+		}
 	}
 
 	return e
@@ -72,6 +163,13 @@ func (e *Expander) SetLogger(l *utils.Logger) {
 	e.logger = l
 }
 
+// SetInjector replaces the output backend used to type replacements.
+func (e *Expander) SetInjector(inj Injector) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.injector = inj
+}
+
 // SetNotificationCallback sets the function to call when an expansion occurs
 func (e *Expander) SetNotificationCallback(fn func(trigger, replacement string)) {
 	e.mu.Lock()
@@ -79,7 +177,48 @@ func (e *Expander) SetNotificationCallback(fn func(trigger, replacement string))
 	e.notifyFunc = fn
 }
 
-// Start begins monitoring keyboard events.
+// SetAbortCallback sets the function to call when an expansion is abandoned
+// part-way, for example because focus moved to another window. Like the
+// expansion notification it is only called when notifications are enabled,
+// and never when the user cancels with Escape.
+func (e *Expander) SetAbortCallback(fn func(trigger string, err error)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.abortFunc = fn
+}
+
+// SetConfigErrorCallback sets the function to call when a reload of the
+// configuration file fails, and with nil when a later reload succeeds.
+func (e *Expander) SetConfigErrorCallback(fn func(err error)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.configErrFunc = fn
+}
+
+// ConfigError returns why the configuration file could not be reloaded, or
+// nil if the last reload succeeded.
+func (e *Expander) ConfigError() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.configErr
+}
+
+// setKeyboardLocked makes kb the source of key presses. e.mu must be held
+// by the caller, who also registers the hotkeys with it.
+func (e *Expander) setKeyboardLocked(kb Keyboard) {
+	e.keyboard = kb
+	e.echoes = true
+	if o, ok := kb.(injectionObserver); ok {
+		e.echoes = o.ObservesInjected()
+	}
+	if src, ok := kb.(interface{ SetOnKeyPress(func(string)) }); ok {
+		src.SetOnKeyPress(e.OnKeyPress)
+	}
+}
+
+// Start begins monitoring keyboard events. If the configured input backend
+// cannot be started, the keyboard hook is started in its place and an error
+// wrapping ErrInputFallback is returned.
 func (e *Expander) Start() error {
 	e.mu.Lock()
 	if e.running {
@@ -88,12 +227,31 @@ func (e *Expander) Start() error {
 	}
 	e.running = true
 	kb := e.keyboard
+	inputErr := e.inputErr
 	e.mu.Unlock()
 
 	if kb == nil {
 		return nil
 	}
-	return kb.Start()
+	if err := kb.Start(); err != nil {
+		if _, isHook := kb.(*KeyboardHook); isHook {
+			return err
+		}
+		log.Printf("input backend unavailable, using the hook: %v", err)
+		hk := NewKeyboardHook()
+		e.mu.Lock()
+		e.setKeyboardLocked(hk)
+		e.registerHotkeysLocked()
+		e.mu.Unlock()
+		if herr := hk.Start(); herr != nil {
+			return herr
+		}
+		inputErr = err
+	}
+	if inputErr != nil {
+		return fmt.Errorf("%w: %v", ErrInputFallback, inputErr)
+	}
+	return nil
 }
 
 // Stop stops monitoring keyboard events.
@@ -112,14 +270,32 @@ func (e *Expander) Stop() {
 	}
 }
 
-// OnKeyPress is invoked by the keyboard hook for each key press.
-func (e *Expander) OnKeyPress(key string) {
-	// Avoid processing keys that are produced by our own simulated typing.
-	e.mu.RLock()
-	if e.inExpansion {
-		e.mu.RUnlock()
-		return
+// Close stops the expander for good: it stops monitoring the keyboard and
+// the configuration file, and the worker goroutine ends once the keys
+// already queued have been processed. Keys reported afterwards are ignored.
+func (e *Expander) Close() {
+	e.Stop()
+
+	e.mu.Lock()
+	stopWatch := e.stopWatch
+	e.stopWatch = nil
+	e.mu.Unlock()
+	if stopWatch != nil {
+		stopWatch()
 	}
+
+	e.sendMu.Lock()
+	defer e.sendMu.Unlock()
+	if !e.closed {
+		e.closed = true
+		close(e.events)
+	}
+}
+
+// processKey updates the buffer for a single key press and triggers
+// expansions. It runs on the worker goroutine.
+func (e *Expander) processKey(key string) {
+	e.mu.RLock()
 	cfg := e.config
 	e.mu.RUnlock()
 
@@ -128,6 +304,7 @@ func (e *Expander) OnKeyPress(key string) {
 		return
 	}
 	settings := cfg.GetSettings()
+	enabled := settings.Enabled && e.PausedUntil().IsZero()
 
 	// Debug: log key presses (limit to avoid spam)
 	if key != KeySpace && key != KeyEnter && key != KeyTab && key != KeyBackspace {
@@ -135,36 +312,40 @@ func (e *Expander) OnKeyPress(key string) {
 	}
 
 	switch key {
+	case KeyEscape:
+		// Escape aborts a running expansion (see OnKeyPress) and starts
+		// matching afresh.
+		e.buffer.Clear()
 	case KeyBackspace:
 		e.buffer.Remove()
 		log.Printf("[DEBUG] OnKeyPress: BACKSPACE, buffer after: %q", e.buffer.String())
 	case KeySpace:
 		bufBefore := e.buffer.String()
-		if settings.Enabled && settings.TriggerOnSpace {
+		e.buffer.Append(' ')
+		if enabled && settings.TriggerOnSpace {
 			log.Printf("[DEBUG] OnKeyPress: SPACE, checking expansion, buffer: %q", bufBefore)
-			e.CheckAndExpand()
+			e.checkAndExpand(bufBefore, string(' '))
 		} else {
-			log.Printf("[DEBUG] OnKeyPress: SPACE, expansion disabled (enabled=%v, triggerOnSpace=%v)", settings.Enabled, settings.TriggerOnSpace)
+			log.Printf("[DEBUG] OnKeyPress: SPACE, expansion disabled (enabled=%v, triggerOnSpace=%v)", enabled, settings.TriggerOnSpace)
 		}
-		e.buffer.Append(' ')
 	case KeyEnter:
 		bufBefore := e.buffer.String()
-		if settings.Enabled && settings.TriggerOnEnter {
+		e.buffer.Append('\n')
+		if enabled && settings.TriggerOnEnter {
 			log.Printf("[DEBUG] OnKeyPress: ENTER, checking expansion, buffer: %q", bufBefore)
-			e.CheckAndExpand()
+			e.checkAndExpand(bufBefore, string('\n'))
 		} else {
-			log.Printf("[DEBUG] OnKeyPress: ENTER, expansion disabled (enabled=%v, triggerOnEnter=%v)", settings.Enabled, settings.TriggerOnEnter)
+			log.Printf("[DEBUG] OnKeyPress: ENTER, expansion disabled (enabled=%v, triggerOnEnter=%v)", enabled, settings.TriggerOnEnter)
 		}
-		e.buffer.Append('\n')
 	case KeyTab:
 		bufBefore := e.buffer.String()
-		if settings.Enabled && settings.TriggerOnTab {
+		e.buffer.Append('\t')
+		if enabled && settings.TriggerOnTab {
 			log.Printf("[DEBUG] OnKeyPress: TAB, checking expansion, buffer: %q", bufBefore)
-			e.CheckAndExpand()
+			e.checkAndExpand(bufBefore, string('\t'))
 		} else {
-			log.Printf("[DEBUG] OnKeyPress: TAB, expansion disabled (enabled=%v, triggerOnTab=%v)", settings.Enabled, settings.TriggerOnTab)
+			log.Printf("[DEBUG] OnKeyPress: TAB, expansion disabled (enabled=%v, triggerOnTab=%v)", enabled, settings.TriggerOnTab)
 		}
-		e.buffer.Append('\t')
 	default:
 		runes := []rune(key)
 		if len(runes) == 1 {
@@ -176,7 +357,14 @@ func (e *Expander) OnKeyPress(key string) {
 // CheckAndExpand inspects the input buffer for any matching trigger and, if
 // found, performs the expansion.
 func (e *Expander) CheckAndExpand() {
-	bufferContent := e.buffer.String()
+	e.checkAndExpand(e.buffer.String(), "")
+}
+
+// checkAndExpand matches the buffer contents bufferContent against the
+// triggers. A non-empty terminator is the space, newline or tab that ended
+// the trigger; it already follows bufferContent in the target and the buffer
+// and is replaced along with the trigger.
+func (e *Expander) checkAndExpand(bufferContent, terminator string) {
 	log.Printf("[DEBUG] CheckAndExpand: buffer content: %q", bufferContent)
 
 	if bufferContent == "" {
@@ -202,74 +390,208 @@ func (e *Expander) CheckAndExpand() {
 	}
 
 	log.Printf("[DEBUG] CheckAndExpand: found match! trigger: %q, replacement: %q", exp.Trigger, exp.Replacement)
-	e.PerformExpansion(exp.Trigger, exp.Replacement)
+	if e.isRetrigger(exp.Trigger) {
+		log.Printf("CheckAndExpand: ignoring %q produced by the previous expansion", exp.Trigger)
+		return
+	}
+	e.performExpansion(exp, e.window(), exp.Trigger, terminator)
 }
 
-// PerformExpansion executes the delete-and-type sequence for a given trigger
-// and expansion template.
-func (e *Expander) PerformExpansion(trigger, expansion string) {
-	if trigger == "" || expansion == "" {
-		return
+// PerformExpansion executes the delete-and-type sequence for a given
+// expansion. Rich output formats are pasted through the clipboard instead of
+// being typed. Pressing Escape while it runs aborts the expansion, as does
+// focus moving away from the window that is focused now.
+func (e *Expander) PerformExpansion(exp Expansion) {
+	e.performExpansion(exp, e.window(), exp.Trigger, "")
+}
+
+// InsertAtCaret is PerformExpansion without the delete: the replacement of
+// exp is inserted at the caret, as when its hotkey is pressed.
+func (e *Expander) InsertAtCaret(exp Expansion) {
+	e.performExpansion(exp, e.window(), "", "")
+}
+
+// performExpansion runs an expansion aimed at the window target. The typed
+// text, the trigger or part of it, is deleted first together with the
+// terminator that ended it, which is typed again after the replacement when
+// the settings keep terminators.
+func (e *Expander) performExpansion(exp Expansion, target windowInfo, typed, terminator string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.resetEcho()
+	e.mu.Lock()
+	e.cancel = cancel
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.cancel = nil
+		e.mu.Unlock()
+		cancel()
+	}()
+
+	if err := e.expand(ctx, exp, target, typed, terminator); err != nil {
+		// The target's contents are unknown after a partial expansion, so
+		// start matching from scratch.
+		e.buffer.Clear()
+		log.Printf("expansion %q aborted: %v", exp.Name(), err)
+
+		e.mu.RLock()
+		logger := e.logger
+		abortFunc := e.abortFunc
+		showNotifications := e.config != nil && e.config.GetSettings().ShowNotifications
+		e.mu.RUnlock()
+		if logger != nil {
+			logger.LogError(fmt.Errorf("expansion %q aborted: %w", exp.Name(), err))
+		}
+		if showNotifications && abortFunc != nil && !errors.Is(err, context.Canceled) {
+			go abortFunc(exp.Name(), err)
+		}
 	}
+}
 
-	if !utils.ShouldAllowExpansion() {
-		return
+// expand performs an expansion into the window target, stopping early with
+// ctx.Err() when the context is cancelled and with ErrFocusChanged when
+// target loses focus.
+func (e *Expander) expand(ctx context.Context, exp Expansion, target windowInfo, typed, terminator string) error {
+	trigger := exp.Name()
+	if trigger == "" || exp.Replacement == "" {
+		return nil
+	}
+
+	if !e.allow() {
+		return nil
 	}
 
 	e.mu.RLock()
 	tp := e.template
+	inj := e.injector
 	logger := e.logger
 	cfg := e.config
 	e.mu.RUnlock()
 
-	if tp == nil {
-		return
+	if tp == nil || inj == nil {
+		return nil
 	}
 
-	text, cursorOffset := tp.Process(expansion)
+	text, cursorOffset := tp.Process(exp.Replacement)
 	if text == "" {
-		return
+		return nil
 	}
 
-	// Signal that we're in the middle of an expansion so we can ignore
-	// synthetic key events from robotgo.
-	e.mu.Lock()
-	e.inExpansion = true
-	e.mu.Unlock()
+	var settings config.Settings
+	var profiles []config.InjectionProfile
+	if cfg != nil {
+		settings = cfg.GetSettings()
+		profiles = cfg.GetProfiles()
+	}
 
-	defer func() {
-		e.mu.Lock()
-		e.inExpansion = false
-		e.mu.Unlock()
-	}()
+	if err := checkOutputSize(text, settings); err != nil {
+		if !settings.ConfirmLargeOutput {
+			return err
+		}
+		if !e.sizeConfirmed {
+			e.confirmThenExpand(exp, target, typed, terminator, utf8.RuneCountInString(text))
+			return nil
+		}
+	}
+
+	// Output that contains a trigger followed by a terminator would expand
+	// again if its echo reached the matcher; never let that happen.
+	e.mu.RLock()
+	retriggers := findRetriggers(text, e.expansions, terminators(settings))
+	e.mu.RUnlock()
+	if len(retriggers) > 0 {
+		log.Printf("expansion %q output contains triggers %q; they will not re-expand", trigger, retriggers)
+	}
+	e.guardRetriggers(retriggers)
+
+	// Look up the injection profile for the target application.
+	profile, ok := matchProfile(profiles, target)
+	if ok {
+		log.Printf("[DEBUG] PerformExpansion: using injection profile %q", profile.Name)
+	}
+
+	deleteLen := utf8.RuneCountInString(typed) + utf8.RuneCountInString(terminator)
 
-	triggerLen := utf8.RuneCountInString(trigger)
+	// Reading {CLIPBOARD} and waiting in the queue behind earlier events
+	// take time; make sure the trigger is still in front of us.
+	if err := e.checkFocus(target); err != nil {
+		return err
+	}
 
-	// Delete the trigger.
-	if triggerLen > 0 && e.keyboard != nil {
-		e.keyboard.SimulateBackspace(triggerLen)
-		for i := 0; i < triggerLen; i++ {
+	// Delete the trigger and its terminator.
+	if deleteLen > 0 {
+		if err := e.deleteWithProfile(ctx, inj, deleteLen, profile); err != nil {
+			return err
+		}
+		for i := 0; i < deleteLen; i++ {
 			e.buffer.Remove()
 		}
 	}
 
-	// Type the replacement.
-	if e.keyboard != nil {
-		e.keyboard.SimulateTyping(text)
-		for _, r := range []rune(text) {
+	// Insert the replacement. Rich formats are always pasted; plain text is
+	// typed or pasted depending on the expansion's injection strategy.
+	var paste *ClipboardContent
+	if isRichFormat(exp.OutputFormat) {
+		htmlOut, plain, plainOffset := renderRich(exp.OutputFormat, text, cursorOffset)
+		text, cursorOffset = plain, plainOffset
+		paste = &ClipboardContent{Text: plain, HTML: htmlOut}
+	} else if chooseStrategy(exp, text, settings, profile) == StrategyPaste {
+		paste = &ClipboardContent{Text: text}
+	}
+
+	if err := e.checkFocus(target); err != nil {
+		return err
+	}
+
+	pasted := false
+	if paste != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e.expectEcho(shortcutEcho(profile.PasteShortcut))
+		if err := inj.Paste(*paste, profile.PasteShortcut); err != nil {
+			log.Printf("paste failed, typing instead: %v", err)
+			if logger != nil {
+				logger.LogError(err)
+			}
+		} else {
+			pasted = true
+		}
+	}
+
+	if !pasted {
+		if err := e.typeWithProfile(ctx, inj, text, profile); err != nil {
+			return err
+		}
+	}
+	for _, r := range []rune(text) {
+		e.buffer.Append(r)
+	}
+
+	if terminator != "" && settings.KeepTerminator {
+		if err := e.typeWithProfile(ctx, inj, terminator, profile); err != nil {
+			return err
+		}
+		for _, r := range terminator {
 			e.buffer.Append(r)
 		}
+		if cursorOffset > 0 {
+			cursorOffset += utf8.RuneCountInString(terminator)
+		}
 	}
 
-	// Move cursor to requested position using left-arrow taps.
-	for i := 0; i < cursorOffset; i++ {
-		_ = robotgo.KeyTap(robotgo.Left)
-		time.Sleep(2 * time.Millisecond)
+	// Move cursor to the requested position.
+	if cursorOffset > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_ = inj.MoveCursor(-cursorOffset, keyDelay(profile))
 	}
 
 	// Log usage.
-	if logger != nil && cfg != nil && cfg.GetSettings().LogExpansions {
-		logger.LogExpansion(trigger)
+	if logger != nil && settings.LogExpansions {
+		logger.LogExpansion(exp.ID, trigger)
 	}
 
 	// Show notification if enabled
@@ -281,9 +603,12 @@ func (e *Expander) PerformExpansion(trigger, expansion string) {
 	if showNotifications && notifyFunc != nil {
 		go notifyFunc(trigger, text)
 	}
+	return nil
 }
 
 // ReloadConfig reloads configuration from disk when the config file changes.
+// A file that cannot be loaded leaves the last good configuration in use and
+// is reported through the config error callback until it is fixed.
 func (e *Expander) ReloadConfig() {
 	e.mu.RLock()
 	cfg := e.config
@@ -298,18 +623,41 @@ func (e *Expander) ReloadConfig() {
 		return
 	}
 
-	newCfg, err := config.LoadConfig(path)
-	if err != nil {
+	// The configuration is reloaded in place, so everything holding it,
+	// such as the tray menu, sees the new settings.
+	if err := cfg.Reload(); err != nil {
 		if l := e.logger; l != nil {
 			l.LogError(err)
 		}
+		e.setConfigError(err)
 		return
 	}
+	for _, w := range cfg.Warnings() {
+		log.Printf("%s: warning: %s", path, w)
+	}
 
 	e.mu.Lock()
-	e.config = newCfg
 	e.reloadFromConfigLocked()
 	e.mu.Unlock()
+	e.setConfigError(nil)
+}
+
+// setConfigError records the outcome of a reload and tells the callback if
+// it changed.
+func (e *Expander) setConfigError(err error) {
+	e.mu.Lock()
+	prev := e.configErr
+	e.configErr = err
+	fn := e.configErrFunc
+	e.mu.Unlock()
+
+	if fn == nil || (err == nil && prev == nil) {
+		return
+	}
+	if err != nil && prev != nil && err.Error() == prev.Error() {
+		return
+	}
+	fn(err)
 }
 
 // reloadFromConfigLocked refreshes internal state from the current config.
@@ -319,16 +667,29 @@ func (e *Expander) reloadFromConfigLocked() {
 		return
 	}
 
-	exps := e.config.GetExpansions()
+	exps := e.config.AllExpansions()
 	m := make(map[string]Expansion, len(exps))
 	for _, exp := range exps {
-		m[exp.Trigger] = exp
+		// Hotkey-only expansions are registered with the keyboard instead.
+		// A trigger given twice keeps its first expansion.
+		if _, dup := m[exp.Trigger]; exp.Trigger != "" && !dup {
+			m[exp.Trigger] = exp
+		}
 	}
 	e.expansions = m
 
+	for _, err := range e.config.PackConflicts() {
+		log.Printf("snippet pack: %v", err)
+		if e.logger != nil {
+			e.logger.LogError(err)
+		}
+	}
+
 	if e.template != nil {
 		e.template.SetCustomVars(e.config.GetCustomVars())
 	}
+
+	e.registerHotkeysLocked()
 }
 
 // matchExpansion finds the best matching expansion for the given buffer
@@ -1,7 +1,16 @@
 package expander
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	hook "github.com/robotn/gohook"
 
 	"text-expander/config"
 )
@@ -66,3 +75,694 @@ func TestNewExpanderInitialisesFromConfig(t *testing.T) {
 		t.Fatalf("expected expansion ';x' to be loaded into expander")
 	}
 }
+
+func TestChooseStrategy(t *testing.T) {
+	settings := config.Settings{PasteThreshold: 10}
+
+	cases := []struct {
+		name string
+		exp  Expansion
+		text string
+		want InjectionStrategy
+	}{
+		{"short auto", Expansion{}, "short", StrategyType},
+		{"long auto", Expansion{}, "this is longer than ten", StrategyPaste},
+		{"forced type", Expansion{InjectMode: config.InjectModeType}, "this is longer than ten", StrategyType},
+		{"forced paste", Expansion{InjectMode: config.InjectModePaste}, "short", StrategyPaste},
+	}
+
+	for _, tc := range cases {
+		if got := chooseStrategy(tc.exp, tc.text, settings, config.InjectionProfile{}); got != tc.want {
+			t.Errorf("%s: chooseStrategy = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if got := chooseStrategy(Expansion{}, "this is longer than ten", config.Settings{}, config.InjectionProfile{}); got != StrategyType {
+		t.Errorf("zero threshold should disable automatic paste, got %v", got)
+	}
+}
+
+func TestMatchProfile(t *testing.T) {
+	profiles := []config.InjectionProfile{
+		{Name: "terminal", Process: "WindowsTerminal.exe", PasteShortcut: "ctrl+shift+v"},
+		{Name: "webmail", WindowTitle: "Gmail", TypingDelay: 10},
+	}
+
+	p, ok := matchProfile(profiles, windowInfo{Title: "PowerShell", Process: `C:\Program Files\WindowsTerminal.exe`})
+	if !ok || p.Name != "terminal" {
+		t.Fatalf("expected terminal profile, got %q (ok=%v)", p.Name, ok)
+	}
+
+	p, ok = matchProfile(profiles, windowInfo{Title: "Inbox - gmail - Firefox", Process: "firefox"})
+	if !ok || p.Name != "webmail" {
+		t.Fatalf("expected webmail profile, got %q (ok=%v)", p.Name, ok)
+	}
+
+	if _, ok := matchProfile(profiles, windowInfo{Title: "Untitled - Notepad", Process: "notepad.exe"}); ok {
+		t.Fatalf("expected no profile for notepad")
+	}
+}
+
+func TestSplitShortcut(t *testing.T) {
+	key, mods := splitShortcut("Ctrl+Shift+V")
+	if key != "v" || len(mods) != 2 || mods[0] != "ctrl" || mods[1] != "shift" {
+		t.Fatalf("unexpected split: key=%q mods=%v", key, mods)
+	}
+
+	if key, _ := splitShortcut(""); key != "" {
+		t.Fatalf("expected empty key for empty shortcut, got %q", key)
+	}
+}
+
+// newTestExpander builds an expander wired to an in-memory injector with the
+// window and security checks stubbed out.
+func newTestExpander(t *testing.T, cfg *config.Config) (*Expander, *MemoryInjector) {
+	t.Helper()
+
+	origAllow, origWindow := allowExpansion, activeWindow
+	allowExpansion = func() bool { return true }
+	activeWindow = func() windowInfo { return windowInfo{Title: "test"} }
+	t.Cleanup(func() {
+		allowExpansion, activeWindow = origAllow, origWindow
+	})
+
+	e := NewExpanderWithKeyboard(cfg, &KeyboardHook{})
+	t.Cleanup(e.Close)
+	inj := NewMemoryInjector()
+	e.SetInjector(inj)
+	return e, inj
+}
+
+func typeKeys(e *Expander, text string) {
+	for _, r := range text {
+		switch r {
+		case ' ':
+			e.OnKeyPress(KeySpace)
+		case '\n':
+			e.OnKeyPress(KeyEnter)
+		default:
+			e.OnKeyPress(string(r))
+		}
+	}
+}
+
+func TestPerformExpansionUsesInjector(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";hi", Replacement: "Hello{CURSOR}!"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true},
+	}
+
+	e, inj := newTestExpander(t, cfg)
+	typeKeys(e, ";hi ")
+	e.WaitIdle()
+
+	events := inj.Events()
+	var backspaces int
+	var typed string
+	var moved int
+	for _, ev := range events {
+		switch {
+		case ev.Kind == "tap" && ev.Key == InjectBackspace:
+			backspaces++
+		case ev.Kind == "type":
+			typed += ev.Text
+		case ev.Kind == "move":
+			moved += ev.Delta
+		}
+	}
+
+	if backspaces != 4 {
+		t.Fatalf("expected 4 backspaces, got %d (%+v)", backspaces, events)
+	}
+	if typed != "Hello!" {
+		t.Fatalf("expected typed text %q, got %q", "Hello!", typed)
+	}
+	if moved != -1 {
+		t.Fatalf("expected cursor move of -1, got %d", moved)
+	}
+}
+
+func TestKeepTerminatorRetypesIt(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";hi", Replacement: "Hello{CURSOR}!"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnEnter: true, KeepTerminator: true},
+	}
+
+	e, inj := newTestExpander(t, cfg)
+	typeKeys(e, ";hi")
+	e.OnKeyPress(KeyEnter)
+	e.WaitIdle()
+
+	var typed string
+	var moved int
+	for _, ev := range inj.Events() {
+		switch ev.Kind {
+		case "type":
+			typed += ev.Text
+		case "move":
+			moved += ev.Delta
+		}
+	}
+	if typed != "Hello!\n" {
+		t.Fatalf("expected typed text %q, got %q", "Hello!\n", typed)
+	}
+	if moved != -2 {
+		t.Fatalf("expected cursor move of -2, got %d", moved)
+	}
+	if got := e.buffer.String(); got != "Hello!\n" {
+		t.Fatalf("expected the kept terminator in the buffer, got %q", got)
+	}
+}
+
+func TestDuplicateTriggerKeepsFirstExpansion(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";hi", Replacement: "first"},
+			{Trigger: ";hi", Replacement: "second"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true},
+	}
+
+	e, inj := newTestExpander(t, cfg)
+	typeKeys(e, ";hi ")
+	e.WaitIdle()
+
+	var typed string
+	for _, ev := range inj.Events() {
+		if ev.Kind == "type" {
+			typed += ev.Text
+		}
+	}
+	if typed != "first" {
+		t.Fatalf("expected the first expansion, got %q", typed)
+	}
+}
+
+func TestPerformExpansionDeletesTrigger(t *testing.T) {
+	cfg := &config.Config{Settings: config.Settings{Enabled: true}}
+	e, inj := newTestExpander(t, cfg)
+
+	e.PerformExpansion(Expansion{Trigger: ";sig", Replacement: "Regards"})
+
+	var backspaces int
+	for _, ev := range inj.Events() {
+		if ev.Kind == "tap" && ev.Key == InjectBackspace {
+			backspaces++
+		}
+	}
+	if backspaces != len(";sig") {
+		t.Fatalf("expected the trigger to be deleted, got %d backspaces (%+v)", backspaces, inj.Events())
+	}
+}
+
+// gatedInjector wraps a MemoryInjector and blocks the first TypeText call
+// until release is closed, signalling started when it is reached.
+type gatedInjector struct {
+	*MemoryInjector
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newGatedInjector() *gatedInjector {
+	return &gatedInjector{
+		MemoryInjector: NewMemoryInjector(),
+		started:        make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+}
+
+func (g *gatedInjector) TypeText(text string, delay time.Duration) error {
+	err := g.MemoryInjector.TypeText(text, delay)
+	g.once.Do(func() {
+		close(g.started)
+		<-g.release
+	})
+	return err
+}
+
+func TestKeysTypedMidExpansionReachBufferAfterIt(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";hi", Replacement: "Hello!"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true},
+	}
+
+	e, _ := newTestExpander(t, cfg)
+	inj := newGatedInjector()
+	e.SetInjector(inj)
+
+	typeKeys(e, ";hi ")
+	<-inj.started
+
+	// The user keeps typing while the replacement is being injected.
+	typeKeys(e, "ab")
+	if got := e.buffer.String(); got != "" {
+		t.Fatalf("buffer changed mid-expansion: %q", got)
+	}
+
+	close(inj.release)
+	e.WaitIdle()
+
+	// Only the buffer sees the keys after the replacement; the focused
+	// application received them as they were typed.
+	if got := e.buffer.String(); got != "Hello!ab" {
+		t.Fatalf("expected the keys after the replacement, got buffer %q", got)
+	}
+}
+
+func TestCloseStopsTheWorker(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";hi", Replacement: "Hello!"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true},
+	}
+
+	e, inj := newTestExpander(t, cfg)
+	e.Close()
+	e.Close()
+
+	// Keys reported after Close are ignored rather than panicking.
+	typeKeys(e, ";hi ")
+	e.WaitIdle()
+	if events := inj.Events(); len(events) != 0 {
+		t.Fatalf("expected no output after Close, got %+v", events)
+	}
+}
+
+func TestEscapeAbortsExpansion(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";long", Replacement: "0123456789abcdefghij{CURSOR}"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true},
+	}
+
+	e, _ := newTestExpander(t, cfg)
+	inj := newGatedInjector()
+	e.SetInjector(inj)
+
+	typeKeys(e, ";long ")
+	<-inj.started
+	e.OnKeyPress(KeyEscape)
+	close(inj.release)
+	e.WaitIdle()
+
+	var typed string
+	for _, ev := range inj.Events() {
+		switch ev.Kind {
+		case "type":
+			typed += ev.Text
+		case "move":
+			t.Fatalf("cursor moved after abort: %+v", inj.Events())
+		}
+	}
+	if typed != "01234567" {
+		t.Fatalf("expected only the first chunk to be typed, got %q", typed)
+	}
+	if got := e.buffer.String(); got != "" {
+		t.Fatalf("expected buffer to be cleared after abort, got %q", got)
+	}
+}
+
+func TestEchoFilterMatchesInOrder(t *testing.T) {
+	e, _ := newTestExpander(t, &config.Config{})
+
+	e.expectEcho("a", "b", "c")
+	steps := []struct {
+		key  string
+		echo bool
+	}{
+		{"a", true},
+		{"x", false}, // real key typed between injected ones
+		{"c", false}, // real key equal to a later injected one
+		{"b", true},
+		{"c", true},
+		{"c", false},
+	}
+	for i, s := range steps {
+		if got := e.isEcho(s.key); got != s.echo {
+			t.Fatalf("step %d: isEcho(%q) = %v, want %v", i, s.key, got, s.echo)
+		}
+	}
+}
+
+func TestInjectedKeysAreFilteredButUserKeysKept(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";hi", Replacement: "Hi!"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true},
+	}
+
+	e, _ := newTestExpander(t, cfg)
+	typeKeys(e, ";hi ")
+
+	// The hook reports the injected keys with a real key press mixed in.
+	for _, k := range []string{KeyBackspace, KeyBackspace, "x", KeyBackspace, KeyBackspace, "H", "i", "!"} {
+		e.OnKeyPress(k)
+	}
+	e.WaitIdle()
+
+	if got := e.buffer.String(); got != "Hi!x" {
+		t.Fatalf("expected only the user key to reach the buffer, got %q", got)
+	}
+}
+
+func TestExpansionAbortsWhenFocusChanges(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";hi", Replacement: "Hello"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true, ShowNotifications: true},
+	}
+
+	e, inj := newTestExpander(t, cfg)
+
+	// The editor is focused when the trigger matches; a dialog steals focus
+	// before anything is typed.
+	var calls int
+	activeWindow = func() windowInfo {
+		calls++
+		if calls == 1 {
+			return windowInfo{Handle: 1, Title: "editor"}
+		}
+		return windowInfo{Handle: 2, Title: "dialog"}
+	}
+
+	aborted := make(chan error, 1)
+	e.SetAbortCallback(func(trigger string, err error) { aborted <- err })
+
+	typeKeys(e, ";hi ")
+	e.WaitIdle()
+
+	if events := inj.Events(); len(events) != 0 {
+		t.Fatalf("expected nothing to be injected, got %+v", events)
+	}
+	select {
+	case err := <-aborted:
+		if !errors.Is(err, ErrFocusChanged) {
+			t.Fatalf("expected ErrFocusChanged, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("abort callback was not called")
+	}
+}
+
+func TestCheckOutputSize(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings config.Settings
+		wantErr  bool
+	}{
+		{"unlimited", config.Settings{}, false},
+		{"within limit", config.Settings{MaxOutputSize: 10}, false},
+		{"over limit", config.Settings{MaxOutputSize: 3}, true},
+	}
+	for _, c := range cases {
+		err := checkOutputSize("hello", c.settings)
+		if (err != nil) != c.wantErr {
+			t.Fatalf("%s: unexpected error %v", c.name, err)
+		}
+		if err != nil && !errors.Is(err, ErrOutputTooLarge) {
+			t.Fatalf("%s: expected ErrOutputTooLarge, got %v", c.name, err)
+		}
+	}
+}
+
+func TestLargeOutputIsConfirmedOffTheWorker(t *testing.T) {
+	orig := confirmLargeOutput
+	t.Cleanup(func() { confirmLargeOutput = orig })
+
+	asked := make(chan struct{})
+	answer := make(chan bool)
+	confirmLargeOutput = func(string, int) bool {
+		close(asked)
+		return <-answer
+	}
+
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";big", Replacement: "0123456789"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true, MaxOutputSize: 5, ConfirmLargeOutput: true},
+	}
+	e, inj := newTestExpander(t, cfg)
+
+	typeKeys(e, ";big ")
+	<-asked
+	// The worker is free while the dialog is open.
+	typeKeys(e, "x")
+	e.WaitIdle()
+	if events := inj.Events(); len(events) != 0 {
+		t.Fatalf("expected nothing injected before confirmation, got %+v", events)
+	}
+
+	answer <- true
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		e.WaitIdle()
+		var typed string
+		for _, ev := range inj.Events() {
+			if ev.Kind == "type" {
+				typed += ev.Text
+			}
+		}
+		if strings.Contains(typed, "0123456789") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("confirmed expansion was not inserted: %+v", inj.Events())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFindRetriggers(t *testing.T) {
+	exps := map[string]Expansion{
+		";a":  {Trigger: ";a"},
+		";B":  {Trigger: ";B", CaseSensitive: true},
+		";cc": {Trigger: ";cc"},
+	}
+
+	got := findRetriggers("see ;A then ;b\tand ;cc", exps, " \t")
+	if len(got) != 1 || got[0] != ";a" {
+		t.Fatalf("expected only ;a to retrigger, got %q", got)
+	}
+	if got := findRetriggers("x ;a y", exps, ""); got != nil {
+		t.Fatalf("expected no retriggers without terminators, got %q", got)
+	}
+}
+
+func TestSelfRetriggeringExpansionDoesNotLoop(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";loop", Replacement: "again ;loop and again"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true},
+	}
+
+	e, inj := newTestExpander(t, cfg)
+	typeKeys(e, ";loop ")
+	e.WaitIdle()
+
+	// The echo of the output is lost, so the injected text reaches the
+	// matcher as if it had been typed.
+	e.resetEcho()
+	typeKeys(e, "again ;loop ")
+	e.WaitIdle()
+
+	var backspaces int
+	for _, ev := range inj.Events() {
+		if ev.Kind == "tap" && ev.Key == InjectBackspace {
+			backspaces++
+		}
+	}
+	if backspaces != len(";loop ") {
+		t.Fatalf("expected a single expansion, got %d backspaces (%+v)", backspaces, inj.Events())
+	}
+}
+
+func TestHotkeyKeycodesCoverEveryKeyName(t *testing.T) {
+	names := append([]string{}, config.HotkeyKeys...)
+	for c := 'a'; c <= 'z'; c++ {
+		names = append(names, string(c))
+	}
+	for c := '0'; c <= '9'; c++ {
+		names = append(names, string(c))
+	}
+	for i := 1; i <= 12; i++ {
+		names = append(names, fmt.Sprintf("f%d", i))
+	}
+
+	seen := make(map[uint16]string)
+	for _, name := range names {
+		code, ok := hotkeyKeycodes[name]
+		if !ok {
+			t.Errorf("no key code for %q", name)
+			continue
+		}
+		if other, dup := seen[code]; dup {
+			t.Errorf("%q and %q share key code %#x", name, other, code)
+		}
+		seen[code] = name
+	}
+}
+
+func TestHotkeyFromEventMatchesBinding(t *testing.T) {
+	parsed, err := config.ParseHotkey("ctrl+alt+shift+p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewHotkey(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Left Ctrl, right Alt and right Shift.
+	ev := hook.Event{Kind: hook.KeyHold, Keycode: 0x19, Mask: 1<<1 | 1<<7 | 1<<4}
+	if got := hotkeyFromEvent(ev); got != want {
+		t.Fatalf("hotkeyFromEvent = %+v, want %+v", got, want)
+	}
+}
+
+func TestPauseSuspendsExpansion(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{{Trigger: ";hi", Replacement: "Hello"}},
+		Settings:   config.Settings{Enabled: true, TriggerOnSpace: true},
+	}
+
+	e, inj := newTestExpander(t, cfg)
+	e.Pause(time.Hour)
+	if e.PausedUntil().IsZero() {
+		t.Fatalf("expected expander to report a pause")
+	}
+	typeKeys(e, ";hi ")
+	e.WaitIdle()
+	if events := inj.Events(); len(events) != 0 {
+		t.Fatalf("expected no injection while paused, got %+v", events)
+	}
+
+	e.Resume()
+	typeKeys(e, ";hi ")
+	e.WaitIdle()
+	if len(inj.Events()) == 0 {
+		t.Fatalf("expected expansion after resuming")
+	}
+}
+
+func TestHotkeyExpansionInsertsAtCaret(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Hotkey: "ctrl+alt+s", Replacement: "Regards"},
+			{Trigger: ";hi", Replacement: "Hello"},
+		},
+		Settings: config.Settings{Enabled: true, TriggerOnSpace: true},
+	}
+
+	e, inj := newTestExpander(t, cfg)
+	kb := e.keyboard.(*KeyboardHook)
+	fn, ok := kb.hotkeys[Hotkey{Keycode: 0x1F, Ctrl: true, Alt: true}]
+	if !ok {
+		t.Fatalf("expected ctrl+alt+s to be registered, got %v", kb.hotkeys)
+	}
+	if _, ok := e.expansions[""]; ok {
+		t.Fatalf("hotkey-only expansion must not be matched as a typed trigger")
+	}
+
+	typeKeys(e, "ab")
+	e.WaitIdle()
+
+	// The hotkey's modifiers are still held when it fires; nothing is
+	// inserted until they are released.
+	kb.mu.Lock()
+	kb.mods = maskCtrl | maskAlt
+	kb.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("insertion did not wait for the modifiers to be released")
+	case <-time.After(50 * time.Millisecond):
+	}
+	kb.mu.Lock()
+	kb.mods = 0
+	kb.mu.Unlock()
+	<-done
+	e.WaitIdle()
+
+	var typed string
+	for _, ev := range inj.Events() {
+		switch {
+		case ev.Kind == "tap" && ev.Key == InjectBackspace:
+			t.Fatalf("hotkey expansion must not delete anything (%+v)", inj.Events())
+		case ev.Kind == "type":
+			typed += ev.Text
+		}
+	}
+	if typed != "Regards" {
+		t.Fatalf("expected %q to be typed, got %q", "Regards", typed)
+	}
+}
+
+func TestReloadConfigKeepsLastGoodConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expansions.json")
+	good := `{"schema_version": 2, "expansions": [{"trigger": ";x", "replacement": "X"}]}`
+	if err := os.WriteFile(path, []byte(good), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewExpanderWithKeyboard(cfg, &KeyboardHook{})
+	defer e.Close()
+
+	var (
+		mu       sync.Mutex
+		reported []error
+	)
+	e.SetConfigErrorCallback(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	})
+
+	if err := os.WriteFile(path, []byte(`{"expansions": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e.ReloadConfig()
+	var pe *config.ParseError
+	if !errors.As(e.ConfigError(), &pe) {
+		t.Fatalf("expected a parse error, got %v", e.ConfigError())
+	}
+	e.mu.RLock()
+	_, kept := e.expansions[";x"]
+	e.mu.RUnlock()
+	if !kept {
+		t.Fatal("expected the last good expansions to stay loaded")
+	}
+
+	if err := os.WriteFile(path, []byte(good), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e.ReloadConfig()
+	if err := e.ConfigError(); err != nil {
+		t.Fatalf("expected the error to clear, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 2 || reported[0] == nil || reported[1] != nil {
+		t.Errorf("expected the error and then nil, got %v", reported)
+	}
+}
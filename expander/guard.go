@@ -0,0 +1,142 @@
+package expander
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-vgo/robotgo"
+
+	"text-expander/config"
+)
+
+// ErrOutputTooLarge is reported when a replacement exceeds
+// Settings.MaxOutputSize and was not confirmed.
+var ErrOutputTooLarge = errors.New("replacement exceeds the maximum output size")
+
+// retriggerGuardTime is how long triggers found in an expansion's own output
+// are ignored after that expansion finishes.
+const retriggerGuardTime = 2 * time.Second
+
+// confirmRefocusDelay gives the target window time to regain focus after
+// the confirmation dialog closes.
+const confirmRefocusDelay = 200 * time.Millisecond
+
+// confirmLargeOutput asks the user whether an oversized replacement should be
+// inserted anyway. It blocks until the dialog is answered and is a variable
+// so tests can replace it.
+var confirmLargeOutput = func(trigger string, size int) bool {
+	msg := fmt.Sprintf("The replacement for %s is %d characters long. Insert it anyway?", trigger, size)
+	return robotgo.Alert("Text Expander", msg, "Insert", "Cancel")
+}
+
+// checkOutputSize enforces Settings.MaxOutputSize.
+func checkOutputSize(text string, settings config.Settings) error {
+	if settings.MaxOutputSize <= 0 {
+		return nil
+	}
+	size := utf8.RuneCountInString(text)
+	if size <= settings.MaxOutputSize {
+		return nil
+	}
+	return fmt.Errorf("%w (%d > %d characters)", ErrOutputTooLarge, size, settings.MaxOutputSize)
+}
+
+// confirmThenExpand asks whether an oversized replacement should be inserted
+// from a goroutine of its own, so the worker keeps processing keys while the
+// dialog is open. Once confirmed, the expansion is queued again with the
+// size check waived.
+func (e *Expander) confirmThenExpand(exp Expansion, target windowInfo, typed, terminator string, size int) {
+	go func() {
+		if !confirmLargeOutput(exp.Name(), size) {
+			log.Printf("expansion %q not inserted: %v", exp.Name(), ErrOutputTooLarge)
+			return
+		}
+		time.Sleep(confirmRefocusDelay)
+		run := func() {
+			e.sizeConfirmed = true
+			defer func() { e.sizeConfirmed = false }()
+			e.performExpansion(exp, target, typed, terminator)
+		}
+		if !e.queue(keyEvent{run: run}) {
+			log.Printf("expander: event queue full, dropping confirmed expansion %q", exp.Name())
+		}
+	}()
+}
+
+// terminators returns the characters that end a trigger under settings.
+func terminators(settings config.Settings) string {
+	var t string
+	if settings.TriggerOnSpace {
+		t += " "
+	}
+	if settings.TriggerOnTab {
+		t += "\t"
+	}
+	if settings.TriggerOnEnter {
+		t += "\n"
+	}
+	return t
+}
+
+// findRetriggers returns the triggers that occur in text immediately followed
+// by one of the terminator characters. Typing such text would fire those
+// expansions again if the keystrokes were fed back into the matcher.
+func findRetriggers(text string, expansions map[string]Expansion, terms string) []string {
+	if terms == "" {
+		return nil
+	}
+
+	lower := strings.ToLower(text)
+	var found []string
+	for _, exp := range expansions {
+		trigger, haystack := exp.Trigger, text
+		if !exp.CaseSensitive {
+			trigger, haystack = strings.ToLower(trigger), lower
+		}
+		if trigger == "" {
+			continue
+		}
+
+		for i := 0; ; {
+			j := strings.Index(haystack[i:], trigger)
+			if j < 0 {
+				break
+			}
+			end := i + j + len(trigger)
+			if end < len(haystack) && strings.ContainsRune(terms, rune(haystack[end])) {
+				found = append(found, exp.Trigger)
+				break
+			}
+			i += j + 1
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// guardRetriggers suppresses the given triggers for retriggerGuardTime, so
+// injected text that slips past echo filtering cannot start a loop.
+func (e *Expander) guardRetriggers(triggers []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.retriggers = make(map[string]bool, len(triggers))
+	for _, t := range triggers {
+		e.retriggers[t] = true
+	}
+	e.retriggerUntil = time.Now().Add(retriggerGuardTime)
+}
+
+// isRetrigger reports whether trigger was produced by the previous
+// expansion's output and should not fire now.
+func (e *Expander) isRetrigger(trigger string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.retriggers[trigger] && time.Now().Before(e.retriggerUntil)
+}
@@ -0,0 +1,250 @@
+package expander
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	hook "github.com/robotn/gohook"
+
+	"text-expander/config"
+)
+
+// maskShift is libuiohook's mask for either Shift key.
+const maskShift = 1<<0 | 1<<4
+
+// hotkeyKeycodes maps config.Hotkey key names to libuiohook key codes, which
+// do not depend on the keyboard layout.
+var hotkeyKeycodes = map[string]uint16{
+	"a": 0x1E, "b": 0x30, "c": 0x2E, "d": 0x20, "e": 0x12, "f": 0x21, "g": 0x22,
+	"h": 0x23, "i": 0x17, "j": 0x24, "k": 0x25, "l": 0x26, "m": 0x32, "n": 0x31,
+	"o": 0x18, "p": 0x19, "q": 0x10, "r": 0x13, "s": 0x1F, "t": 0x14, "u": 0x16,
+	"v": 0x2F, "w": 0x11, "x": 0x2D, "y": 0x15, "z": 0x2C,
+
+	"1": 0x02, "2": 0x03, "3": 0x04, "4": 0x05, "5": 0x06,
+	"6": 0x07, "7": 0x08, "8": 0x09, "9": 0x0A, "0": 0x0B,
+
+	"f1": 0x3B, "f2": 0x3C, "f3": 0x3D, "f4": 0x3E, "f5": 0x3F, "f6": 0x40,
+	"f7": 0x41, "f8": 0x42, "f9": 0x43, "f10": 0x44, "f11": 0x57, "f12": 0x58,
+
+	"space":     0x39,
+	"enter":     vcEnter,
+	"tab":       vcTab,
+	"escape":    vcEscape,
+	"backspace": vcBackspace,
+	"insert":    0x0E52,
+	"delete":    0x0E53,
+	"home":      0x0E47,
+	"end":       0x0E4F,
+	"pageup":    0x0E49,
+	"pagedown":  0x0E51,
+	"up":        0xE048,
+	"down":      0xE050,
+	"left":      0xE04B,
+	"right":     0xE04D,
+}
+
+// Hotkey is a key combination matched against raw hook press events.
+type Hotkey struct {
+	Keycode uint16
+	Ctrl    bool
+	Shift   bool
+	Alt     bool
+	Meta    bool
+}
+
+// NewHotkey converts a parsed binding into a Hotkey.
+func NewHotkey(h config.Hotkey) (Hotkey, error) {
+	code, ok := hotkeyKeycodes[h.Key]
+	if !ok {
+		return Hotkey{}, fmt.Errorf("no key code for %q", h.Key)
+	}
+	return Hotkey{Keycode: code, Ctrl: h.Ctrl, Shift: h.Shift, Alt: h.Alt, Meta: h.Meta}, nil
+}
+
+// hotkeyFromEvent returns the combination a press event represents.
+func hotkeyFromEvent(ev hook.Event) Hotkey {
+	return Hotkey{
+		Keycode: ev.Keycode,
+		Ctrl:    ev.Mask&maskCtrl != 0,
+		Shift:   ev.Mask&maskShift != 0,
+		Alt:     ev.Mask&maskAlt != 0,
+		Meta:    ev.Mask&maskMeta != 0,
+	}
+}
+
+// hotkeyRegistrar is implemented by keyboards that can watch for global
+// hotkeys.
+type hotkeyRegistrar interface {
+	SetHotkeys(map[Hotkey]func())
+}
+
+// SetHotkeyHandler sets the function called with a config.Hotkey* action
+// name when its global hotkey is pressed; the expander handles
+// config.HotkeyAccept itself. Bindings come from the settings and follow
+// config reloads. The handler runs on its own goroutine.
+func (e *Expander) SetHotkeyHandler(fn func(action string)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hotkeyFunc = fn
+	e.registerHotkeysLocked()
+}
+
+// registerHotkeysLocked passes the configured bindings to the keyboard:
+// the global actions, which need a handler unless they are handled here,
+// followed by the expansion hotkeys. A combination that is already taken
+// keeps its first owner. e.mu must be held by the caller.
+func (e *Expander) registerHotkeysLocked() {
+	reg, ok := e.keyboard.(hotkeyRegistrar)
+	if !ok || e.config == nil {
+		return
+	}
+
+	hotkeys := make(map[Hotkey]func())
+	bind := func(name, binding string, fn func()) {
+		parsed, err := config.ParseHotkey(binding)
+		if err != nil {
+			log.Printf("ignoring %s hotkey: %v", name, err)
+			return
+		}
+		hk, err := NewHotkey(parsed)
+		if err != nil {
+			log.Printf("ignoring %s hotkey %q: %v", name, binding, err)
+			return
+		}
+		if _, dup := hotkeys[hk]; dup {
+			log.Printf("ignoring %s hotkey %q: already bound", name, binding)
+			return
+		}
+		hotkeys[hk] = fn
+	}
+
+	bindings := e.config.GetSettings().Hotkeys.Bindings()
+	for _, action := range config.HotkeyActions {
+		binding, ok := bindings[action]
+		if !ok {
+			continue
+		}
+		action := action
+		switch handler := e.hotkeyFunc; {
+		case action == config.HotkeyAccept:
+			bind(action, binding, e.AcceptSuggestion)
+		case handler != nil:
+			bind(action, binding, func() { handler(action) })
+		}
+	}
+
+	for _, exp := range e.config.AllExpansions() {
+		if exp.Hotkey == "" {
+			continue
+		}
+		exp := exp
+		bind(fmt.Sprintf("expansion %q", exp.Name()), exp.Hotkey, func() { e.queueInsert(exp) })
+	}
+
+	for _, err := range e.config.HotkeyConflicts() {
+		log.Printf("hotkey conflict: %v", err)
+		if e.logger != nil {
+			e.logger.LogError(err)
+		}
+	}
+
+	reg.SetHotkeys(hotkeys)
+}
+
+// InsertExpansion inserts the expansion with the given name (see
+// Expansion.Name) at the caret, as if its hotkey had been pressed.
+func (e *Expander) InsertExpansion(name string) error {
+	e.mu.RLock()
+	cfg := e.config
+	e.mu.RUnlock()
+
+	if cfg == nil {
+		return fmt.Errorf("expansion %q not found", name)
+	}
+	for _, exp := range cfg.AllExpansions() {
+		if exp.Name() == name {
+			e.queueInsert(exp)
+			return nil
+		}
+	}
+	return fmt.Errorf("expansion %q not found", name)
+}
+
+// modifierReleaseTimeout bounds how long an insertion waits for the
+// modifiers of the hotkey that asked for it to be released.
+const modifierReleaseTimeout = time.Second
+
+// modifierTracker is implemented by keyboards that know whether a modifier
+// key is held down.
+type modifierTracker interface {
+	ModifiersHeld() bool
+}
+
+// waitModifiersReleased blocks until no modifier key is held, so that text
+// injected for a hotkey is not combined with the hotkey's own Ctrl or Alt,
+// or until modifierReleaseTimeout has passed. Keyboards that do not track
+// modifiers are not waited for.
+func (e *Expander) waitModifiersReleased() {
+	e.mu.RLock()
+	mt, ok := e.keyboard.(modifierTracker)
+	e.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	deadline := time.Now().Add(modifierReleaseTimeout)
+	for mt.ModifiersHeld() {
+		if time.Now().After(deadline) {
+			log.Printf("expander: modifiers still held after %v, inserting anyway", modifierReleaseTimeout)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// queueInsert waits for the modifiers to be released and runs exp on the
+// worker after the keys already queued, so it cannot interleave with a typed
+// expansion.
+func (e *Expander) queueInsert(exp Expansion) {
+	e.waitModifiersReleased()
+	run := func() {
+		e.mu.RLock()
+		cfg := e.config
+		e.mu.RUnlock()
+		if !cfg.GetSettings().Enabled || !e.PausedUntil().IsZero() {
+			return
+		}
+		e.InsertAtCaret(exp)
+	}
+	if !e.queue(keyEvent{run: run}) {
+		log.Printf("expander: event queue full, dropping insertion of %q", exp.Name())
+	}
+}
+
+// Pause suspends expansions for d. Keys are still tracked so triggers typed
+// after the pause ends work normally.
+func (e *Expander) Pause(d time.Duration) {
+	e.mu.Lock()
+	e.pausedUntil = time.Now().Add(d)
+	e.mu.Unlock()
+}
+
+// Resume ends a pause early.
+func (e *Expander) Resume() {
+	e.mu.Lock()
+	e.pausedUntil = time.Time{}
+	e.mu.Unlock()
+}
+
+// PausedUntil returns when the current pause ends, or the zero time if
+// expansions are not paused.
+func (e *Expander) PausedUntil() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if time.Now().After(e.pausedUntil) {
+		return time.Time{}
+	}
+	return e.pausedUntil
+}
@@ -0,0 +1,40 @@
+package expander
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"text-expander/config"
+)
+
+// InjectionStrategy describes how replacement text is delivered to the
+// focused application.
+type InjectionStrategy int
+
+const (
+	// StrategyType sends the text one keystroke at a time.
+	StrategyType InjectionStrategy = iota
+	// StrategyPaste puts the text on the clipboard and sends the paste
+	// shortcut, restoring the previous clipboard afterwards.
+	StrategyPaste
+)
+
+// chooseStrategy picks the injection strategy for an expansion. An explicit
+// inject mode on the expansion wins, then the application profile's mode;
+// otherwise text longer than the paste threshold is pasted, because typing
+// it is slow and editors with auto-indent or auto-close brackets mangle it.
+func chooseStrategy(exp Expansion, text string, settings config.Settings, profile config.InjectionProfile) InjectionStrategy {
+	for _, mode := range []string{exp.InjectMode, profile.InjectMode} {
+		switch strings.ToLower(mode) {
+		case config.InjectModePaste:
+			return StrategyPaste
+		case config.InjectModeType:
+			return StrategyType
+		}
+	}
+
+	if settings.PasteThreshold > 0 && utf8.RuneCountInString(text) > settings.PasteThreshold {
+		return StrategyPaste
+	}
+	return StrategyType
+}
@@ -0,0 +1,153 @@
+package expander
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key names understood by every Injector. They follow robotgo's naming.
+const (
+	InjectBackspace = "backspace"
+	InjectEnter     = "enter"
+	InjectTab       = "tab"
+	InjectLeft      = "left"
+	InjectRight     = "right"
+	InjectShift     = "shift"
+	InjectCtrl      = "ctrl"
+	InjectAlt       = "alt"
+	InjectCmd       = "cmd"
+)
+
+// Output backends selectable through config.Settings.OutputBackend.
+const (
+	BackendRobotgo = "robotgo"
+	BackendUinput  = "uinput"
+)
+
+// ClipboardContent is what an Injector places on the clipboard when
+// pasting. HTML is optional; Text is always offered as the plain fallback.
+type ClipboardContent struct {
+	Text string
+	HTML string
+}
+
+// Injector delivers synthetic input to the focused application. It is the
+// output half of the expander; listening for keys is handled by Keyboard.
+type Injector interface {
+	// TypeText types text, pausing delay between keystrokes.
+	TypeText(text string, delay time.Duration) error
+	// TapKey presses and releases key while holding the given modifiers.
+	TapKey(key string, modifiers ...string) error
+	// Paste puts content on the clipboard, sends shortcut (or the platform
+	// default when empty) and restores the previous clipboard text.
+	Paste(content ClipboardContent, shortcut string) error
+	// MoveCursor moves the caret by delta characters; negative values move
+	// left.
+	MoveCursor(delta int, delay time.Duration) error
+}
+
+// NewInjector returns the output backend with the given name. An empty name
+// selects robotgo.
+func NewInjector(backend string) (Injector, error) {
+	switch strings.ToLower(backend) {
+	case "", BackendRobotgo:
+		return NewRobotInjector(), nil
+	case BackendUinput:
+		return NewUinputInjector()
+	default:
+		return nil, fmt.Errorf("unknown output backend %q", backend)
+	}
+}
+
+// tapShortcut sends a shortcut such as "shift+enter" through inj.
+func tapShortcut(inj Injector, shortcut string) error {
+	key, mods := splitShortcut(shortcut)
+	if key == "" {
+		return fmt.Errorf("invalid shortcut %q", shortcut)
+	}
+	return inj.TapKey(key, mods...)
+}
+
+// splitShortcut parses a shortcut such as "ctrl+shift+v" into the key and
+// its modifiers.
+func splitShortcut(shortcut string) (key string, modifiers []string) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(shortcut)), "+")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	if len(parts) == 0 || parts[len(parts)-1] == "" {
+		return "", nil
+	}
+	return parts[len(parts)-1], parts[:len(parts)-1]
+}
+
+// InjectedEvent is a single operation recorded by MemoryInjector.
+type InjectedEvent struct {
+	Kind      string // "type", "tap", "paste" or "move"
+	Text      string
+	HTML      string
+	Key       string
+	Modifiers []string
+	Delta     int
+}
+
+// MemoryInjector records injected input instead of sending it anywhere. It
+// is used by tests and by tooling that needs to observe expander output.
+type MemoryInjector struct {
+	mu     sync.Mutex
+	events []InjectedEvent
+}
+
+// NewMemoryInjector creates an empty in-memory injector.
+func NewMemoryInjector() *MemoryInjector {
+	return &MemoryInjector{}
+}
+
+// TypeText records typed text.
+func (m *MemoryInjector) TypeText(text string, _ time.Duration) error {
+	m.record(InjectedEvent{Kind: "type", Text: text})
+	return nil
+}
+
+// TapKey records a key tap.
+func (m *MemoryInjector) TapKey(key string, modifiers ...string) error {
+	m.record(InjectedEvent{Kind: "tap", Key: key, Modifiers: append([]string(nil), modifiers...)})
+	return nil
+}
+
+// Paste records pasted clipboard content.
+func (m *MemoryInjector) Paste(content ClipboardContent, shortcut string) error {
+	m.record(InjectedEvent{Kind: "paste", Text: content.Text, HTML: content.HTML, Key: shortcut})
+	return nil
+}
+
+// MoveCursor records a caret movement.
+func (m *MemoryInjector) MoveCursor(delta int, _ time.Duration) error {
+	m.record(InjectedEvent{Kind: "move", Delta: delta})
+	return nil
+}
+
+// Events returns a copy of the recorded operations.
+func (m *MemoryInjector) Events() []InjectedEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]InjectedEvent, len(m.events))
+	copy(out, m.events)
+	return out
+}
+
+// Reset discards all recorded operations.
+func (m *MemoryInjector) Reset() {
+	m.mu.Lock()
+	m.events = nil
+	m.mu.Unlock()
+}
+
+func (m *MemoryInjector) record(ev InjectedEvent) {
+	m.mu.Lock()
+	m.events = append(m.events, ev)
+	m.mu.Unlock()
+}
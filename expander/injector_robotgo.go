@@ -0,0 +1,98 @@
+package expander
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/go-vgo/robotgo"
+)
+
+// clipboardRestoreDelay gives the target application time to read the
+// clipboard after the paste shortcut before the previous contents return.
+const clipboardRestoreDelay = 300 * time.Millisecond
+
+// RobotInjector sends input through robotgo. It works on Windows, macOS and
+// X11 sessions.
+type RobotInjector struct{}
+
+// NewRobotInjector creates a robotgo-backed injector.
+func NewRobotInjector() *RobotInjector {
+	return &RobotInjector{}
+}
+
+// TypeText types text with the given per-keystroke delay.
+func (r *RobotInjector) TypeText(text string, delay time.Duration) error {
+	if text == "" {
+		return nil
+	}
+	robotgo.TypeDelay(text, int(delay/time.Millisecond))
+	return nil
+}
+
+// TapKey presses and releases a key with optional modifiers.
+func (r *RobotInjector) TapKey(key string, modifiers ...string) error {
+	args := make([]interface{}, len(modifiers))
+	for i, m := range modifiers {
+		args[i] = m
+	}
+	return robotgo.KeyTap(key, args...)
+}
+
+// Paste saves the current clipboard text, places content on the clipboard,
+// sends the paste shortcut and restores the saved text, also when the
+// shortcut could not be sent.
+func (r *RobotInjector) Paste(content ClipboardContent, shortcut string) error {
+	previous, prevErr := clipboard.ReadAll()
+
+	if err := setClipboardContent(content); err != nil {
+		return err
+	}
+	defer func() {
+		time.Sleep(clipboardRestoreDelay)
+		if prevErr == nil {
+			_ = clipboard.WriteAll(previous)
+		}
+	}()
+
+	key, mods := splitShortcut(shortcut)
+	if key == "" {
+		key, mods = "v", []string{robotgo.CmdCtrl()}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := r.TapKey(key, mods...); err != nil {
+		return fmt.Errorf("send paste shortcut: %w", err)
+	}
+
+	return nil
+}
+
+// MoveCursor moves the caret with arrow-key taps.
+func (r *RobotInjector) MoveCursor(delta int, delay time.Duration) error {
+	key := robotgo.Right
+	if delta < 0 {
+		key, delta = robotgo.Left, -delta
+	}
+	for i := 0; i < delta; i++ {
+		if err := robotgo.KeyTap(key); err != nil {
+			return err
+		}
+		time.Sleep(delay)
+	}
+	return nil
+}
+
+// setClipboardContent places plain or rich content on the system clipboard.
+func setClipboardContent(content ClipboardContent) error {
+	if content.HTML != "" {
+		if err := setRichClipboard(content.HTML, content.Text); err != nil {
+			return fmt.Errorf("set rich clipboard: %w", err)
+		}
+		return nil
+	}
+	if err := clipboard.WriteAll(content.Text); err != nil {
+		return fmt.Errorf("set clipboard: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package expander
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/atotto/clipboard"
+)
+
+// uinput ioctl requests (linux/uinput.h).
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiDevSetup   = 0x405c5503
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+
+	busVirtual = 0x06
+)
+
+// UinputInjector types through a virtual keyboard created on /dev/uinput.
+// It works on Wayland, where robotgo cannot synthesise input, but requires
+// write access to /dev/uinput (typically membership of the "input" group or
+// a udev rule). Characters outside the US layout are pasted instead.
+type UinputInjector struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewUinputInjector opens /dev/uinput and registers a virtual keyboard.
+func NewUinputInjector() (Injector, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open /dev/uinput: %w", err)
+	}
+
+	if err := ioctl(f, uiSetEvBit, evKey); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("enable key events: %w", err)
+	}
+	for code := uint16(1); code < 256; code++ {
+		if err := ioctl(f, uiSetKeyBit, uintptr(code)); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("enable key %d: %w", code, err)
+		}
+	}
+
+	// struct uinput_setup { struct input_id id; char name[80]; __u32 ff_effects_max; }
+	var setup [92]byte
+	binary.LittleEndian.PutUint16(setup[0:], busVirtual)
+	binary.LittleEndian.PutUint16(setup[2:], 0x1234)
+	binary.LittleEndian.PutUint16(setup[4:], 0x5678)
+	copy(setup[8:], "text-expander virtual keyboard")
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uiDevSetup, uintptr(unsafe.Pointer(&setup[0]))); errno != 0 {
+		f.Close()
+		return nil, fmt.Errorf("setup device: %w", errno)
+	}
+	if err := ioctl(f, uiDevCreate, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("create device: %w", err)
+	}
+
+	// Give the compositor a moment to pick up the new device.
+	time.Sleep(200 * time.Millisecond)
+	return &UinputInjector{file: f}, nil
+}
+
+// Close destroys the virtual keyboard.
+func (u *UinputInjector) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.file == nil {
+		return nil
+	}
+	_ = ioctl(u.file, uiDevDestroy, 0)
+	err := u.file.Close()
+	u.file = nil
+	return err
+}
+
+// TypeText types text rune by rune using the US layout. Runs of characters
+// that have no key on that layout are pasted through the clipboard.
+func (u *UinputInjector) TypeText(text string, delay time.Duration) error {
+	var pending []rune
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := u.Paste(ClipboardContent{Text: string(pending)}, "")
+		pending = nil
+		return err
+	}
+
+	for _, r := range text {
+		ks, ok := usLayout[r]
+		if !ok {
+			pending = append(pending, r)
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		var mods []uint16
+		if ks.shift {
+			mods = []uint16{evKeyLeftShift}
+		}
+		if err := u.tap(ks.code, mods...); err != nil {
+			return err
+		}
+		time.Sleep(delay)
+	}
+	return flush()
+}
+
+// TapKey presses and releases a key with optional modifiers.
+func (u *UinputInjector) TapKey(key string, modifiers ...string) error {
+	code, ok := keyCodeForName(key)
+	if !ok {
+		return fmt.Errorf("uinput: unsupported key %q", key)
+	}
+
+	mods := make([]uint16, 0, len(modifiers))
+	for _, m := range modifiers {
+		mc, ok := keyCodeForName(m)
+		if !ok {
+			return fmt.Errorf("uinput: unsupported modifier %q", m)
+		}
+		mods = append(mods, mc)
+	}
+	return u.tap(code, mods...)
+}
+
+// Paste places content on the clipboard, sends the paste shortcut
+// (Ctrl+V by default) and restores the previous clipboard text, also when
+// the shortcut could not be sent.
+func (u *UinputInjector) Paste(content ClipboardContent, shortcut string) error {
+	previous, prevErr := clipboard.ReadAll()
+
+	if err := setClipboardContent(content); err != nil {
+		return err
+	}
+	defer func() {
+		time.Sleep(clipboardRestoreDelay)
+		if prevErr == nil {
+			_ = clipboard.WriteAll(previous)
+		}
+	}()
+
+	if shortcut == "" {
+		shortcut = "ctrl+v"
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := tapShortcut(u, shortcut); err != nil {
+		return fmt.Errorf("send paste shortcut: %w", err)
+	}
+
+	return nil
+}
+
+// MoveCursor moves the caret with arrow-key taps.
+func (u *UinputInjector) MoveCursor(delta int, delay time.Duration) error {
+	code := uint16(evKeyRight)
+	if delta < 0 {
+		code, delta = evKeyLeft, -delta
+	}
+	for i := 0; i < delta; i++ {
+		if err := u.tap(code); err != nil {
+			return err
+		}
+		time.Sleep(delay)
+	}
+	return nil
+}
+
+func (u *UinputInjector) tap(code uint16, modifiers ...uint16) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.file == nil {
+		return fmt.Errorf("uinput device is closed")
+	}
+
+	for _, m := range modifiers {
+		if err := u.emit(evKey, m, 1); err != nil {
+			return err
+		}
+	}
+	if err := u.emit(evKey, code, 1); err != nil {
+		return err
+	}
+	if err := u.emit(evKey, code, 0); err != nil {
+		return err
+	}
+	for i := len(modifiers) - 1; i >= 0; i-- {
+		if err := u.emit(evKey, modifiers[i], 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emit writes one input_event followed by a SYN_REPORT. u.mu must be held.
+func (u *UinputInjector) emit(typ, code uint16, value int32) error {
+	if err := u.write(typ, code, value); err != nil {
+		return err
+	}
+	return u.write(evSyn, synReport, 0)
+}
+
+func (u *UinputInjector) write(typ, code uint16, value int32) error {
+	var buf [evdevEventSize]byte
+	binary.LittleEndian.PutUint16(buf[evdevTypeOff:], typ)
+	binary.LittleEndian.PutUint16(buf[evdevCodeOff:], code)
+	binary.LittleEndian.PutUint32(buf[evdevValueOff:], uint32(value))
+	_, err := u.file.Write(buf[:])
+	return err
+}
+
+func ioctl(f *os.File, req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package expander
+
+import "errors"
+
+// NewUinputInjector is only available on Linux.
+func NewUinputInjector() (Injector, error) {
+	return nil, errors.New("uinput output backend is only supported on Linux")
+}
@@ -1,11 +1,11 @@
 package expander
 
 import (
+	"fmt"
 	"log"
+	"strings"
 	"sync"
-	"time"
 
-	"github.com/go-vgo/robotgo"
 	hook "github.com/robotn/gohook"
 )
 
@@ -15,21 +15,43 @@ const (
 	KeyEnter     = "ENTER"
 	KeySpace     = "SPACE"
 	KeyTab       = "TAB"
+	KeyEscape    = "ESCAPE"
 )
 
-// Keyboard defines the subset of keyboard operations that the expander needs.
-// It is implemented by KeyboardHook and can be replaced in tests.
+// Keyboard is the input half of the expander: it listens for key presses.
+// It is implemented by KeyboardHook and can be replaced in tests. Output is
+// handled separately by an Injector.
 type Keyboard interface {
 	Start() error
 	Stop()
-	SimulateBackspace(count int)
-	SimulateTyping(text string)
 }
 
-// KeyboardHook listens for global keyboard events using gohook and provides
-// helpers to simulate key presses using robotgo.
+// Input backends selectable through config.Settings.InputBackend.
+const (
+	BackendHook  = "hook"
+	BackendEvdev = "evdev"
+)
+
+// NewKeyboard returns the input backend with the given name. An empty name
+// selects the hook. The layout is only used by evdev.
+func NewKeyboard(backend, layout string) (Keyboard, error) {
+	switch strings.ToLower(backend) {
+	case "", BackendHook:
+		return NewKeyboardHook(), nil
+	case BackendEvdev:
+		return NewEvdevKeyboard(layout)
+	default:
+		return nil, fmt.Errorf("unknown input backend %q", backend)
+	}
+}
+
+// KeyboardHook listens for global keyboard events using gohook.
 type KeyboardHook struct {
 	onKeyPress func(key string)
+	recorder   *EventRecorder
+	hotkeys    map[Hotkey]func()
+	// mods holds the modifier bits of the last event's mask.
+	mods uint16
 
 	mu      sync.Mutex
 	running bool
@@ -48,6 +70,31 @@ func (k *KeyboardHook) SetOnKeyPress(cb func(key string)) {
 	k.mu.Unlock()
 }
 
+// SetRecorder makes the hook write every key event to rec, so the session
+// can later be replayed with ReplayKeyboard. Pass nil to stop recording.
+func (k *KeyboardHook) SetRecorder(rec *EventRecorder) {
+	k.mu.Lock()
+	k.recorder = rec
+	k.mu.Unlock()
+}
+
+// SetHotkeys replaces the global hotkeys. Each function runs on its own
+// goroutine when its combination is pressed; the key press is not passed on
+// as text.
+func (k *KeyboardHook) SetHotkeys(hotkeys map[Hotkey]func()) {
+	k.mu.Lock()
+	k.hotkeys = hotkeys
+	k.mu.Unlock()
+}
+
+// ModifiersHeld reports whether Shift, Ctrl, Alt or Meta was held at the
+// last key press or release.
+func (k *KeyboardHook) ModifiersHeld() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.mods != 0
+}
+
 // Start begins listening for global keyboard events.
 func (k *KeyboardHook) Start() error {
 	k.mu.Lock()
@@ -64,40 +111,61 @@ func (k *KeyboardHook) Start() error {
 		defer hook.End()
 		log.Printf("[DEBUG] KeyboardHook: keyboard hook started, waiting for events...")
 
+		var tr keyTranslator
+		skipTyped := false
 		eventCount := 0
 		for ev := range evChan {
-			eventCount++
-			// We only care about key down events.
-			if ev.Kind != hook.KeyDown {
-				continue
+			k.mu.Lock()
+			rec := k.recorder
+			hotkeys := k.hotkeys
+			if ev.Kind == hook.KeyHold || ev.Kind == hook.KeyUp {
+				k.mods = ev.Mask & (maskShift | maskCtrl | maskAlt | maskMeta)
+			}
+			k.mu.Unlock()
+			if rec != nil {
+				if err := rec.Record(ev); err != nil {
+					log.Printf("KeyboardHook: recording failed: %v", err)
+				}
 			}
 
-			keyStr := hook.RawcodetoKeychar(ev.Rawcode)
-			key := translateEvent(ev)
-
-			// Always log space, enter, tab, backspace to debug translation
-			if key == KeySpace || key == KeyEnter || key == KeyTab || key == KeyBackspace ||
-				key == " " || key == "\r" || key == "\n" || key == "\t" || key == "" {
-				log.Printf("[DEBUG] KeyboardHook: event #%d: keyStr=%q, keychar=%d, translated=%q", eventCount, keyStr, ev.Keychar, key)
+			// A hotkey's press is followed by a typed event for its
+			// character (Ctrl+Alt looks like AltGr); drop that as well.
+			switch ev.Kind {
+			case hook.KeyHold:
+				skipTyped = false
+				if fn, ok := hotkeys[hotkeyFromEvent(ev)]; ok {
+					skipTyped = true
+					go fn()
+					continue
+				}
+			case hook.KeyDown:
+				if skipTyped {
+					skipTyped = false
+					continue
+				}
 			}
 
-			if key == "" {
+			keys := tr.Translate(ev)
+			if len(keys) == 0 {
 				continue
 			}
 
 			// Log first few events to verify hook is working
+			eventCount++
 			if eventCount <= 10 {
-				log.Printf("[DEBUG] KeyboardHook: received key event #%d: %q (rawcode=%d, keychar=%d, keyStr=%q)", eventCount, key, ev.Rawcode, ev.Keychar, keyStr)
+				log.Printf("[DEBUG] KeyboardHook: received key event #%d: %q (keycode=%d, rawcode=%d, keychar=%d)", eventCount, keys, ev.Keycode, ev.Rawcode, ev.Keychar)
 			}
 
 			k.mu.Lock()
 			cb := k.onKeyPress
 			k.mu.Unlock()
 
-			if cb != nil {
-				cb(key)
-			} else {
+			if cb == nil {
 				log.Printf("[DEBUG] KeyboardHook: WARNING - callback is nil!")
+				continue
+			}
+			for _, key := range keys {
+				cb(key)
 			}
 		}
 		log.Printf("[DEBUG] KeyboardHook: event channel closed, hook ending")
@@ -117,115 +185,3 @@ func (k *KeyboardHook) Stop() {
 	hook.End()
 	k.running = false
 }
-
-// SimulateBackspace sends the given number of backspace key taps.
-func (k *KeyboardHook) SimulateBackspace(count int) {
-	if count <= 0 {
-		return
-	}
-
-	for i := 0; i < count; i++ {
-		_ = robotgo.KeyTap(robotgo.Backspace)
-		time.Sleep(2 * time.Millisecond)
-	}
-}
-
-// SimulateTyping types the given text with small delays between keystrokes.
-func (k *KeyboardHook) SimulateTyping(text string) {
-	if text == "" {
-		return
-	}
-	// TypeDelay introduces a per-key delay in milliseconds.
-	robotgo.TypeDelay(text, 2)
-}
-
-// translateEvent maps a gohook Event to a logical key representation used by
-// the expander.
-func translateEvent(ev hook.Event) string {
-	keyStr := hook.RawcodetoKeychar(ev.Rawcode)
-	char := string(ev.Keychar)
-
-	// Debug logging for special keys - log ALL potential special key events
-	if ev.Keychar == 32 || ev.Keychar == 13 || ev.Keychar == 10 || ev.Keychar == 9 || ev.Keychar == 8 ||
-		keyStr == " " || keyStr == "\r" || keyStr == "\n" || keyStr == "\t" || keyStr == "space" || keyStr == "enter" || keyStr == "tab" || keyStr == "backspace" {
-		log.Printf("[DEBUG] translateEvent: keyStr=%q, keychar=%d, char=%q", keyStr, ev.Keychar, char)
-	}
-
-	// First check keyStr for named keys (this is more reliable)
-	switch keyStr {
-	case "space":
-		return KeySpace
-	case "enter":
-		return KeyEnter
-	case "tab":
-		return KeyTab
-	case "backspace":
-		return KeyBackspace
-	case "":
-		// If keyStr is empty, check keychar for special keys
-		if ev.Keychar == 0 || ev.Keychar == hook.CharUndefined {
-			return ""
-		}
-		// Convert keychar to string first to check what it is
-		char := string(ev.Keychar)
-
-		// Check for special keys by their character representation
-		// Space character
-		if char == " " || ev.Keychar == 32 {
-			return KeySpace
-		}
-		// Enter (carriage return or newline)
-		if char == "\r" || char == "\n" || ev.Keychar == 13 || ev.Keychar == 10 {
-			return KeyEnter
-		}
-		// Tab
-		if char == "\t" || ev.Keychar == 9 {
-			return KeyTab
-		}
-		// Backspace
-		if ev.Keychar == 8 {
-			return KeyBackspace
-		}
-		// For other printable characters, return the character
-		return char
-	default:
-		// Check if keyStr is a special character that should be mapped
-		runes := []rune(keyStr)
-		if len(runes) == 1 {
-			charFromKeyStr := string(runes[0])
-			// Check for special keys by their character representation
-			if charFromKeyStr == " " {
-				log.Printf("[DEBUG] translateEvent: default case - mapping space character to KeySpace")
-				return KeySpace
-			}
-			if charFromKeyStr == "\r" || charFromKeyStr == "\n" {
-				log.Printf("[DEBUG] translateEvent: default case - mapping newline to KeyEnter")
-				return KeyEnter
-			}
-			if charFromKeyStr == "\t" {
-				log.Printf("[DEBUG] translateEvent: default case - mapping tab to KeyTab")
-				return KeyTab
-			}
-			// For other printable characters, return the character
-			return charFromKeyStr
-		}
-		// If keyStr is empty or multi-character, check keychar directly
-		if keyStr == "" {
-			// keyStr is empty, check keychar
-			if char == " " {
-				log.Printf("[DEBUG] translateEvent: empty keyStr, keychar is space, returning KeySpace")
-				return KeySpace
-			}
-			if char == "\r" || char == "\n" {
-				log.Printf("[DEBUG] translateEvent: empty keyStr, keychar is enter, returning KeyEnter")
-				return KeyEnter
-			}
-			if char == "\t" {
-				log.Printf("[DEBUG] translateEvent: empty keyStr, keychar is tab, returning KeyTab")
-				return KeyTab
-			}
-		}
-		// Ignore other special keys (arrows, function keys, etc.) here.
-		return ""
-	}
-}
@@ -0,0 +1,105 @@
+package expander
+
+// Linux input event types (linux/input-event-codes.h).
+const (
+	evSyn     = 0x00
+	evKey     = 0x01
+	synReport = 0
+)
+
+// Linux input event codes (linux/input-event-codes.h) for the keys the
+// uinput injector needs.
+const (
+	evKeyEsc       = 1
+	evKeyBackspace = 14
+	evKeyTab       = 15
+	evKeyEnter     = 28
+	evKeyLeftCtrl  = 29
+	evKeyLeftShift = 42
+	evKeyLeftAlt   = 56
+	evKeySpace     = 57
+	evKeyHome      = 102
+	evKeyUp        = 103
+	evKeyLeft      = 105
+	evKeyRight     = 106
+	evKeyEnd       = 107
+	evKeyDown      = 108
+	evKeyDelete    = 111
+	evKeyLeftMeta  = 125
+)
+
+// keyStroke is a key code plus whether Shift must be held to produce a
+// character on the US layout.
+type keyStroke struct {
+	code  uint16
+	shift bool
+}
+
+// usLayout maps printable ASCII characters to key strokes on a US QWERTY
+// layout.
+var usLayout = buildUSLayout()
+
+func buildUSLayout() map[rune]keyStroke {
+	m := make(map[rune]keyStroke, 100)
+
+	letters := map[rune]uint16{
+		'q': 16, 'w': 17, 'e': 18, 'r': 19, 't': 20, 'y': 21, 'u': 22, 'i': 23, 'o': 24, 'p': 25,
+		'a': 30, 's': 31, 'd': 32, 'f': 33, 'g': 34, 'h': 35, 'j': 36, 'k': 37, 'l': 38,
+		'z': 44, 'x': 45, 'c': 46, 'v': 47, 'b': 48, 'n': 49, 'm': 50,
+	}
+	for r, code := range letters {
+		m[r] = keyStroke{code: code}
+		m[r-'a'+'A'] = keyStroke{code: code, shift: true}
+	}
+
+	unshifted := "1234567890-=[];'`\\,./"
+	shifted := "!@#$%^&*()_+{}:\"~|<>?"
+	codes := []uint16{2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 26, 27, 39, 40, 41, 43, 51, 52, 53}
+	for i, code := range codes {
+		m[rune(unshifted[i])] = keyStroke{code: code}
+		m[rune(shifted[i])] = keyStroke{code: code, shift: true}
+	}
+
+	m[' '] = keyStroke{code: evKeySpace}
+	m['\t'] = keyStroke{code: evKeyTab}
+	m['\n'] = keyStroke{code: evKeyEnter}
+	return m
+}
+
+// namedKeyCodes maps injector key names to Linux key codes.
+var namedKeyCodes = map[string]uint16{
+	"esc":       evKeyEsc,
+	"escape":    evKeyEsc,
+	"backspace": evKeyBackspace,
+	"tab":       evKeyTab,
+	"enter":     evKeyEnter,
+	"space":     evKeySpace,
+	"home":      evKeyHome,
+	"end":       evKeyEnd,
+	"delete":    evKeyDelete,
+	"up":        evKeyUp,
+	"down":      evKeyDown,
+	"left":      evKeyLeft,
+	"right":     evKeyRight,
+	"shift":     evKeyLeftShift,
+	"ctrl":      evKeyLeftCtrl,
+	"control":   evKeyLeftCtrl,
+	"alt":       evKeyLeftAlt,
+	"cmd":       evKeyLeftMeta,
+	"super":     evKeyLeftMeta,
+}
+
+// keyCodeForName resolves an injector key name, which may also be a single
+// character such as "v", to a Linux key code.
+func keyCodeForName(name string) (uint16, bool) {
+	if code, ok := namedKeyCodes[name]; ok {
+		return code, true
+	}
+	runes := []rune(name)
+	if len(runes) == 1 {
+		if ks, ok := usLayout[runes[0]]; ok && !ks.shift {
+			return ks.code, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,322 @@
+package expander
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MarkdownToHTML converts a small, practical subset of Markdown into an HTML
+// fragment suitable for pasting into rich text editors such as mail clients.
+//
+// Supported block elements are ATX headings, paragraphs, unordered and
+// ordered lists, block quotes, horizontal rules and fenced code blocks.
+// Supported inline elements are bold, italic, strikethrough, code spans,
+// links, images and autolinks. Single line breaks inside a paragraph are
+// kept as <br> because snippets such as signatures rely on them.
+func MarkdownToHTML(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	lines := strings.Split(src, "\n")
+
+	var (
+		out       strings.Builder
+		paragraph []string
+		listTag   string
+	)
+
+	flushParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		rendered := make([]string, len(paragraph))
+		for i, l := range paragraph {
+			rendered[i] = renderInline(l)
+		}
+		out.WriteString("<p>")
+		out.WriteString(strings.Join(rendered, "<br>"))
+		out.WriteString("</p>")
+		paragraph = nil
+	}
+	closeList := func() {
+		if listTag == "" {
+			return
+		}
+		out.WriteString("</" + listTag + ">")
+		listTag = ""
+	}
+	openList := func(tag string) {
+		if listTag == tag {
+			return
+		}
+		closeList()
+		out.WriteString("<" + tag + ">")
+		listTag = tag
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		// Fenced code blocks are copied verbatim.
+		if strings.HasPrefix(trimmed, "```") {
+			flushParagraph()
+			closeList()
+			var code []string
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			out.WriteString("<pre><code>")
+			out.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			out.WriteString("</code></pre>")
+			continue
+		}
+
+		if trimmed == "" {
+			flushParagraph()
+			closeList()
+			continue
+		}
+
+		if level, text, ok := parseHeading(trimmed); ok {
+			flushParagraph()
+			closeList()
+			tag := "h" + string(rune('0'+level))
+			out.WriteString("<" + tag + ">" + renderInline(text) + "</" + tag + ">")
+			continue
+		}
+
+		if isHorizontalRule(trimmed) {
+			flushParagraph()
+			closeList()
+			out.WriteString("<hr>")
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, ">") {
+			flushParagraph()
+			closeList()
+			text := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+			out.WriteString("<blockquote>" + renderInline(text) + "</blockquote>")
+			continue
+		}
+
+		if text, ok := parseBullet(trimmed); ok {
+			flushParagraph()
+			openList("ul")
+			out.WriteString("<li>" + renderInline(text) + "</li>")
+			continue
+		}
+
+		if text, ok := parseOrdered(trimmed); ok {
+			flushParagraph()
+			openList("ol")
+			out.WriteString("<li>" + renderInline(text) + "</li>")
+			continue
+		}
+
+		closeList()
+		paragraph = append(paragraph, trimmed)
+	}
+
+	flushParagraph()
+	closeList()
+	return out.String()
+}
+
+func parseHeading(line string) (int, string, bool) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level >= len(line) || line[level] != ' ' {
+		return 0, "", false
+	}
+	return level, strings.TrimSpace(line[level:]), true
+}
+
+func isHorizontalRule(line string) bool {
+	if len(line) < 3 {
+		return false
+	}
+	c := line[0]
+	if c != '-' && c != '*' && c != '_' {
+		return false
+	}
+	for i := 0; i < len(line); i++ {
+		if line[i] != c && line[i] != ' ' {
+			return false
+		}
+	}
+	return strings.Count(line, string(c)) >= 3
+}
+
+func parseBullet(line string) (string, bool) {
+	if len(line) < 2 {
+		return "", false
+	}
+	if (line[0] == '-' || line[0] == '*' || line[0] == '+') && line[1] == ' ' {
+		return strings.TrimSpace(line[2:]), true
+	}
+	return "", false
+}
+
+var orderedItemRe = regexp.MustCompile(`^\d+[.)] +`)
+
+func parseOrdered(line string) (string, bool) {
+	loc := orderedItemRe.FindStringIndex(line)
+	if loc == nil {
+		return "", false
+	}
+	return strings.TrimSpace(line[loc[1]:]), true
+}
+
+// renderInline renders inline Markdown markup inside a single block.
+func renderInline(text string) string {
+	var out strings.Builder
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune("\\`*_[]()!~<>#", runes[i+1]):
+			out.WriteString(html.EscapeString(string(runes[i+1])))
+			i += 2
+			continue
+
+		case r == '`':
+			if end := indexRune(runes, '`', i+1); end > i {
+				out.WriteString("<code>" + html.EscapeString(string(runes[i+1:end])) + "</code>")
+				i = end + 1
+				continue
+			}
+
+		case r == '!' && i+1 < len(runes) && runes[i+1] == '[':
+			if label, target, next, ok := parseLinkAt(runes, i+1); ok {
+				out.WriteString(`<img src="` + html.EscapeString(target) + `" alt="` + html.EscapeString(label) + `">`)
+				i = next
+				continue
+			}
+
+		case r == '[':
+			if label, target, next, ok := parseLinkAt(runes, i); ok {
+				out.WriteString(`<a href="` + html.EscapeString(target) + `">` + renderInline(label) + `</a>`)
+				i = next
+				continue
+			}
+
+		case r == '<':
+			if end := indexRune(runes, '>', i+1); end > i {
+				target := string(runes[i+1 : end])
+				if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "mailto:") {
+					label := strings.TrimPrefix(target, "mailto:")
+					out.WriteString(`<a href="` + html.EscapeString(target) + `">` + html.EscapeString(label) + `</a>`)
+					i = end + 1
+					continue
+				}
+			}
+
+		case r == '~' && hasPrefixAt(runes, i, "~~"):
+			if end := indexSeq(runes, "~~", i+2); end > i+2 {
+				out.WriteString("<del>" + renderInline(string(runes[i+2:end])) + "</del>")
+				i = end + 2
+				continue
+			}
+
+		case (r == '*' || r == '_') && i+1 < len(runes) && runes[i+1] == r:
+			marker := string([]rune{r, r})
+			if end := indexSeq(runes, marker, i+2); end > i+2 {
+				out.WriteString("<strong>" + renderInline(string(runes[i+2:end])) + "</strong>")
+				i = end + 2
+				continue
+			}
+
+		case r == '*' || (r == '_' && (i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]))):
+			if end := indexRune(runes, r, i+1); end > i+1 && runes[i+1] != ' ' {
+				out.WriteString("<em>" + renderInline(string(runes[i+1:end])) + "</em>")
+				i = end + 1
+				continue
+			}
+		}
+
+		out.WriteString(html.EscapeString(string(r)))
+		i++
+	}
+
+	return out.String()
+}
+
+// parseLinkAt parses "[label](target)" starting at runes[start] == '['.
+func parseLinkAt(runes []rune, start int) (label, target string, next int, ok bool) {
+	closeLabel := indexRune(runes, ']', start+1)
+	if closeLabel < 0 || closeLabel+1 >= len(runes) || runes[closeLabel+1] != '(' {
+		return "", "", 0, false
+	}
+	closeTarget := indexRune(runes, ')', closeLabel+2)
+	if closeTarget < 0 {
+		return "", "", 0, false
+	}
+	label = string(runes[start+1 : closeLabel])
+	target = strings.TrimSpace(string(runes[closeLabel+2 : closeTarget]))
+	return label, target, closeTarget + 1, true
+}
+
+func indexRune(runes []rune, r rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexSeq(runes []rune, seq string, from int) int {
+	for i := from; i < len(runes); i++ {
+		if hasPrefixAt(runes, i, seq) {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasPrefixAt(runes []rune, at int, seq string) bool {
+	s := []rune(seq)
+	if at+len(s) > len(runes) {
+		return false
+	}
+	for i, r := range s {
+		if runes[at+i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	htmlBreakRe   = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlBlockEnd  = regexp.MustCompile(`(?i)</(p|div|h[1-6]|li|tr|blockquote|pre|ul|ol|table)>`)
+	htmlListItem  = regexp.MustCompile(`(?i)<li[^>]*>`)
+	htmlImgAltRe  = regexp.MustCompile(`(?i)<img[^>]*\balt="([^"]*)"[^>]*>`)
+	htmlTagRe     = regexp.MustCompile(`<[^>]*>`)
+	htmlBlankRuns = regexp.MustCompile(`\n{3,}`)
+)
+
+// HTMLToPlainText produces a readable plain-text rendering of an HTML
+// fragment. It is used as the text/plain fallback next to rich clipboard
+// content.
+func HTMLToPlainText(src string) string {
+	s := strings.ReplaceAll(src, "\r\n", "\n")
+	s = htmlBreakRe.ReplaceAllString(s, "\n")
+	s = htmlListItem.ReplaceAllString(s, "- ")
+	s = htmlBlockEnd.ReplaceAllString(s, "\n")
+	s = strings.ReplaceAll(s, "<hr>", "\n")
+	s = htmlImgAltRe.ReplaceAllString(s, "$1")
+	s = htmlTagRe.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = htmlBlankRuns.ReplaceAllString(s, "\n\n")
+	return strings.TrimRight(s, "\n")
+}
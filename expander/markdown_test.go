@@ -0,0 +1,59 @@
+package expander
+
+import "testing"
+
+func TestMarkdownToHTMLInline(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bold", "**Jane** Doe", "<p><strong>Jane</strong> Doe</p>"},
+		{"italic", "*Senior* Engineer", "<p><em>Senior</em> Engineer</p>"},
+		{"link", "[Acme](https://acme.example)", `<p><a href="https://acme.example">Acme</a></p>`},
+		{"image", "![logo](https://acme.example/logo.png)", `<p><img src="https://acme.example/logo.png" alt="logo"></p>`},
+		{"code", "run `go test`", "<p>run <code>go test</code></p>"},
+		{"escape", "a < b & c", "<p>a &lt; b &amp; c</p>"},
+		{"snake case", "my_var_name", "<p>my_var_name</p>"},
+		{"autolink", "<mailto:jane@acme.example>", `<p><a href="mailto:jane@acme.example">jane@acme.example</a></p>`},
+	}
+
+	for _, tc := range cases {
+		if got := MarkdownToHTML(tc.in); got != tc.want {
+			t.Errorf("%s: MarkdownToHTML(%q) = %q, want %q", tc.name, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestMarkdownToHTMLBlocks(t *testing.T) {
+	in := "# Title\n\nBest regards,\n**Jane**\n\n- one\n- two\n\n1. first\n2. second\n\n```\nx < y\n```"
+	want := "<h1>Title</h1>" +
+		"<p>Best regards,<br><strong>Jane</strong></p>" +
+		"<ul><li>one</li><li>two</li></ul>" +
+		"<ol><li>first</li><li>second</li></ol>" +
+		"<pre><code>x &lt; y</code></pre>"
+
+	if got := MarkdownToHTML(in); got != want {
+		t.Fatalf("unexpected HTML:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestHTMLToPlainText(t *testing.T) {
+	in := `<p>Best regards,<br><strong>Jane</strong> &amp; co</p><ul><li>one</li><li>two</li></ul>`
+	want := "Best regards,\nJane & co\n- one\n- two"
+
+	if got := HTMLToPlainText(in); got != want {
+		t.Fatalf("HTMLToPlainText = %q, want %q", got, want)
+	}
+}
+
+func TestRenderRichCursorOffset(t *testing.T) {
+	// "Hi **there**" with the cursor before "there" -> 5 visible runes follow.
+	_, plain, offset := renderRich("markdown", "Hi **there**", len("there**"))
+	if plain != "Hi there" {
+		t.Fatalf("unexpected plain text: %q", plain)
+	}
+	if offset != 5 {
+		t.Fatalf("expected cursor offset 5, got %d", offset)
+	}
+}
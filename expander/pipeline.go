@@ -0,0 +1,218 @@
+package expander
+
+import (
+	"context"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+// eventQueueSize bounds the number of key presses waiting for the worker.
+// Keys typed while an expansion runs wait here and are replayed afterwards.
+const eventQueueSize = 256
+
+// typeChunkSize is the number of runes typed between cancellation checks.
+const typeChunkSize = 8
+
+// echoTimeout is how long keys injected by an expansion are expected to
+// show up again through the keyboard hook.
+const echoTimeout = 500 * time.Millisecond
+
+// keyEvent is an item on the worker queue. A non-nil barrier is closed once
+// every event queued before it has been processed; a non-nil run is called
+// on the worker in place of handling a key.
+type keyEvent struct {
+	key     string
+	barrier chan struct{}
+	run     func()
+}
+
+// OnKeyPress is invoked by the keyboard hook for each key press. It only
+// queues the key; matching and expansion happen on the worker goroutine so
+// the hook is never blocked. Escape additionally aborts a running expansion.
+func (e *Expander) OnKeyPress(key string) {
+	if key == KeyEscape {
+		e.cancelExpansion()
+	}
+
+	if !e.queue(keyEvent{key: key}) {
+		log.Printf("expander: event queue full, dropping key %q", key)
+	}
+}
+
+// queue hands ev to the worker without blocking. It reports false if the
+// queue is full; events queued after Close are silently dropped.
+func (e *Expander) queue(ev keyEvent) bool {
+	e.sendMu.RLock()
+	defer e.sendMu.RUnlock()
+
+	if e.closed {
+		return true
+	}
+	select {
+	case e.events <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+// cancelExpansion aborts the expansion in progress, if any.
+func (e *Expander) cancelExpansion() {
+	e.mu.RLock()
+	cancel := e.cancel
+	e.mu.RUnlock()
+
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// runWorker processes queued key presses one at a time.
+func (e *Expander) runWorker() {
+	for ev := range e.events {
+		if ev.barrier != nil {
+			close(ev.barrier)
+			continue
+		}
+		if ev.run != nil {
+			ev.run()
+		} else if !e.isEcho(ev.key) {
+			e.processKey(ev.key)
+		}
+		e.updateSuggestions()
+	}
+}
+
+// WaitIdle blocks until every key queued so far has been processed,
+// including any expansion it triggers.
+func (e *Expander) WaitIdle() {
+	done := make(chan struct{})
+	e.sendMu.RLock()
+	if e.closed {
+		e.sendMu.RUnlock()
+		return
+	}
+	e.events <- keyEvent{barrier: done}
+	e.sendMu.RUnlock()
+	<-done
+}
+
+// expectEcho records, in order, the logical keys the injector is about to
+// send. The hook reports them like any other key press; the worker filters
+// exactly these from the incoming stream so real key presses typed during
+// an expansion are still processed. gohook gives no access to the
+// platform's "injected" marker, so matching by sequence is the only option.
+func (e *Expander) expectEcho(keys ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.echoes {
+		return
+	}
+	for _, k := range keys {
+		if k != "" {
+			e.echo = append(e.echo, k)
+		}
+	}
+	e.echoUntil = time.Now().Add(echoTimeout)
+}
+
+// resetEcho forgets any injected keys that never came back.
+func (e *Expander) resetEcho() {
+	e.mu.Lock()
+	e.echo = nil
+	e.mu.Unlock()
+}
+
+// isEcho reports whether key is the next injected key the hook is expected
+// to report, consuming it if so. Keys that do not match are real input; only
+// the head of the queue is considered, so a real key that happens to equal a
+// later injected one is never swallowed. Echoes the hook never reports are
+// dropped when echoTimeout expires.
+func (e *Expander) isEcho(key string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.echo) == 0 {
+		return false
+	}
+	if time.Now().After(e.echoUntil) {
+		e.echo = nil
+		return false
+	}
+	if e.echo[0] != key {
+		return false
+	}
+	e.echo = e.echo[1:]
+	return true
+}
+
+// shortcutEcho returns the logical key the hook reports when a shortcut
+// such as "ctrl+v" or "shift+enter" is tapped, or "" if it reports none.
+func shortcutEcho(shortcut string) string {
+	key, mods := splitShortcut(shortcut)
+	switch key {
+	case InjectEnter:
+		return KeyEnter
+	case InjectTab:
+		return KeyTab
+	case InjectBackspace:
+		return KeyBackspace
+	}
+	// Characters typed with Ctrl, Alt or Cmd held are not reported as text.
+	for _, m := range mods {
+		if m != InjectShift {
+			return ""
+		}
+	}
+	if key == "space" {
+		return KeySpace
+	}
+	if utf8.RuneCountInString(key) == 1 {
+		return key
+	}
+	return ""
+}
+
+// runeKey returns the logical key for a typed rune.
+func runeKey(r rune) string {
+	switch r {
+	case ' ':
+		return KeySpace
+	case '\n':
+		return KeyEnter
+	case '\t':
+		return KeyTab
+	default:
+		return string(r)
+	}
+}
+
+// typeChunked types text in small chunks, checking ctx between them so an
+// expansion can be aborted part-way through.
+func (e *Expander) typeChunked(ctx context.Context, inj Injector, text string, delay time.Duration) error {
+	runes := []rune(text)
+	for len(runes) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := typeChunkSize
+		if n > len(runes) {
+			n = len(runes)
+		}
+		chunk := runes[:n]
+		runes = runes[n:]
+
+		keys := make([]string, len(chunk))
+		for i, r := range chunk {
+			keys[i] = runeKey(r)
+		}
+		e.expectEcho(keys...)
+		if err := inj.TypeText(string(chunk), delay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package expander
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-vgo/robotgo"
+
+	"text-expander/config"
+)
+
+// defaultKeyDelay is the pause between synthetic keystrokes when no
+// injection profile overrides it.
+const defaultKeyDelay = 2 * time.Millisecond
+
+// windowInfo identifies the application that currently has focus.
+type windowInfo struct {
+	Handle  int
+	PID     int
+	Title   string
+	Process string
+}
+
+// activeWindow returns information about the focused window. It is a
+// variable so tests can replace it.
+var activeWindow = func() windowInfo {
+	info := windowInfo{
+		Handle: robotgo.GetHandle(),
+		PID:    robotgo.GetPid(),
+		Title:  robotgo.GetTitle(),
+	}
+	if name, err := robotgo.FindName(info.PID); err == nil {
+		info.Process = name
+	}
+	return info
+}
+
+// sameWindow reports whether a and b identify the same window. The native
+// handle is preferred; titles are only compared when nothing better is
+// available because many editors change them while typing.
+func sameWindow(a, b windowInfo) bool {
+	if a.Handle != 0 && b.Handle != 0 {
+		return a.Handle == b.Handle
+	}
+	if a.PID != 0 && b.PID != 0 {
+		return a.PID == b.PID
+	}
+	return a.Process == b.Process && a.Title == b.Title
+}
+
+// checkFocus returns ErrFocusChanged if target no longer has focus.
+func (e *Expander) checkFocus(target windowInfo) error {
+	if now := e.window(); !sameWindow(target, now) {
+		return fmt.Errorf("%w: expected %q, now %q", ErrFocusChanged, target.Title, now.Title)
+	}
+	return nil
+}
+
+// matchProfile returns the first profile whose process name or window title
+// matches the given window.
+func matchProfile(profiles []config.InjectionProfile, win windowInfo) (config.InjectionProfile, bool) {
+	process := strings.ToLower(path.Base(strings.ReplaceAll(win.Process, `\`, "/")))
+	title := strings.ToLower(win.Title)
+
+	for _, p := range profiles {
+		if p.Process != "" && process != "" {
+			want := strings.ToLower(p.Process)
+			if want == process || strings.TrimSuffix(want, ".exe") == strings.TrimSuffix(process, ".exe") {
+				return p, true
+			}
+		}
+		if p.WindowTitle != "" && strings.Contains(title, strings.ToLower(p.WindowTitle)) {
+			return p, true
+		}
+	}
+	return config.InjectionProfile{}, false
+}
+
+// keyDelay returns the per-keystroke delay for a profile.
+func keyDelay(p config.InjectionProfile) time.Duration {
+	if p.TypingDelay > 0 {
+		return time.Duration(p.TypingDelay) * time.Millisecond
+	}
+	return defaultKeyDelay
+}
+
+// deleteWithProfile removes count characters before the caret, either with
+// backspaces or by extending a selection with Shift+Left and deleting it.
+func (e *Expander) deleteWithProfile(ctx context.Context, inj Injector, count int, p config.InjectionProfile) error {
+	if count <= 0 {
+		return nil
+	}
+
+	delay := keyDelay(p)
+	if strings.ToLower(p.DeleteMode) != config.DeleteModeSelect {
+		for i := 0; i < count; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			e.expectEcho(KeyBackspace)
+			_ = inj.TapKey(InjectBackspace)
+			time.Sleep(delay)
+		}
+		return nil
+	}
+
+	for i := 0; i < count; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_ = inj.TapKey(InjectLeft, InjectShift)
+		time.Sleep(delay)
+	}
+	e.expectEcho(KeyBackspace)
+	_ = inj.TapKey(InjectBackspace)
+	return nil
+}
+
+// typeWithProfile types text honouring the profile's keystroke delay,
+// newline key and auto-indent compensation.
+func (e *Expander) typeWithProfile(ctx context.Context, inj Injector, text string, p config.InjectionProfile) error {
+	delay := keyDelay(p)
+	if p.NewlineKey == "" && !p.AutoIndentCompensation {
+		return e.typeChunked(ctx, inj, text, delay)
+	}
+
+	newline := p.NewlineKey
+	if newline == "" {
+		newline = InjectEnter
+	}
+
+	lines := strings.Split(text, "\n")
+	prevIndent := 0
+	for i, line := range lines {
+		if i > 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			e.expectEcho(shortcutEcho(newline))
+			_ = tapShortcut(inj, newline)
+			time.Sleep(delay)
+		}
+
+		if p.AutoIndentCompensation && i > 0 {
+			var dedent int
+			line, prevIndent, dedent = compensateIndent(line, prevIndent)
+			for j := 0; j < dedent; j++ {
+				e.expectEcho(KeyBackspace)
+				_ = inj.TapKey(InjectBackspace)
+				time.Sleep(delay)
+			}
+		} else {
+			prevIndent = len(line) - len(strings.TrimLeft(line, " \t"))
+		}
+
+		if err := e.typeChunked(ctx, inj, line, delay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compensateIndent adjusts a line for an editor that repeats the previous
+// line's indentation after Enter. Indentation shared with the previous line
+// is not typed again; dedents must be undone with the returned number of
+// backspaces. It also returns the text still to be typed and the line's full
+// indentation width.
+func compensateIndent(line string, prevIndent int) (rest string, indent, dedent int) {
+	if strings.TrimSpace(line) == "" {
+		// Blank lines keep whatever indentation the editor inserted.
+		return "", prevIndent, 0
+	}
+
+	indent = len(line) - len(strings.TrimLeft(line, " \t"))
+	if indent >= prevIndent {
+		return line[prevIndent:], indent, 0
+	}
+	return line[indent:], indent, prevIndent - indent
+}
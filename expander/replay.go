@@ -0,0 +1,265 @@
+package expander
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	hook "github.com/robotn/gohook"
+)
+
+// RecordedEvent is one line of a recorded keyboard session. T is the time in
+// milliseconds since the recording started. An event carries exactly one of:
+//
+//   - Key: a logical key as passed to Expander.OnKeyPress ("a", "SPACE", ...)
+//   - Kind "press" or "typed" with the raw hook fields, as written by
+//     EventRecorder; these go through the same translation as live input
+//   - Shortcut: an editing shortcut such as "ctrl+z" that only the focused
+//     application sees
+type RecordedEvent struct {
+	T        int64  `json:"t"`
+	Key      string `json:"key,omitempty"`
+	Shortcut string `json:"shortcut,omitempty"`
+
+	Kind    string `json:"kind,omitempty"`
+	Keycode uint16 `json:"keycode,omitempty"`
+	Rawcode uint16 `json:"rawcode,omitempty"`
+	Keychar rune   `json:"keychar,omitempty"`
+	Mask    uint16 `json:"mask,omitempty"`
+}
+
+// Raw event kinds in recordings.
+const (
+	RecordedPress = "press"
+	RecordedTyped = "typed"
+)
+
+// hookEvent converts a raw recorded event back into a hook event.
+func (r RecordedEvent) hookEvent() hook.Event {
+	ev := hook.Event{
+		Keycode: r.Keycode,
+		Rawcode: r.Rawcode,
+		Mask:    r.Mask,
+		Keychar: hook.CharUndefined,
+	}
+	if r.Kind == RecordedTyped {
+		ev.Kind = hook.KeyDown
+	} else {
+		ev.Kind = hook.KeyHold
+	}
+	if r.Keychar != 0 {
+		ev.Keychar = r.Keychar
+	}
+	return ev
+}
+
+// ReadRecording parses a JSON-lines recording. Blank lines are skipped.
+func ReadRecording(r io.Reader) ([]RecordedEvent, error) {
+	var events []RecordedEvent
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+
+		var ev RecordedEvent
+		if err := json.Unmarshal([]byte(text), &ev); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ev.Key == "" && ev.Shortcut == "" && ev.Kind != RecordedPress && ev.Kind != RecordedTyped {
+			return nil, fmt.Errorf("line %d: event has no key, shortcut or kind", line)
+		}
+		events = append(events, ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ReplayKeyboard is a Keyboard that plays back a recorded session instead of
+// listening to the desktop. It never sees input sent by the Injector.
+type ReplayKeyboard struct {
+	events []RecordedEvent
+	speed  float64
+
+	mu         sync.Mutex
+	onKeyPress func(key string)
+	onShortcut func(shortcut string)
+	running    bool
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewReplayKeyboard creates a keyboard that replays events. By default the
+// original timing is kept; see SetSpeed.
+func NewReplayKeyboard(events []RecordedEvent) *ReplayKeyboard {
+	return &ReplayKeyboard{events: events, speed: 1, done: make(chan struct{})}
+}
+
+// SetOnKeyPress sets the callback invoked for each logical key.
+func (k *ReplayKeyboard) SetOnKeyPress(cb func(key string)) {
+	k.mu.Lock()
+	k.onKeyPress = cb
+	k.mu.Unlock()
+}
+
+// SetOnShortcut sets the callback invoked for shortcut events.
+func (k *ReplayKeyboard) SetOnShortcut(cb func(shortcut string)) {
+	k.mu.Lock()
+	k.onShortcut = cb
+	k.mu.Unlock()
+}
+
+// SetSpeed scales playback: 2 plays twice as fast, 0 ignores timestamps.
+func (k *ReplayKeyboard) SetSpeed(speed float64) {
+	k.mu.Lock()
+	k.speed = speed
+	k.mu.Unlock()
+}
+
+// ObservesInjected reports false: replayed input does not include what the
+// expander types.
+func (k *ReplayKeyboard) ObservesInjected() bool {
+	return false
+}
+
+// Start begins playback in a background goroutine.
+func (k *ReplayKeyboard) Start() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.running {
+		return nil
+	}
+	k.running = true
+	k.stop = make(chan struct{})
+	go k.play(k.stop)
+	return nil
+}
+
+// Stop ends playback early.
+func (k *ReplayKeyboard) Stop() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !k.running {
+		return
+	}
+	close(k.stop)
+	k.running = false
+}
+
+// Done is closed once every event has been delivered or playback stopped.
+func (k *ReplayKeyboard) Done() <-chan struct{} {
+	return k.done
+}
+
+func (k *ReplayKeyboard) play(stop chan struct{}) {
+	defer close(k.done)
+
+	var tr keyTranslator
+	var last int64
+	for _, ev := range k.events {
+		k.mu.Lock()
+		speed, onKey, onShortcut := k.speed, k.onKeyPress, k.onShortcut
+		k.mu.Unlock()
+
+		if speed > 0 && ev.T > last {
+			select {
+			case <-time.After(time.Duration(float64(ev.T-last)/speed) * time.Millisecond):
+			case <-stop:
+				return
+			}
+		} else {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+		last = ev.T
+
+		switch {
+		case ev.Shortcut != "":
+			if onShortcut != nil {
+				onShortcut(ev.Shortcut)
+			}
+		case ev.Key != "":
+			if onKey != nil {
+				onKey(ev.Key)
+			}
+		default:
+			for _, key := range tr.Translate(ev.hookEvent()) {
+				if onKey != nil {
+					onKey(key)
+				}
+			}
+		}
+	}
+}
+
+// EventRecorder writes raw hook events as a JSON-lines recording that
+// ReplayKeyboard can play back.
+type EventRecorder struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+}
+
+// NewEventRecorder creates a recorder writing to w.
+func NewEventRecorder(w io.Writer) *EventRecorder {
+	return &EventRecorder{enc: json.NewEncoder(w)}
+}
+
+// Record writes ev if it is a key press or typed event.
+func (r *EventRecorder) Record(ev hook.Event) error {
+	var kind string
+	switch ev.Kind {
+	case hook.KeyHold:
+		kind = RecordedPress
+	case hook.KeyDown:
+		kind = RecordedTyped
+	default:
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.start.IsZero() {
+		r.start = now
+	}
+	rec := RecordedEvent{
+		T:       now.Sub(r.start).Milliseconds(),
+		Kind:    kind,
+		Keycode: ev.Keycode,
+		Rawcode: ev.Rawcode,
+		Mask:    ev.Mask,
+	}
+	if ev.Keychar != hook.CharUndefined {
+		rec.Keychar = ev.Keychar
+	}
+	return r.enc.Encode(rec)
+}
+
+// RecordKey writes a logical key, for keyboards that do not produce hook
+// events.
+func (r *EventRecorder) RecordKey(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.start.IsZero() {
+		r.start = now
+	}
+	return r.enc.Encode(RecordedEvent{T: now.Sub(r.start).Milliseconds(), Key: key})
+}
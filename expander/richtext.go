@@ -0,0 +1,69 @@
+package expander
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"text-expander/config"
+)
+
+// cursorMarker is a private-use rune that stands in for the {CURSOR}
+// position while rich content is rendered, so the caret can be placed
+// relative to the visible text rather than the source markup.
+const cursorMarker = '\uE000'
+
+// isRichFormat reports whether the output format is delivered through the
+// clipboard rather than typed.
+func isRichFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case config.OutputFormatMarkdown, config.OutputFormatHTML:
+		return true
+	}
+	return false
+}
+
+// renderRich converts processed replacement text into an HTML fragment and
+// a plain-text fallback. cursorOffset is measured in runes from the end of
+// text; the returned offset is measured from the end of the plain fallback.
+func renderRich(format, text string, cursorOffset int) (htmlOut, plain string, plainOffset int) {
+	if cursorOffset > 0 {
+		runes := []rune(text)
+		pos := len(runes) - cursorOffset
+		if pos < 0 {
+			pos = 0
+		}
+		text = string(runes[:pos]) + string(cursorMarker) + string(runes[pos:])
+	}
+
+	if strings.ToLower(format) == config.OutputFormatMarkdown {
+		htmlOut = MarkdownToHTML(text)
+	} else {
+		htmlOut = text
+	}
+	plain = HTMLToPlainText(htmlOut)
+
+	marker := string(cursorMarker)
+	if idx := strings.Index(plain, marker); idx >= 0 {
+		plainOffset = utf8.RuneCountInString(plain[idx+len(marker):])
+	}
+	htmlOut = strings.ReplaceAll(htmlOut, marker, "")
+	plain = strings.ReplaceAll(plain, marker, "")
+	return htmlOut, plain, plainOffset
+}
+
+// cfHTML wraps an HTML fragment in the "HTML Format" clipboard envelope
+// used on Windows. All offsets are byte offsets into the returned string.
+func cfHTML(fragment string) string {
+	const header = "Version:0.9\r\nStartHTML:%010d\r\nEndHTML:%010d\r\nStartFragment:%010d\r\nEndFragment:%010d\r\n"
+	const prefix = "<html><body><!--StartFragment-->"
+	const suffix = "<!--EndFragment--></body></html>"
+
+	headerLen := len(fmt.Sprintf(header, 0, 0, 0, 0))
+	startHTML := headerLen
+	startFragment := startHTML + len(prefix)
+	endFragment := startFragment + len(fragment)
+	endHTML := endFragment + len(suffix)
+
+	return fmt.Sprintf(header, startHTML, endHTML, startFragment, endFragment) + prefix + fragment + suffix
+}
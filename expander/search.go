@@ -0,0 +1,133 @@
+package expander
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"text-expander/utils"
+)
+
+// Weights of the fields searched by SearchExpansions; a hit in the trigger
+// counts for more than one buried in the replacement.
+const (
+	weightTrigger     = 4
+	weightDescription = 3
+	weightCategory    = 2
+	weightReplacement = 1
+)
+
+// FuzzyScore reports whether the runes of query appear in text in order,
+// ignoring case, and scores the match. Consecutive runes, runes at the start
+// of a word and a prefix of text score higher, and matching all of it most.
+// Leading punctuation, such as a trigger's ";", is ignored for prefixes.
+func FuzzyScore(query, text string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, true
+	}
+	t := []rune(strings.ToLower(text))
+
+	start := 0
+	for start < len(t) && !unicode.IsLetter(t[start]) && !unicode.IsDigit(t[start]) {
+		start++
+	}
+
+	score, qi, prev := 0, 0, -2
+	prefix := true
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		score++
+		if ti == prev+1 {
+			score += 3
+		}
+		if ti == 0 || (!unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1])) {
+			score += 2
+		}
+		if prefix = prefix && ti == start+qi; prefix {
+			score += 2
+		}
+		prev = ti
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	if prefix && prev == len(t)-1 {
+		score += 5
+	}
+	return score, true
+}
+
+// usageScore favours expansions used recently and often.
+func usageScore(u utils.Usage, now time.Time) int {
+	if u.Count == 0 {
+		return 0
+	}
+	score := u.Count
+	if score > 20 {
+		score = 20
+	}
+	switch age := now.Sub(u.Last); {
+	case age < 24*time.Hour:
+		score += 30
+	case age < 7*24*time.Hour:
+		score += 20
+	case age < 30*24*time.Hour:
+		score += 10
+	}
+	return score
+}
+
+// SearchExpansions returns the expansions matching query in their trigger,
+// hotkey, description, category or replacement, best first. Ties, and every
+// expansion when query is empty, are ordered by recent usage, as read by
+// utils.ReadUsage.
+func SearchExpansions(exps []Expansion, query string, usage map[string]utils.Usage, now time.Time) []Expansion {
+	type result struct {
+		exp   Expansion
+		score int
+	}
+
+	query = strings.TrimSpace(query)
+	var results []result
+	for _, exp := range exps {
+		best, found := 0, query == ""
+		for _, f := range []struct {
+			text   string
+			weight int
+		}{
+			{exp.Trigger, weightTrigger},
+			{exp.Hotkey, weightTrigger},
+			{exp.Description, weightDescription},
+			{exp.Category, weightCategory},
+			{exp.Replacement, weightReplacement},
+		} {
+			if s, ok := FuzzyScore(query, f.text); ok && f.text != "" {
+				found = true
+				if s*f.weight > best {
+					best = s * f.weight
+				}
+			}
+		}
+		if found {
+			results = append(results, result{exp, best + usageScore(utils.UsageFor(usage, exp.ID, exp.Name()), now)})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].exp.Name() < results[j].exp.Name()
+	})
+
+	out := make([]Expansion, len(results))
+	for i, r := range results {
+		out[i] = r.exp
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package expander
+
+import (
+	"testing"
+	"time"
+
+	"text-expander/config"
+	"text-expander/utils"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := FuzzyScore("sgn", "signature"); !ok {
+		t.Fatalf("expected subsequence to match")
+	}
+	if _, ok := FuzzyScore("sgx", "signature"); ok {
+		t.Fatalf("expected missing rune not to match")
+	}
+
+	prefix, _ := FuzzyScore("sig", "signature")
+	scattered, _ := FuzzyScore("sig", "its a big one")
+	if prefix <= scattered {
+		t.Fatalf("prefix match scored %d, scattered %d", prefix, scattered)
+	}
+}
+
+func TestSearchExpansionsRanksFieldsAndUsage(t *testing.T) {
+	exps := []config.Expansion{
+		{Trigger: ";addr", Description: "Home address", Replacement: "1 Main St"},
+		{Trigger: ";sig", Description: "Email signature", Replacement: "Best regards"},
+		{Trigger: ";sign", Description: "Sign-off", Replacement: "Cheers"},
+		{ID: "t1", Hotkey: "ctrl+alt+t", Description: "Thanks", Category: "Professional", Replacement: "Thank you"},
+	}
+
+	got := SearchExpansions(exps, "sig", nil, time.Now())
+	if len(got) != 2 || got[0].Trigger != ";sig" {
+		t.Fatalf("unexpected results %+v", got)
+	}
+
+	// Matches in the category and replacement count too.
+	if got := SearchExpansions(exps, "profess", nil, time.Now()); len(got) != 1 || got[0].Hotkey != "ctrl+alt+t" {
+		t.Fatalf("expected category match, got %+v", got)
+	}
+	if got := SearchExpansions(exps, "main st", nil, time.Now()); len(got) != 1 || got[0].Trigger != ";addr" {
+		t.Fatalf("expected replacement match, got %+v", got)
+	}
+
+	// With no query everything is listed, most recently used first.
+	now := time.Now()
+	usage := map[string]utils.Usage{
+		";addr": {Count: 2, Last: now.Add(-40 * 24 * time.Hour)},
+		"t1":    {Count: 1, Last: now.Add(-time.Hour)},
+	}
+	got = SearchExpansions(exps, "", usage, now)
+	if len(got) != len(exps) || got[0].Hotkey != "ctrl+alt+t" || got[1].Trigger != ";addr" {
+		t.Fatalf("unexpected order %+v", got)
+	}
+}
@@ -0,0 +1,46 @@
+package expander
+
+import (
+	"text-expander/config"
+)
+
+// SimulateOptions controls Simulate.
+type SimulateOptions struct {
+	// Realtime replays events with their recorded timing and does not wait
+	// for each expansion to finish before delivering the next key, so keys
+	// typed mid-expansion reach the document as they would on a desktop.
+	Realtime bool
+}
+
+// Simulate replays a recorded session against cfg without touching the
+// desktop. User keys and shortcuts are applied to a virtual document before
+// the expander sees them, and everything the expander injects is applied to
+// the same document, which is returned once playback and all expansions have
+// finished.
+func Simulate(cfg *config.Config, events []RecordedEvent, opts SimulateOptions) *Document {
+	doc := NewDocument()
+	kb := NewReplayKeyboard(events)
+	if !opts.Realtime {
+		kb.SetSpeed(0)
+	}
+
+	e := NewExpanderWithKeyboard(cfg, kb)
+	defer e.Close()
+	e.SetInjector(NewDocumentInjector(doc))
+	e.window = func() windowInfo { return windowInfo{Handle: 1, Title: "simulate"} }
+	e.allow = func() bool { return true }
+
+	kb.SetOnShortcut(doc.ApplyShortcut)
+	kb.SetOnKeyPress(func(key string) {
+		doc.ApplyKey(key)
+		e.OnKeyPress(key)
+		if !opts.Realtime {
+			e.WaitIdle()
+		}
+	})
+
+	_ = kb.Start()
+	<-kb.Done()
+	e.WaitIdle()
+	return doc
+}
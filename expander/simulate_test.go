@@ -0,0 +1,121 @@
+package expander
+
+import (
+	"strings"
+	"testing"
+
+	"text-expander/config"
+)
+
+func simulate(t *testing.T, cfg *config.Config, recording string) *Document {
+	t.Helper()
+
+	events, err := ReadRecording(strings.NewReader(recording))
+	if err != nil {
+		t.Fatalf("ReadRecording: %v", err)
+	}
+	return Simulate(cfg, events, SimulateOptions{})
+}
+
+// simulateConfig enables exps with Space and Enter as terminators.
+func simulateConfig(exps ...config.Expansion) *config.Config {
+	return &config.Config{
+		Expansions: exps,
+		Settings:   config.Settings{Enabled: true, TriggerOnSpace: true, TriggerOnEnter: true},
+	}
+}
+
+func TestSimulateExpansionWithCursor(t *testing.T) {
+	cfg := simulateConfig(config.Expansion{Trigger: ";hi", Replacement: "Hello{CURSOR}!"})
+
+	doc := simulate(t, cfg, `
+{"t": 0, "key": ";"}
+{"t": 80, "key": "h"}
+{"t": 150, "key": "i"}
+{"t": 230, "key": "SPACE"}
+{"t": 400, "key": "x"}
+`)
+
+	if got := doc.TextWithCaret("|"); got != "Hellox|!" {
+		t.Fatalf("unexpected document %q", got)
+	}
+}
+
+func TestSimulateKeepTerminator(t *testing.T) {
+	cfg := simulateConfig(config.Expansion{Trigger: ";hi", Replacement: "Hello{CURSOR}!"})
+	cfg.Settings.KeepTerminator = true
+
+	doc := simulate(t, cfg, `
+{"t": 0, "key": ";"}
+{"t": 80, "key": "h"}
+{"t": 150, "key": "i"}
+{"t": 230, "key": "SPACE"}
+{"t": 400, "key": "x"}
+`)
+
+	if got := doc.TextWithCaret("|"); got != "Hellox|! " {
+		t.Fatalf("unexpected document %q", got)
+	}
+}
+
+func TestSimulateUserBackspaceBeforeTrigger(t *testing.T) {
+	cfg := simulateConfig(config.Expansion{Trigger: ";addr", Replacement: "1 Main St"})
+
+	doc := simulate(t, cfg, `
+{"t": 0, "key": ";"}
+{"t": 10, "key": "a"}
+{"t": 20, "key": "x"}
+{"t": 30, "key": "BACKSPACE"}
+{"t": 40, "key": "d"}
+{"t": 50, "key": "d"}
+{"t": 60, "key": "r"}
+{"t": 70, "key": "ENTER"}
+`)
+
+	if got := doc.Text(); got != "1 Main St" {
+		t.Fatalf("unexpected document %q", got)
+	}
+}
+
+func TestSimulateRawEventsWithDeadKey(t *testing.T) {
+	cfg := simulateConfig(config.Expansion{Trigger: ";café", Replacement: "coffee"})
+
+	// ";caf", a dead acute reported as a combining mark, "e", Space.
+	doc := simulate(t, cfg, `
+{"t": 0, "kind": "typed", "keychar": 59}
+{"t": 10, "kind": "typed", "keychar": 99}
+{"t": 20, "kind": "typed", "keychar": 97}
+{"t": 30, "kind": "typed", "keychar": 102}
+{"t": 40, "kind": "typed", "keychar": 769}
+{"t": 50, "kind": "typed", "keychar": 101}
+{"t": 60, "kind": "typed", "keychar": 32}
+`)
+
+	if got := doc.Text(); got != "coffee" {
+		t.Fatalf("unexpected document %q", got)
+	}
+}
+
+func TestSimulateUndoRestoresTrigger(t *testing.T) {
+	cfg := simulateConfig(config.Expansion{Trigger: ";sig", Replacement: "Best regards"})
+
+	doc := simulate(t, cfg, `
+{"t": 0, "key": ";"}
+{"t": 10, "key": "s"}
+{"t": 20, "key": "i"}
+{"t": 30, "key": "g"}
+{"t": 40, "key": "SPACE"}
+{"t": 500, "shortcut": "ctrl+z"}
+`)
+
+	if got := doc.Text(); got != ";sig " {
+		t.Fatalf("expected undo to restore the trigger, got %q", got)
+	}
+}
+
+func TestReadRecordingReportsLine(t *testing.T) {
+	_, err := ReadRecording(strings.NewReader("{\"t\": 0, \"key\": \"a\"}\n{\"t\": 1}\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an error for line 2, got %v", err)
+	}
+}
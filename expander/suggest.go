@@ -0,0 +1,138 @@
+package expander
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// suggestionLimit caps the number of suggestions offered at once.
+const suggestionLimit = 8
+
+// PartialTrigger returns the partial trigger at the end of buffer: the text
+// from the last occurrence of prefix on, provided it contains no whitespace
+// and is at least minLen runes long.
+func PartialTrigger(buffer, prefix string, minLen int) (string, bool) {
+	if prefix == "" {
+		return "", false
+	}
+	i := strings.LastIndex(buffer, prefix)
+	if i < 0 {
+		return "", false
+	}
+	partial := buffer[i:]
+	if strings.IndexFunc(partial, unicode.IsSpace) >= 0 || utf8.RuneCountInString(partial) < minLen {
+		return "", false
+	}
+	return partial, true
+}
+
+// MatchPrefix returns the expansions whose trigger starts with partial,
+// honouring their case sensitivity. Shorter triggers come first, so a
+// trigger typed in full is the top suggestion.
+func MatchPrefix(partial string, expansions map[string]Expansion) []Expansion {
+	if partial == "" {
+		return nil
+	}
+
+	lower := strings.ToLower(partial)
+	var out []Expansion
+	for _, exp := range expansions {
+		if exp.CaseSensitive {
+			if !strings.HasPrefix(exp.Trigger, partial) {
+				continue
+			}
+		} else if !strings.HasPrefix(strings.ToLower(exp.Trigger), lower) {
+			continue
+		}
+		out = append(out, exp)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		li, lj := utf8.RuneCountInString(out[i].Trigger), utf8.RuneCountInString(out[j].Trigger)
+		if li != lj {
+			return li < lj
+		}
+		return out[i].Trigger < out[j].Trigger
+	})
+	if len(out) > suggestionLimit {
+		out = out[:suggestionLimit]
+	}
+	return out
+}
+
+// SetSuggestionCallback sets the function told about the suggestions for the
+// trigger being typed whenever they change; an empty list means there are
+// none to show. It is called on the worker, so it must not block.
+func (e *Expander) SetSuggestionCallback(fn func(partial string, exps []Expansion)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.suggestFunc = fn
+}
+
+// updateSuggestions recomputes the suggestions from the buffer and reports
+// them if they changed. It runs on the worker goroutine.
+func (e *Expander) updateSuggestions() {
+	e.mu.RLock()
+	cfg := e.config
+	expansions := e.expansions
+	fn := e.suggestFunc
+	e.mu.RUnlock()
+
+	var partial string
+	var exps []Expansion
+	if cfg != nil {
+		settings := cfg.GetSettings()
+		s := settings.Suggestions
+		if s.Enabled && settings.Enabled && e.PausedUntil().IsZero() {
+			if p, ok := PartialTrigger(e.buffer.String(), s.Prefix(), s.MinPrefix()); ok {
+				if exps = MatchPrefix(p, expansions); len(exps) > 0 {
+					partial = p
+				}
+			}
+		}
+	}
+
+	if partial == e.suggestPartial && sameTriggers(exps, e.suggestions) {
+		return
+	}
+	e.suggestPartial, e.suggestions = partial, exps
+	if fn != nil {
+		fn(partial, exps)
+	}
+}
+
+// sameTriggers reports whether a and b list the same triggers in order.
+func sameTriggers(a, b []Expansion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Trigger != b[i].Trigger {
+			return false
+		}
+	}
+	return true
+}
+
+// AcceptSuggestion replaces the partial trigger being typed with the top
+// suggestion, as if the whole trigger had been typed, once the hotkey's
+// modifiers are released. It does nothing when nothing is suggested.
+func (e *Expander) AcceptSuggestion() {
+	e.waitModifiersReleased()
+	run := func() {
+		e.mu.RLock()
+		cfg := e.config
+		e.mu.RUnlock()
+		if len(e.suggestions) == 0 || !cfg.GetSettings().Enabled || !e.PausedUntil().IsZero() {
+			return
+		}
+		exp, partial := e.suggestions[0], e.suggestPartial
+		e.performExpansion(exp, e.window(), partial, "")
+	}
+	if !e.queue(keyEvent{run: run}) {
+		log.Printf("expander: event queue full, dropping accepted suggestion")
+	}
+}
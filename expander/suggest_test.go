@@ -0,0 +1,93 @@
+package expander
+
+import (
+	"reflect"
+	"testing"
+
+	"text-expander/config"
+)
+
+func TestPartialTrigger(t *testing.T) {
+	tests := []struct {
+		buffer string
+		want   string
+		ok     bool
+	}{
+		{"hello ;em", ";em", true},
+		{";em", ";em", true},
+		{"a;b;sig", ";sig", true},
+		{"hello ;e", "", false},  // too short
+		{";em ail", "", false},   // whitespace after the prefix
+		{"no prefix", "", false}, // nothing to complete
+	}
+	for _, tt := range tests {
+		got, ok := PartialTrigger(tt.buffer, ";", 3)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("PartialTrigger(%q) = %q, %v; want %q, %v", tt.buffer, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMatchPrefix(t *testing.T) {
+	exps := map[string]Expansion{
+		";email":  {Trigger: ";email"},
+		";em":     {Trigger: ";em"},
+		";Emoji":  {Trigger: ";Emoji", CaseSensitive: true},
+		";EMAIL2": {Trigger: ";EMAIL2"},
+		";sig":    {Trigger: ";sig"},
+	}
+
+	var got []string
+	for _, exp := range MatchPrefix(";em", exps) {
+		got = append(got, exp.Trigger)
+	}
+	if want := []string{";em", ";email", ";EMAIL2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestAcceptSuggestionReplacesPartialTrigger(t *testing.T) {
+	cfg := &config.Config{
+		Expansions: []config.Expansion{
+			{Trigger: ";email", Replacement: "me@example.com"},
+			{Trigger: ";emoji", Replacement: ":)"},
+		},
+		Settings: config.Settings{
+			Enabled:     true,
+			Suggestions: config.Suggestions{Enabled: true},
+		},
+	}
+
+	e, inj := newTestExpander(t, cfg)
+	var shown []string
+	e.SetSuggestionCallback(func(partial string, exps []Expansion) {
+		shown = append(shown, partial)
+	})
+
+	typeKeys(e, "x;e")
+	e.WaitIdle()
+	if len(shown) != 0 {
+		t.Fatalf("suggestions shown before the minimum prefix length: %q", shown)
+	}
+
+	typeKeys(e, "m")
+	e.AcceptSuggestion()
+	e.WaitIdle()
+
+	if want := []string{";em", ""}; !reflect.DeepEqual(shown, want) {
+		t.Fatalf("suggestion updates = %q, want %q", shown, want)
+	}
+
+	backspaces, typed := 0, ""
+	for _, ev := range inj.Events() {
+		switch {
+		case ev.Kind == "tap" && ev.Key == InjectBackspace:
+			backspaces++
+		case ev.Kind == "type":
+			typed += ev.Text
+		}
+	}
+	if backspaces != 3 || typed != "me@example.com" {
+		t.Fatalf("got %d backspaces and %q typed, want 3 and %q", backspaces, typed, "me@example.com")
+	}
+}
@@ -101,6 +101,9 @@ func (tp *TemplateProcessor) Process(template string) (result string, cursorOffs
 
 				// Handle built-in variables
 				switch upperToken {
+				case "{":
+					// {{} is a literal brace.
+					builder.WriteRune('{')
 				case "DATE":
 					builder.WriteString(dateStr)
 				case "TIME":
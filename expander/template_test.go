@@ -39,4 +39,14 @@ func TestTemplateProcessorCustomVar(t *testing.T) {
 	if result != "Hi Alice" {
 		t.Fatalf("unexpected result with custom var: %q", result)
 	}
-}
\ No newline at end of file
+}
+
+func TestTemplateProcessorEscapedBrace(t *testing.T) {
+	tp := NewTemplateProcessor()
+	tp.SetCustomVar("NAME", "Alice")
+	result, _ := tp.Process("{{}NAME} is {NAME}, {{}DATE} and {x}")
+
+	if result != "{NAME} is Alice, {DATE} and {x}" {
+		t.Fatalf("unexpected result with escaped braces: %q", result)
+	}
+}
@@ -0,0 +1,186 @@
+package expander
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	hook "github.com/robotn/gohook"
+	"golang.org/x/text/unicode/norm"
+)
+
+// libuiohook virtual key codes. Unlike raw codes they are the same on every
+// platform and do not depend on the keyboard layout.
+const (
+	vcEscape    = 0x0001
+	vcBackspace = 0x000E
+	vcTab       = 0x000F
+	vcEnter     = 0x001C
+	vcKPEnter   = 0x0E1C
+)
+
+// vkProcessKey is the raw code Windows reports for keys consumed by an IME
+// while it is composing.
+const vkProcessKey = 0xE5
+
+// Modifier masks reported by libuiohook (left and right variants).
+const (
+	maskCtrl = 1<<1 | 1<<5
+	maskMeta = 1<<2 | 1<<6
+	maskAlt  = 1<<3 | 1<<7
+)
+
+// deadAccents maps the characters a dead key may report to the combining
+// mark it applies. Platforms report either the spacing accent or the
+// combining mark itself.
+var deadAccents = map[rune]rune{
+	'`':      '\u0300', // grave
+	'\u00B4': '\u0301', // acute
+	'\'':     '\u0301', // US-International acute
+	'^':      '\u0302', // circumflex
+	'~':      '\u0303', // tilde
+	'\u00A8': '\u0308', // diaeresis
+	'"':      '\u0308', // US-International diaeresis
+	'\u02DA': '\u030A', // ring above
+	'\u02C7': '\u030C', // caron
+	'\u00B8': '\u0327', // cedilla
+}
+
+// spacingAccents maps combining marks back to the character typed when a
+// dead key is followed by Space.
+var spacingAccents = map[rune]rune{
+	'\u0300': '`',
+	'\u0301': '\u00B4',
+	'\u0302': '^',
+	'\u0303': '~',
+	'\u0308': '\u00A8',
+	'\u030A': '\u02DA',
+	'\u030C': '\u02C7',
+	'\u0327': '\u00B8',
+}
+
+// keyTranslator turns the hook's event stream into logical keys. Characters
+// come from the OS-composed Keychar of typed events, so the active layout,
+// AltGr and IME commits are honoured; raw codes are never mapped to
+// characters. Non-printing keys come from the layout-independent key code of
+// press events.
+//
+// A dead key either produces no event at all, a combining mark, or its
+// spacing accent. The translator holds a possible accent back until the next
+// character shows whether it was composed into it. Keys consumed by an IME
+// during composition are ignored; only the committed text is seen.
+type keyTranslator struct {
+	pending rune // accent awaiting the next character, 0 if none
+	high    rune // high surrogate of a UTF-16 pair, 0 if none
+}
+
+// Translate returns the logical keys produced by ev, if any.
+func (t *keyTranslator) Translate(ev hook.Event) []string {
+	switch ev.Kind {
+	case hook.KeyHold:
+		return t.pressed(ev)
+	case hook.KeyDown:
+		return t.typed(ev)
+	}
+	return nil
+}
+
+func (t *keyTranslator) pressed(ev hook.Event) []string {
+	switch ev.Keycode {
+	case vcBackspace:
+		t.high = 0
+		if t.pending != 0 {
+			// The held-back accent is what gets deleted.
+			t.pending = 0
+			return nil
+		}
+		return []string{KeyBackspace}
+	case vcEscape:
+		t.pending, t.high = 0, 0
+		return []string{KeyEscape}
+	case vcEnter, vcKPEnter:
+		return t.flush(KeyEnter)
+	case vcTab:
+		return t.flush(KeyTab)
+	}
+	return nil
+}
+
+func (t *keyTranslator) typed(ev hook.Event) []string {
+	if ev.Rawcode == vkProcessKey {
+		return nil
+	}
+	r := ev.Keychar
+	if r == 0 || r == hook.CharUndefined {
+		return nil
+	}
+	// Shortcuts such as Ctrl+V or Cmd+V are not text. AltGr is reported as
+	// Ctrl+Alt, and its characters are.
+	if ev.Mask&(maskCtrl|maskMeta) != 0 && ev.Mask&maskAlt == 0 {
+		return nil
+	}
+
+	if utf16.IsSurrogate(r) {
+		if r < 0xDC00 {
+			t.high = r
+			return nil
+		}
+		if t.high == 0 {
+			return nil
+		}
+		r, t.high = utf16.DecodeRune(t.high, r), 0
+	}
+	// Control characters duplicate the press events handled above.
+	if r < 0x20 || r == 0x7F {
+		return nil
+	}
+
+	if _, ok := spacingAccents[r]; ok {
+		out := t.flush()
+		t.pending = r
+		return out
+	}
+	if _, ok := deadAccents[r]; ok {
+		out := t.flush()
+		t.pending = r
+		return out
+	}
+	if t.pending == 0 {
+		return []string{runeKey(r)}
+	}
+
+	p := t.pending
+	t.pending = 0
+	mark, combining := p, true
+	if m, ok := deadAccents[p]; ok {
+		mark, combining = m, false
+	}
+
+	switch {
+	case strings.ContainsRune(norm.NFD.String(string(r)), mark):
+		// The OS already composed the accent into r.
+		return []string{runeKey(r)}
+	case combining && r == ' ':
+		return []string{string(spacingAccents[mark])}
+	case combining:
+		var out []string
+		for _, c := range norm.NFC.String(string(r) + string(mark)) {
+			out = append(out, runeKey(c))
+		}
+		return out
+	default:
+		return []string{string(p), runeKey(r)}
+	}
+}
+
+// flush emits a held-back accent as typed, followed by keys.
+func (t *keyTranslator) flush(keys ...string) []string {
+	if t.pending == 0 {
+		return keys
+	}
+	p := t.pending
+	t.pending = 0
+	if s, ok := spacingAccents[p]; ok {
+		p = s
+	}
+	return append([]string{string(p)}, keys...)
+}
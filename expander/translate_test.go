@@ -0,0 +1,203 @@
+package expander
+
+import (
+	"reflect"
+	"testing"
+
+	hook "github.com/robotn/gohook"
+)
+
+// stroke records the events the hook delivers for one key: a press carrying
+// the layout-independent key code, and a typed event carrying the character
+// the OS composed (if any).
+func stroke(keycode, rawcode uint16, char rune, mask uint16) []hook.Event {
+	evs := []hook.Event{{Kind: hook.KeyHold, Keycode: keycode, Rawcode: rawcode, Mask: mask, Keychar: hook.CharUndefined}}
+	if char != 0 {
+		evs = append(evs, hook.Event{Kind: hook.KeyDown, Rawcode: rawcode, Mask: mask, Keychar: char})
+	}
+	return evs
+}
+
+// typedOnly is a typed event without a preceding press, as some platforms
+// report dead keys and IME commits.
+func typedOnly(char rune, rawcode uint16) []hook.Event {
+	return []hook.Event{{Kind: hook.KeyDown, Rawcode: rawcode, Keychar: char}}
+}
+
+func seq(strokes ...[]hook.Event) []hook.Event {
+	var out []hook.Event
+	for _, s := range strokes {
+		out = append(out, s...)
+	}
+	return out
+}
+
+func TestKeyTranslatorRecordedSequences(t *testing.T) {
+	const (
+		altGr    = 1<<1 | 1<<7 // Ctrl_L + Alt_R, as Windows reports AltGr
+		ctrl     = 1 << 1
+		meta     = 1 << 2
+		vkPacket = 0xE7
+	)
+
+	cases := []struct {
+		name   string
+		events []hook.Event
+		want   []string
+	}{
+		{
+			name: "US QWERTY trigger",
+			events: seq(
+				stroke(0x27, 0xBA, ';', 0),
+				stroke(0x23, 'H', 'h', 0),
+				stroke(0x17, 'I', 'i', 0),
+				stroke(0x39, ' ', ' ', 0),
+			),
+			want: []string{";", "h", "i", KeySpace},
+		},
+		{
+			name: "AZERTY number row",
+			events: seq(
+				stroke(0x02, '1', '&', 0),
+				stroke(0x03, '2', 'é', 0),
+			),
+			want: []string{"&", "é"},
+		},
+		{
+			name: "Dvorak with positional raw codes",
+			events: seq(
+				stroke(0x20, 'D', 'e', 0),
+				stroke(0x21, 'F', 'u', 0),
+			),
+			want: []string{"e", "u"},
+		},
+		{
+			name:   "German AltGr+Q",
+			events: stroke(0x10, 'Q', '@', altGr),
+			want:   []string{"@"},
+		},
+		{
+			name: "French dead circumflex composed by the OS",
+			events: seq(
+				stroke(0x1A, 0xDD, 0, 0),
+				stroke(0x12, 'E', 'ê', 0),
+			),
+			want: []string{"ê"},
+		},
+		{
+			name: "dead key echoed as spacing accent before composed char",
+			events: seq(
+				typedOnly('^', 0xDD),
+				stroke(0x12, 'E', 'ê', 0),
+			),
+			want: []string{"ê"},
+		},
+		{
+			name: "dead key reported as combining mark",
+			events: seq(
+				typedOnly('\u0302', 0xFE52),
+				stroke(0x12, 'e', 'e', 0),
+			),
+			want: []string{"ê"},
+		},
+		{
+			name: "Spanish dead tilde",
+			events: seq(
+				typedOnly('\u0303', 0xFE53),
+				stroke(0x31, 'n', 'n', 0),
+			),
+			want: []string{"ñ"},
+		},
+		{
+			name: "dead acute followed by space",
+			events: seq(
+				typedOnly('\u0301', 0xFE51),
+				stroke(0x39, ' ', ' ', 0),
+			),
+			want: []string{"´"},
+		},
+		{
+			name: "literal caret on US layout",
+			events: seq(
+				stroke(0x07, '6', '^', 1),
+				stroke(0x2D, 'X', 'x', 0),
+			),
+			want: []string{"^", "x"},
+		},
+		{
+			name: "US-International apostrophe before consonant",
+			events: seq(
+				stroke(0x28, 0xDE, '\'', 0),
+				stroke(0x1F, 'S', 's', 0),
+			),
+			want: []string{"'", "s"},
+		},
+		{
+			name: "held-back accent deleted with backspace",
+			events: seq(
+				stroke(0x29, 0xC0, '~', 1),
+				stroke(vcBackspace, 8, '\b', 0),
+			),
+			want: nil,
+		},
+		{
+			name: "held-back accent flushed before Enter",
+			events: seq(
+				stroke(0x07, '6', '^', 1),
+				stroke(vcEnter, 13, '\r', 0),
+			),
+			want: []string{"^", KeyEnter},
+		},
+		{
+			name: "Japanese IME composition and commit",
+			events: seq(
+				stroke(0x31, vkProcessKey, 'n', 0),
+				stroke(0x17, vkProcessKey, 'i', 0),
+				stroke(0x39, vkProcessKey, ' ', 0),
+				typedOnly('日', vkPacket),
+				typedOnly('本', vkPacket),
+			),
+			want: []string{"日", "本"},
+		},
+		{
+			name: "emoji delivered as a surrogate pair",
+			events: seq(
+				typedOnly(0xD83D, vkPacket),
+				typedOnly(0xDE00, vkPacket),
+			),
+			want: []string{"\U0001F600"},
+		},
+		{
+			name: "shortcuts are not text",
+			events: seq(
+				stroke(0x2F, 'V', 0x16, ctrl),
+				stroke(0x2F, 'V', 'v', meta),
+			),
+			want: nil,
+		},
+		{
+			name: "named keys",
+			events: seq(
+				stroke(vcBackspace, 8, '\b', 0),
+				stroke(vcTab, 9, '\t', 0),
+				stroke(vcEnter, 13, '\r', 0),
+				stroke(vcKPEnter, 13, '\r', 0),
+				stroke(vcEscape, 27, 0x1B, 0),
+			),
+			want: []string{KeyBackspace, KeyTab, KeyEnter, KeyEnter, KeyEscape},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var tr keyTranslator
+			var got []string
+			for _, ev := range c.events {
+				got = append(got, tr.Translate(ev)...)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 
 	"fyne.io/fyne/v2"
@@ -9,23 +13,44 @@ import (
 
 	"text-expander/config"
 	"text-expander/gui"
+	"text-expander/utils"
 )
 
 func main() {
+	palette := flag.Bool("palette", false, "show the snippet palette and print the chosen expansion's name")
+	overlay := flag.Bool("overlay", false, "show the inline suggestions read from stdin")
+	flag.Parse()
+
 	// Get config path (same as main app)
-	cfgPath := filepath.Join("config", "expansions.json")
+	cfgPath := config.DefaultPath("config")
 
 	// Load configuration
 	cfg, err := config.LoadConfig(cfgPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-		return
+		gui.ShowNotification("Configuration Error", err.Error())
+		// Invalid fields can be fixed in the editor, so it opens anyway.
+		var ve *config.ValidationError
+		if !errors.As(err, &ve) || *palette {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		log.Printf("Opening the editor to fix the config: %v", err)
 	}
 
 	// Create Fyne app
 	a := app.NewWithID("com.textexpander.config")
 	gui.ApplyTheme(a)
 
+	if *palette {
+		runPalette(a, cfg)
+		return
+	}
+	if *overlay {
+		if err := gui.RunOverlay(a, os.Stdin); err != nil {
+			log.Fatalf("Suggestion overlay: %v", err)
+		}
+		return
+	}
+
 	// Create and show editor window
 	w := a.NewWindow("Text Expander Manager")
 	w.Resize(fyne.NewSize(1000, 700))
@@ -37,3 +62,24 @@ func main() {
 	// Show and run (blocks until window closed)
 	w.ShowAndRun()
 }
+
+// runPalette shows the snippet palette. The tray application reads the chosen
+// expansion's name from stdout and inserts it into the window that was
+// focused before; nothing is printed when the palette is dismissed.
+func runPalette(a fyne.App, cfg *config.Config) {
+	usage, err := utils.ReadUsage(filepath.Join("logs", "expander.log"))
+	if err != nil {
+		log.Printf("Failed to read usage: %v", err)
+	}
+
+	w := a.NewWindow("Insert Snippet")
+	w.Resize(fyne.NewSize(640, 420))
+	w.CenterOnScreen()
+
+	gui.ShowPalette(w, cfg, usage, func(exp config.Expansion) {
+		fmt.Println(exp.Name())
+		a.Quit()
+	}, a.Quit)
+
+	w.ShowAndRun()
+}
@@ -3,6 +3,7 @@ package gui
 import (
 	"fmt"
 	"image/color"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -13,8 +14,10 @@ import (
 	"text-expander/config"
 )
 
-// ShowExpansionDialog shows a simplified, readable dialog
-func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *config.Expansion, onSave func()) {
+// ShowExpansionDialog shows a simplified, readable dialog. The new or edited
+// expansion is handed to do as an undoable command; onSave is called if do
+// reports that it was made.
+func ShowExpansionDialog(parent fyne.Window, existing *config.Expansion, do func(config.Command) bool, onSave func()) {
 	// Colors
 	labelColor := color.NRGBA{R: 99, G: 102, B: 241, A: 255}
 	hintColor := color.NRGBA{R: 107, G: 114, B: 128, A: 255}
@@ -23,6 +26,9 @@ func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *confi
 	triggerEntry := widget.NewEntry()
 	triggerEntry.SetPlaceHolder("e.g., ;myshortcut")
 
+	hotkeyEntry := widget.NewEntry()
+	hotkeyEntry.SetPlaceHolder("e.g., ctrl+alt+s (optional)")
+
 	descEntry := widget.NewEntry()
 	descEntry.SetPlaceHolder("e.g., My custom shortcut")
 
@@ -36,14 +42,49 @@ func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *confi
 
 	caseSensitiveCheck := widget.NewCheck("Case sensitive", nil)
 
+	formatOptions := []string{"Plain text", "Markdown", "HTML"}
+	formatValues := map[string]string{
+		"Plain text": "",
+		"Markdown":   config.OutputFormatMarkdown,
+		"HTML":       config.OutputFormatHTML,
+	}
+	formatSelect := widget.NewSelect(formatOptions, nil)
+	formatSelect.SetSelected("Plain text")
+
+	injectOptions := []string{"Automatic", "Type", "Paste"}
+	injectValues := map[string]string{
+		"Automatic": "",
+		"Type":      config.InjectModeType,
+		"Paste":     config.InjectModePaste,
+	}
+	injectSelect := widget.NewSelect(injectOptions, nil)
+	injectSelect.SetSelected("Automatic")
+
 	// Fill existing
 	isEdit := existing != nil
 	if isEdit {
 		triggerEntry.SetText(existing.Trigger)
+		hotkeyEntry.SetText(existing.Hotkey)
 		descEntry.SetText(existing.Description)
 		replacementEntry.SetText(existing.Replacement)
 		categorySelect.SetSelected(existing.Category)
 		caseSensitiveCheck.SetChecked(existing.CaseSensitive)
+		// Formats are matched case-insensitively; an explicit "text" is
+		// kept as written rather than saved as the empty default.
+		format := strings.ToLower(existing.OutputFormat)
+		if format == config.OutputFormatText {
+			formatValues["Plain text"] = config.OutputFormatText
+		}
+		for label, value := range formatValues {
+			if value == format {
+				formatSelect.SetSelected(label)
+			}
+		}
+		for label, value := range injectValues {
+			if value == existing.InjectMode {
+				injectSelect.SetSelected(label)
+			}
+		}
 	}
 
 	// Labels
@@ -54,6 +95,13 @@ func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *confi
 	triggerHint := canvas.NewText("Start with ; or your preferred prefix", hintColor)
 	triggerHint.TextSize = 12
 
+	hotkeyLabel := canvas.NewText("Hotkey (what you press)", labelColor)
+	hotkeyLabel.TextSize = 16
+	hotkeyLabel.TextStyle = fyne.TextStyle{Bold: true}
+
+	hotkeyHint := canvas.NewText("Inserts the replacement at the caret; leave the trigger empty to use only this", hintColor)
+	hotkeyHint.TextSize = 12
+
 	descLabel := canvas.NewText("Description (what it does)", labelColor)
 	descLabel.TextSize = 16
 	descLabel.TextStyle = fyne.TextStyle{Bold: true}
@@ -69,6 +117,20 @@ func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *confi
 	categoryLabel.TextSize = 16
 	categoryLabel.TextStyle = fyne.TextStyle{Bold: true}
 
+	formatLabel := canvas.NewText("Output format", labelColor)
+	formatLabel.TextSize = 16
+	formatLabel.TextStyle = fyne.TextStyle{Bold: true}
+
+	formatHint := canvas.NewText("Markdown and HTML are pasted as rich text", hintColor)
+	formatHint.TextSize = 12
+
+	injectLabel := canvas.NewText("Insert method", labelColor)
+	injectLabel.TextSize = 16
+	injectLabel.TextStyle = fyne.TextStyle{Bold: true}
+
+	injectHint := canvas.NewText("Automatic pastes replacements longer than the paste threshold", hintColor)
+	injectHint.TextSize = 12
+
 	optionsLabel := canvas.NewText("Options", labelColor)
 	optionsLabel.TextSize = 16
 	optionsLabel.TextStyle = fyne.TextStyle{Bold: true}
@@ -80,6 +142,11 @@ func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *confi
 		triggerHint,
 		widget.NewLabel(""),
 
+		hotkeyLabel,
+		hotkeyEntry,
+		hotkeyHint,
+		widget.NewLabel(""),
+
 		descLabel,
 		descEntry,
 		widget.NewLabel(""),
@@ -93,6 +160,16 @@ func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *confi
 		categorySelect,
 		widget.NewLabel(""),
 
+		formatLabel,
+		formatSelect,
+		formatHint,
+		widget.NewLabel(""),
+
+		injectLabel,
+		injectSelect,
+		injectHint,
+		widget.NewLabel(""),
+
 		optionsLabel,
 		caseSensitiveCheck,
 	)
@@ -107,10 +184,16 @@ func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *confi
 
 	// Validate
 	validate := func() bool {
-		if triggerEntry.Text == "" {
-			dialog.ShowError(fmt.Errorf("trigger cannot be empty"), parent)
+		if triggerEntry.Text == "" && hotkeyEntry.Text == "" {
+			dialog.ShowError(fmt.Errorf("enter a trigger, a hotkey or both"), parent)
 			return false
 		}
+		if hotkeyEntry.Text != "" {
+			if _, err := config.ParseHotkey(hotkeyEntry.Text); err != nil {
+				dialog.ShowError(err, parent)
+				return false
+			}
+		}
 		if replacementEntry.Text == "" {
 			dialog.ShowError(fmt.Errorf("replacement cannot be empty"), parent)
 			return false
@@ -126,23 +209,20 @@ func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *confi
 
 		expansion := config.Expansion{
 			Trigger:       triggerEntry.Text,
+			Hotkey:        strings.TrimSpace(hotkeyEntry.Text),
 			Replacement:   replacementEntry.Text,
 			Description:   descEntry.Text,
 			Category:      categorySelect.Selected,
 			CaseSensitive: caseSensitiveCheck.Checked,
+			OutputFormat:  formatValues[formatSelect.Selected],
+			InjectMode:    injectValues[injectSelect.Selected],
 		}
 
+		cmd := config.AddExpansionCommand(expansion)
 		if isEdit {
-			cfg.RemoveExpansion(existing.Trigger)
-		}
-
-		if err := cfg.AddExpansion(expansion); err != nil {
-			dialog.ShowError(err, parent)
-			return
+			cmd = config.EditExpansionCommand(*existing, expansion)
 		}
-
-		if err := cfg.Save(); err != nil {
-			dialog.ShowError(err, parent)
+		if !do(cmd) {
 			return
 		}
 
@@ -171,7 +251,7 @@ func ShowExpansionDialog(parent fyne.Window, cfg *config.Config, existing *confi
 func ShowDeleteConfirmation(parent fyne.Window, trigger string, onConfirm func()) {
 	dialog.ShowConfirm(
 		"Delete Expansion",
-		"Are you sure you want to delete '"+trigger+"'?\n\nThis action cannot be undone.",
+		"Are you sure you want to delete '"+trigger+"'?\n\nYou can undo this with Ctrl+Z.",
 		func(confirmed bool) {
 			if confirmed && onConfirm != nil {
 				onConfirm()
@@ -229,6 +309,8 @@ func ShowHelpDialog(parent fyne.Window) {
 	tip3.TextSize = 14
 	tip4 := canvas.NewText("✓ Back up your config regularly", textColor)
 	tip4.TextSize = 14
+	tip5 := canvas.NewText("✓ Ctrl+Z undoes a change, Ctrl+Y redoes it", textColor)
+	tip5.TextSize = 14
 
 	spacer := canvas.NewText("", textColor)
 	spacer.TextSize = 8
@@ -240,7 +322,7 @@ func ShowHelpDialog(parent fyne.Window) {
 		spacer,
 		varsTitle, var1, var2, var3, var4, var5,
 		spacer,
-		tipsTitle, tip1, tip2, tip3, tip4,
+		tipsTitle, tip1, tip2, tip3, tip4, tip5,
 	)
 
 	paddedContent := container.NewPadded(content)
@@ -1,13 +1,19 @@
 package gui
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
 	"text-expander/config"
@@ -22,6 +28,10 @@ type editorState struct {
 	customVarsContainer *fyne.Container
 	settingsContainer   *fyne.Container
 	filteredExpansions  []config.Expansion
+	// undo applies and saves every change made in the editor so it can be
+	// undone; undoBtn and redoBtn mirror it.
+	undo             *config.UndoStack
+	undoBtn, redoBtn *widget.Button
 }
 
 // CreateEditorWindow creates the editor UI in the given window
@@ -30,6 +40,7 @@ func CreateEditorWindow(w fyne.Window, cfg *config.Config) {
 		cfg:                cfg,
 		window:             w,
 		filteredExpansions: cfg.GetExpansions(),
+		undo:               config.NewUndoStack(cfg),
 	}
 
 	state.initUI()
@@ -69,12 +80,46 @@ func (s *editorState) initUI() {
 
 	// Create new expansion button
 	newBtn := widget.NewButton("+ New Expansion", func() {
-		ShowExpansionDialog(s.window, s.cfg, nil, func() {
-			s.refreshExpansionsView()
+		ShowExpansionDialog(s.window, nil, s.do, func() {
+			s.filterExpansions(s.searchEntry.Text)
 		})
 	})
 	newBtn.Importance = widget.HighImportance
 
+	// Import snippets from other text expanders, or export for them
+	importBtn := widget.NewButton("Import...", func() {
+		ShowImportDialog(s.window, s.cfg, func() {
+			s.undo.Clear()
+			s.updateUndoButtons()
+			s.filteredExpansions = s.cfg.GetExpansions()
+			s.refreshExpansionsView()
+		})
+	})
+	exportBtn := widget.NewButton("Export...", func() {
+		ShowExportDialog(s.window, s.cfg)
+	})
+
+	// Compare with and restore earlier versions of the configuration
+	historyBtn := widget.NewButton("History...", func() {
+		ShowHistoryDialog(s.window, s.cfg, func() {
+			s.undo.Clear()
+			s.updateUndoButtons()
+			s.refreshAll()
+		})
+	})
+
+	// Undo and redo, also on Ctrl+Z and Ctrl+Y (or Ctrl+Shift+Z)
+	s.undoBtn = widget.NewButtonWithIcon("", theme.ContentUndoIcon(), s.undoStep)
+	s.redoBtn = widget.NewButtonWithIcon("", theme.ContentRedoIcon(), s.redoStep)
+	s.updateUndoButtons()
+	c := s.window.Canvas()
+	c.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault},
+		func(fyne.Shortcut) { s.undoStep() })
+	c.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyY, Modifier: fyne.KeyModifierShortcutDefault},
+		func(fyne.Shortcut) { s.redoStep() })
+	c.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift},
+		func(fyne.Shortcut) { s.redoStep() })
+
 	// Help button
 	helpBtn := widget.NewButton("?", func() {
 		ShowHelpDialog(s.window)
@@ -85,7 +130,7 @@ func (s *editorState) initUI() {
 	toolbar := container.NewBorder(
 		nil, nil,
 		container.NewPadded(container.NewHBox(layout.NewSpacer())),
-		container.NewPadded(container.NewHBox(newBtn, helpBtn)),
+		container.NewPadded(container.NewHBox(s.undoBtn, s.redoBtn, importBtn, exportBtn, historyBtn, newBtn, helpBtn)),
 		container.NewPadded(s.searchEntry), // Search bar fills remaining space
 	)
 
@@ -152,13 +197,13 @@ func (s *editorState) refreshExpansionsView() {
 		card := NewExpansionCard(
 			exp,
 			func(e *config.Expansion) {
-				ShowExpansionDialog(s.window, s.cfg, e, func() {
-					s.refreshExpansionsView()
+				ShowExpansionDialog(s.window, e, s.do, func() {
+					s.filterExpansions(s.searchEntry.Text)
 				})
 			},
-			func(trigger string) {
-				ShowDeleteConfirmation(s.window, trigger, func() {
-					s.deleteExpansion(trigger)
+			func(e *config.Expansion) {
+				ShowDeleteConfirmation(s.window, e.Name(), func() {
+					s.deleteExpansion(e.ID)
 				})
 			},
 		)
@@ -192,6 +237,7 @@ func (s *editorState) filterExpansions(query string) {
 		// Text search
 		if query != "" {
 			match := strings.Contains(strings.ToLower(exp.Trigger), query) ||
+				strings.Contains(strings.ToLower(exp.Hotkey), query) ||
 				strings.Contains(strings.ToLower(exp.Description), query) ||
 				strings.Contains(strings.ToLower(exp.Replacement), query)
 
@@ -205,25 +251,91 @@ func (s *editorState) filterExpansions(query string) {
 
 	// Sort alphabetically by trigger
 	sort.Slice(s.filteredExpansions, func(i, j int) bool {
-		return s.filteredExpansions[i].Trigger < s.filteredExpansions[j].Trigger
+		return s.filteredExpansions[i].Name() < s.filteredExpansions[j].Name()
 	})
 
 	s.refreshExpansionsView()
 }
 
-func (s *editorState) deleteExpansion(trigger string) {
-	if err := s.cfg.RemoveExpansion(trigger); err != nil {
+func (s *editorState) deleteExpansion(id string) {
+	if !s.do(config.DeleteExpansionCommand(id)) {
+		return
+	}
+	s.filterExpansions(s.searchEntry.Text)
+}
+
+// do applies and saves cmd as an undoable step, reporting any error. It
+// returns whether the change was made.
+func (s *editorState) do(cmd config.Command) bool {
+	err := s.undo.Do(cmd)
+	s.updateUndoButtons()
+	if err != nil {
 		dialog.ShowError(err, s.window)
+		return errors.Is(err, config.ErrSnapshot)
+	}
+	return true
+}
+
+// changeSettings saves new settings as an undoable step. Showing the
+// current values fires some handlers too; they change nothing and make no
+// step.
+func (s *editorState) changeSettings(settings config.Settings) {
+	if settings == s.cfg.GetSettings() {
+		return
+	}
+	s.do(config.SettingsCommand(settings))
+}
+
+func (s *editorState) undoStep() {
+	if _, ok := s.undo.CanUndo(); !ok {
+		return
+	}
+	_, err := s.undo.Undo()
+	s.afterUndoRedo(err)
+}
+
+func (s *editorState) redoStep() {
+	if _, ok := s.undo.CanRedo(); !ok {
 		return
 	}
+	_, err := s.undo.Redo()
+	s.afterUndoRedo(err)
+}
 
-	if err := s.cfg.Save(); err != nil {
+func (s *editorState) afterUndoRedo(err error) {
+	if err != nil {
 		dialog.ShowError(err, s.window)
+	}
+	s.updateUndoButtons()
+	s.refreshAll()
+}
+
+// updateUndoButtons enables the undo and redo buttons when there is a step
+// to take and names it.
+func (s *editorState) updateUndoButtons() {
+	if s.undoBtn == nil {
 		return
 	}
+	for _, b := range []struct {
+		btn    *widget.Button
+		verb   string
+		status func() (string, bool)
+	}{{s.undoBtn, "Undo", s.undo.CanUndo}, {s.redoBtn, "Redo", s.undo.CanRedo}} {
+		if step, ok := b.status(); ok {
+			b.btn.SetText(b.verb + " " + step)
+			b.btn.Enable()
+		} else {
+			b.btn.SetText(b.verb)
+			b.btn.Disable()
+		}
+	}
+}
 
-	s.filteredExpansions = s.cfg.GetExpansions()
-	s.refreshExpansionsView()
+// refreshAll redraws every tab from the configuration.
+func (s *editorState) refreshAll() {
+	s.filterExpansions(s.searchEntry.Text)
+	s.refreshCustomVars()
+	s.refreshSettings()
 }
 
 func (s *editorState) refreshCustomVars() {
@@ -244,9 +356,9 @@ func (s *editorState) refreshCustomVars() {
 			layout.NewSpacer(),
 			widget.NewButton("Delete", func(k string) func() {
 				return func() {
-					s.cfg.DeleteCustomVar(k)
-					s.cfg.Save()
-					s.refreshCustomVars()
+					if s.do(config.DeleteVariableCommand(k)) {
+						s.refreshCustomVars()
+					}
 				}
 			}(key)),
 		)
@@ -272,9 +384,9 @@ func (s *editorState) showAddVariableDialog() {
 
 	dialog.NewCustomConfirm("Add Custom Variable", "Add", "Cancel", form, func(add bool) {
 		if add && keyEntry.Text != "" && valueEntry.Text != "" {
-			s.cfg.SetCustomVar(keyEntry.Text, valueEntry.Text)
-			s.cfg.Save()
-			s.refreshCustomVars()
+			if s.do(config.SetVariableCommand(keyEntry.Text, valueEntry.Text)) {
+				s.refreshCustomVars()
+			}
 		}
 	}, s.window).Show()
 }
@@ -287,43 +399,43 @@ func (s *editorState) refreshSettings() {
 	// Create settings controls
 	enabledCheck := widget.NewCheck("Enable expansions", func(checked bool) {
 		settings.Enabled = checked
-		s.cfg.UpdateSettings(settings)
-		s.cfg.Save()
+		s.changeSettings(settings)
 	})
 	enabledCheck.SetChecked(settings.Enabled)
 
 	spaceCheck := widget.NewCheck("Trigger on Space", func(checked bool) {
 		settings.TriggerOnSpace = checked
-		s.cfg.UpdateSettings(settings)
-		s.cfg.Save()
+		s.changeSettings(settings)
 	})
 	spaceCheck.SetChecked(settings.TriggerOnSpace)
 
 	tabCheck := widget.NewCheck("Trigger on Tab", func(checked bool) {
 		settings.TriggerOnTab = checked
-		s.cfg.UpdateSettings(settings)
-		s.cfg.Save()
+		s.changeSettings(settings)
 	})
 	tabCheck.SetChecked(settings.TriggerOnTab)
 
 	enterCheck := widget.NewCheck("Trigger on Enter", func(checked bool) {
 		settings.TriggerOnEnter = checked
-		s.cfg.UpdateSettings(settings)
-		s.cfg.Save()
+		s.changeSettings(settings)
 	})
 	enterCheck.SetChecked(settings.TriggerOnEnter)
 
+	keepTerminatorCheck := widget.NewCheck("Keep the space, tab or newline after a trigger", func(checked bool) {
+		settings.KeepTerminator = checked
+		s.changeSettings(settings)
+	})
+	keepTerminatorCheck.SetChecked(settings.KeepTerminator)
+
 	notificationsCheck := widget.NewCheck("Show notifications", func(checked bool) {
 		settings.ShowNotifications = checked
-		s.cfg.UpdateSettings(settings)
-		s.cfg.Save()
+		s.changeSettings(settings)
 	})
 	notificationsCheck.SetChecked(settings.ShowNotifications)
 
 	loggingCheck := widget.NewCheck("Log expansions", func(checked bool) {
 		settings.LogExpansions = checked
-		s.cfg.UpdateSettings(settings)
-		s.cfg.Save()
+		s.changeSettings(settings)
 	})
 	loggingCheck.SetChecked(settings.LogExpansions)
 
@@ -335,6 +447,245 @@ func (s *editorState) refreshSettings() {
 	s.settingsContainer.Add(spaceCheck)
 	s.settingsContainer.Add(tabCheck)
 	s.settingsContainer.Add(enterCheck)
+	s.settingsContainer.Add(keepTerminatorCheck)
+	s.settingsContainer.Add(widget.NewSeparator())
+
+	thresholdEntry := widget.NewEntry()
+	thresholdEntry.SetText(strconv.Itoa(settings.PasteThreshold))
+	thresholdEntry.OnChanged = func(text string) {
+		n, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || n < 0 {
+			return
+		}
+		settings.PasteThreshold = n
+		s.changeSettings(settings)
+	}
+
+	maxOutputEntry := widget.NewEntry()
+	maxOutputEntry.SetText(strconv.Itoa(settings.MaxOutputSize))
+	maxOutputEntry.OnChanged = func(text string) {
+		n, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || n < 0 {
+			return
+		}
+		settings.MaxOutputSize = n
+		s.changeSettings(settings)
+	}
+
+	confirmLargeCheck := widget.NewCheck("Ask before inserting larger replacements", func(checked bool) {
+		settings.ConfirmLargeOutput = checked
+		s.changeSettings(settings)
+	})
+	confirmLargeCheck.SetChecked(settings.ConfirmLargeOutput)
+
+	s.settingsContainer.Add(widget.NewLabelWithStyle("Insertion", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	s.settingsContainer.Add(widget.NewLabel("Paste replacements longer than (characters, 0 = never):"))
+	s.settingsContainer.Add(thresholdEntry)
+	s.settingsContainer.Add(widget.NewLabel("Maximum replacement size (characters, 0 = unlimited):"))
+	s.settingsContainer.Add(maxOutputEntry)
+	s.settingsContainer.Add(confirmLargeCheck)
+	s.settingsContainer.Add(widget.NewSeparator())
+
+	historyEntry := widget.NewEntry()
+	historyEntry.SetText(strconv.Itoa(settings.HistoryLimit))
+	historyEntry.OnChanged = func(text string) {
+		n, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || n < 0 {
+			return
+		}
+		settings.HistoryLimit = n
+		s.changeSettings(settings)
+	}
+
+	s.settingsContainer.Add(widget.NewLabelWithStyle("History", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	s.settingsContainer.Add(widget.NewLabel("Snapshots of earlier versions to keep (0 = none):"))
+	s.settingsContainer.Add(historyEntry)
+	s.settingsContainer.Add(widget.NewSeparator())
+
+	hotkeyError := widget.NewLabel("")
+	hotkeyError.Wrapping = fyne.TextWrapWord
+	hotkeyError.Hide()
+
+	// saveHotkeys stores the bindings only if they are all valid, so a
+	// half-typed combination never reaches the running expander.
+	saveHotkeys := func(h config.Hotkeys) {
+		if err := h.Validate(); err != nil {
+			hotkeyError.SetText(err.Error())
+			hotkeyError.Show()
+			return
+		}
+		hotkeyError.Hide()
+		settings.Hotkeys = h
+		s.changeSettings(settings)
+	}
+
+	pending := settings.Hotkeys
+	hotkeyEntry := func(value string, set func(string)) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder("e.g. ctrl+alt+e (empty = off)")
+		entry.SetText(value)
+		entry.OnChanged = func(text string) {
+			set(strings.TrimSpace(text))
+			saveHotkeys(pending)
+		}
+		return entry
+	}
+	toggleEntry := hotkeyEntry(pending.Toggle, func(v string) { pending.Toggle = v })
+	pauseEntry := hotkeyEntry(pending.Pause, func(v string) { pending.Pause = v })
+	managerEntry := hotkeyEntry(pending.OpenManager, func(v string) { pending.OpenManager = v })
+	reloadEntry := hotkeyEntry(pending.Reload, func(v string) { pending.Reload = v })
+	paletteHotkeyEntry := hotkeyEntry(pending.Palette, func(v string) { pending.Palette = v })
+	acceptEntry := hotkeyEntry(pending.AcceptSuggestion, func(v string) { pending.AcceptSuggestion = v })
+
+	pauseMinutesEntry := widget.NewEntry()
+	pauseMinutesEntry.SetText(strconv.Itoa(pending.PauseDuration()))
+	pauseMinutesEntry.OnChanged = func(text string) {
+		n, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || n <= 0 {
+			return
+		}
+		pending.PauseMinutes = n
+		saveHotkeys(pending)
+	}
+
+	s.settingsContainer.Add(widget.NewLabelWithStyle("Hotkeys", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	s.settingsContainer.Add(widget.NewForm(
+		widget.NewFormItem("Enable/disable", toggleEntry),
+		widget.NewFormItem("Pause", pauseEntry),
+		widget.NewFormItem("Pause minutes", pauseMinutesEntry),
+		widget.NewFormItem("Open manager", managerEntry),
+		widget.NewFormItem("Reload config", reloadEntry),
+		widget.NewFormItem("Snippet palette", paletteHotkeyEntry),
+		widget.NewFormItem("Accept suggestion", acceptEntry),
+	))
+	s.settingsContainer.Add(hotkeyError)
+	s.settingsContainer.Add(widget.NewSeparator())
+
+	suggestCheck := widget.NewCheck("Suggest triggers while typing", nil)
+	suggestCheck.SetChecked(settings.Suggestions.Enabled)
+	minPrefixEntry := widget.NewEntry()
+	minPrefixEntry.SetText(strconv.Itoa(settings.Suggestions.MinPrefix()))
+	prefixCharEntry := widget.NewEntry()
+	prefixCharEntry.SetText(settings.Suggestions.Prefix())
+	// Set the handlers last so showing the current values does not save.
+	suggestCheck.OnChanged = func(checked bool) {
+		settings.Suggestions.Enabled = checked
+		s.changeSettings(settings)
+	}
+	minPrefixEntry.OnChanged = func(text string) {
+		n, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || n <= 0 {
+			return
+		}
+		settings.Suggestions.MinPrefixLength = n
+		s.changeSettings(settings)
+	}
+	prefixCharEntry.OnChanged = func(text string) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		settings.Suggestions.PrefixChar = text
+		s.changeSettings(settings)
+	}
+
+	s.settingsContainer.Add(widget.NewLabelWithStyle("Suggestions", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	s.settingsContainer.Add(suggestCheck)
+	s.settingsContainer.Add(widget.NewForm(
+		widget.NewFormItem("Minimum typed length", minPrefixEntry),
+		widget.NewFormItem("Trigger prefix", prefixCharEntry),
+	))
+	s.settingsContainer.Add(widget.NewLabel("The suggestion popup is shown on Windows; elsewhere the accept hotkey still inserts the first match."))
+	s.settingsContainer.Add(widget.NewSeparator())
+
+	s.settingsContainer.Add(widget.NewLabelWithStyle("Snippet Packs", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	packConflicts := widget.NewLabel("")
+	packConflicts.Wrapping = fyne.TextWrapWord
+	showPackConflicts := func() {
+		var lines []string
+		for _, err := range s.cfg.PackConflicts() {
+			lines = append(lines, "⚠ "+err.Error())
+		}
+		packConflicts.SetText(strings.Join(lines, "\n"))
+		packConflicts.Hidden = len(lines) == 0
+		packConflicts.Refresh()
+	}
+	packs := s.cfg.Packs()
+	if len(packs) == 0 {
+		s.settingsContainer.Add(widget.NewLabel("No packs installed. Add pack files to " + s.cfg.PacksDir() + "."))
+	}
+	for _, p := range packs {
+		label := p.Name
+		if p.Version != "" {
+			label += " " + p.Version
+		}
+		label += fmt.Sprintf(" (%d snippets", len(p.Expansions))
+		if p.Namespace != "" {
+			label += ", triggers start with " + p.Namespace
+		}
+		label += ")"
+
+		file := p.File
+		check := widget.NewCheck(label, nil)
+		check.SetChecked(p.Enabled)
+		check.OnChanged = func(checked bool) {
+			if err := s.cfg.SetPackEnabled(file, checked); err != nil {
+				dialog.ShowError(err, s.window)
+				return
+			}
+			showPackConflicts()
+		}
+		s.settingsContainer.Add(check)
+	}
+	showPackConflicts()
+	s.settingsContainer.Add(packConflicts)
+	s.settingsContainer.Add(widget.NewSeparator())
+
+	// evdev only exists on Linux.
+	inputOptions := []string{"Keyboard hook"}
+	inputValues := map[string]string{
+		"Keyboard hook": "",
+	}
+	if runtime.GOOS == "linux" {
+		inputOptions = append(inputOptions, "evdev (Wayland)")
+		inputValues["evdev (Wayland)"] = "evdev"
+	}
+	inputSelect := widget.NewSelect(inputOptions, nil)
+	layoutOptions := []string{"US", "US International"}
+	layoutValues := map[string]string{
+		"US":               "",
+		"US International": "us-intl",
+	}
+	layoutSelect := widget.NewSelect(layoutOptions, nil)
+	inputSelect.SetSelected(inputOptions[0])
+	for label, value := range inputValues {
+		if value == settings.InputBackend {
+			inputSelect.SetSelected(label)
+		}
+	}
+	layoutSelect.SetSelected(layoutOptions[0])
+	for label, value := range layoutValues {
+		if value == settings.KeyboardLayout {
+			layoutSelect.SetSelected(label)
+		}
+	}
+	// Set the handlers last so showing the current values does not save.
+	inputSelect.OnChanged = func(selected string) {
+		settings.InputBackend = inputValues[selected]
+		s.changeSettings(settings)
+	}
+	layoutSelect.OnChanged = func(selected string) {
+		settings.KeyboardLayout = layoutValues[selected]
+		s.changeSettings(settings)
+	}
+
+	s.settingsContainer.Add(widget.NewLabelWithStyle("Input", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	inputForm := widget.NewForm(widget.NewFormItem("Read keys with", inputSelect))
+	if runtime.GOOS == "linux" {
+		inputForm.AppendItem(widget.NewFormItem("evdev layout", layoutSelect))
+	}
+	s.settingsContainer.Add(inputForm)
+	s.settingsContainer.Add(widget.NewLabel("Takes effect after restarting Text Expander."))
 	s.settingsContainer.Add(widget.NewSeparator())
 
 	s.settingsContainer.Add(widget.NewLabelWithStyle("Visual Feedback", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
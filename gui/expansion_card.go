@@ -18,14 +18,14 @@ type ExpansionCard struct {
 	widget.BaseWidget
 	expansion  *config.Expansion
 	onEdit     func(*config.Expansion)
-	onDelete   func(string)
+	onDelete   func(*config.Expansion)
 	background *canvas.Rectangle
 	shadow     *canvas.Rectangle
 	hovered    bool
 }
 
 // NewExpansionCard creates a new modern expansion card with shadows and hover effects
-func NewExpansionCard(exp *config.Expansion, onEdit func(*config.Expansion), onDelete func(string)) *ExpansionCard {
+func NewExpansionCard(exp *config.Expansion, onEdit func(*config.Expansion), onDelete func(*config.Expansion)) *ExpansionCard {
 	card := &ExpansionCard{
 		expansion: exp,
 		onEdit:    onEdit,
@@ -46,7 +46,14 @@ func (c *ExpansionCard) CreateRenderer() fyne.WidgetRenderer {
 	c.background.CornerRadius = 8
 
 	// Trigger text (large, bold)
-	triggerText := canvas.NewText(c.expansion.Trigger, ColorTextPrimary)
+	title := c.expansion.Trigger
+	switch {
+	case title == "":
+		title = c.expansion.Hotkey
+	case c.expansion.Hotkey != "":
+		title += "  (" + c.expansion.Hotkey + ")"
+	}
+	triggerText := canvas.NewText(title, ColorTextPrimary)
 	triggerText.TextSize = 20 // Larger for emphasis
 	triggerText.TextStyle = fyne.TextStyle{Bold: true}
 
@@ -100,7 +107,7 @@ func (c *ExpansionCard) CreateRenderer() fyne.WidgetRenderer {
 	// Delete button (danger style with emoji)
 	deleteBtn := widget.NewButton("Delete", func() {
 		if c.onDelete != nil {
-			c.onDelete(c.expansion.Trigger)
+			c.onDelete(c.expansion)
 		}
 	})
 	deleteBtn.Importance = widget.DangerImportance
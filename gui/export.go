@@ -0,0 +1,73 @@
+package gui
+
+import (
+	"sort"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"text-expander/config"
+	"text-expander/interop"
+)
+
+// ShowExportDialog lets the user export some or all categories of
+// expansions for another tool, then shows what could not be translated.
+func ShowExportDialog(parent fyne.Window, cfg *config.Config) {
+	var options []string
+	byDescription := make(map[string]interop.Exporter)
+	for _, e := range interop.Exporters {
+		options = append(options, e.Description)
+		byDescription[e.Description] = e
+	}
+	formatSelect := widget.NewSelect(options, nil)
+	formatSelect.SetSelected(options[0])
+
+	seen := make(map[string]bool)
+	var categories []string
+	for _, exp := range cfg.GetExpansions() {
+		if exp.Category != "" && !seen[exp.Category] {
+			seen[exp.Category] = true
+			categories = append(categories, exp.Category)
+		}
+	}
+	sort.Strings(categories)
+	categoryChecks := widget.NewCheckGroup(categories, nil)
+
+	form := container.NewVBox(
+		widget.NewLabel("Format:"),
+		formatSelect,
+		widget.NewLabel("Categories (none checked exports everything):"),
+		container.NewVScroll(categoryChecks),
+	)
+	d := dialog.NewCustomConfirm("Export Expansions", "Export...", "Cancel", form, func(ok bool) {
+		if !ok {
+			return
+		}
+		e := byDescription[formatSelect.Selected]
+		save := dialog.NewFileSave(func(wc fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, parent)
+				return
+			}
+			if wc == nil {
+				return // cancelled
+			}
+			path := wc.URI().Path()
+			wc.Close()
+
+			var report interop.Report
+			if err := interop.ExportFile(cfg, path, e.Name, categoryChecks.Selected, &report); err != nil {
+				dialog.ShowError(err, parent)
+				return
+			}
+			showInteropReport(parent, "Export Report", &report)
+		}, parent)
+		save.SetFileName("expansions" + e.Extension)
+		save.Resize(fyne.NewSize(800, 600))
+		save.Show()
+	}, parent)
+	d.Resize(fyne.NewSize(500, 450))
+	d.Show()
+}
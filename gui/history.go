@@ -0,0 +1,193 @@
+package gui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+
+	"text-expander/config"
+)
+
+// ShowHistoryDialog lists the snapshots of cfg's file and shows how the
+// selected one differs from the current configuration, with buttons to
+// restore the whole snapshot or single expansions from it. onRestore is
+// called after anything is restored.
+func ShowHistoryDialog(parent fyne.Window, cfg *config.Config, onRestore func()) {
+	snaps, err := cfg.Snapshots()
+	if err != nil {
+		dialog.ShowError(err, parent)
+		return
+	}
+	if len(snaps) == 0 {
+		dialog.ShowInformation("History",
+			"No snapshots yet. A snapshot of the configuration is kept each time it is saved over, in "+cfg.HistoryDir()+".",
+			parent)
+		return
+	}
+
+	details := container.NewVBox(widget.NewLabel("Select a snapshot to compare it with the current configuration."))
+	var (
+		list       *widget.List
+		selected   config.Snapshot
+		selectedID widget.ListItemID
+		showDiff   func()
+	)
+	// restored refreshes everything after a restore, which saves and so
+	// may have added a snapshot.
+	restored := func() {
+		if onRestore != nil {
+			onRestore()
+		}
+		if latest, err := cfg.Snapshots(); err == nil {
+			snaps = latest
+			list.Refresh()
+			for i, s := range snaps {
+				if s.Path == selected.Path && i != selectedID {
+					list.Select(i) // shows the diff
+					return
+				}
+			}
+		}
+		showDiff()
+	}
+	showDiff = func() {
+		details.Objects = nil
+		defer details.Refresh()
+
+		old, err := selected.Load()
+		if err != nil {
+			details.Add(widget.NewLabel("This snapshot cannot be read: " + err.Error()))
+			return
+		}
+		diff := config.DiffConfigs(old, cfg)
+
+		restoreAll := widget.NewButton("Restore This Snapshot", func() {
+			dialog.ShowConfirm("Restore Snapshot",
+				"Replace the current configuration with the one from "+formatSnapshotTime(selected)+"? The current configuration is kept as a snapshot.",
+				func(ok bool) {
+					if !ok {
+						return
+					}
+					if err := cfg.RestoreSnapshot(selected); err != nil {
+						dialog.ShowError(err, parent)
+						return
+					}
+					restored()
+				}, parent)
+		})
+		restoreAll.Importance = widget.HighImportance
+		if diff.Empty() {
+			restoreAll.Disable()
+		}
+		details.Add(container.NewHBox(
+			widget.NewLabelWithStyle(formatSnapshotTime(selected), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			layout.NewSpacer(),
+			restoreAll,
+		))
+
+		if diff.Empty() {
+			details.Add(widget.NewLabel("Same as the current configuration."))
+			return
+		}
+		for _, c := range diff.Expansions {
+			details.Add(expansionChangeCard(c, func() {
+				if err := cfg.RestoreExpansion(c); err != nil {
+					dialog.ShowError(err, parent)
+					return
+				}
+				if err := cfg.Save(); err != nil {
+					dialog.ShowError(err, parent)
+					return
+				}
+				restored()
+			}))
+		}
+		for _, v := range diff.Variables {
+			var text string
+			switch v.Kind {
+			case config.Added:
+				text = fmt.Sprintf("Variable {%s} added: %q", v.Name, v.Current)
+			case config.Removed:
+				text = fmt.Sprintf("Variable {%s} removed, was %q", v.Name, v.Snapshot)
+			default:
+				text = fmt.Sprintf("Variable {%s} changed from %q to %q", v.Name, v.Snapshot, v.Current)
+			}
+			details.Add(widget.NewLabel(text))
+		}
+		if diff.Settings {
+			details.Add(widget.NewLabel("Settings differ."))
+		}
+		if diff.Profiles {
+			details.Add(widget.NewLabel("Injection profiles differ."))
+		}
+	}
+
+	list = widget.NewList(
+		func() int { return len(snaps) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(formatSnapshotTime(snaps[i]))
+		},
+	)
+	list.OnSelected = func(i widget.ListItemID) {
+		selected, selectedID = snaps[i], i
+		showDiff()
+	}
+
+	split := container.NewHSplit(list, container.NewVScroll(details))
+	split.SetOffset(0.3)
+
+	d := dialog.NewCustom("History", "Close", split, parent)
+	d.Resize(fyne.NewSize(900, 600))
+	d.Show()
+	list.Select(0)
+}
+
+// expansionChangeCard shows one changed expansion, with its snapshot and
+// current replacements, and a button that calls restore.
+func expansionChangeCard(c config.ExpansionChange, restore func()) fyne.CanvasObject {
+	replacement := func(label string, exp config.Expansion) fyne.CanvasObject {
+		text := widget.NewLabel(exp.Replacement)
+		text.TextStyle = fyne.TextStyle{Monospace: true}
+		text.Wrapping = fyne.TextWrapWord
+		return container.NewVBox(widget.NewLabelWithStyle(label, fyne.TextAlignLeading, fyne.TextStyle{Italic: true}), text)
+	}
+
+	var body fyne.CanvasObject
+	button := "Restore"
+	kind := "(" + c.Kind.String() + ")"
+	switch c.Kind {
+	case config.Added:
+		body = replacement("Not in the snapshot; current:", c.Current)
+		button = "Remove"
+	case config.Removed:
+		body = replacement("Deleted since; in the snapshot:", c.Snapshot)
+	default:
+		body = container.NewGridWithColumns(2,
+			replacement("Snapshot:", c.Snapshot),
+			replacement("Current:", c.Current),
+		)
+		if c.Snapshot.Name() != c.Current.Name() {
+			kind = "(renamed from " + c.Snapshot.Name() + ")"
+		}
+	}
+
+	return widget.NewCard("", "", container.NewVBox(
+		container.NewHBox(
+			widget.NewLabelWithStyle(c.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabel(kind),
+			layout.NewSpacer(),
+			widget.NewButton(button, restore),
+		),
+		body,
+	))
+}
+
+// formatSnapshotTime labels a snapshot in the history list.
+func formatSnapshotTime(s config.Snapshot) string {
+	return s.Time.Format("2006-01-02 15:04:05")
+}
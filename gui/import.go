@@ -0,0 +1,94 @@
+package gui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"text-expander/config"
+	"text-expander/interop"
+)
+
+// formatAuto is the import format choice that detects the format from the
+// file.
+const formatAuto = "Detect from file"
+
+// ShowImportDialog lets the user pick a file exported by another text
+// expander, adds its snippets to cfg and shows what could not be translated.
+func ShowImportDialog(parent fyne.Window, cfg *config.Config, onImport func()) {
+	open := dialog.NewFileOpen(func(rc fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, parent)
+			return
+		}
+		if rc == nil {
+			return // cancelled
+		}
+		path := rc.URI().Path()
+		rc.Close()
+		showImportOptions(parent, cfg, path, onImport)
+	}, parent)
+	open.Resize(fyne.NewSize(800, 600))
+	open.Show()
+}
+
+// showImportOptions asks for the format and category, then imports path.
+func showImportOptions(parent fyne.Window, cfg *config.Config, path string, onImport func()) {
+	options := []string{formatAuto}
+	names := map[string]string{formatAuto: ""}
+	for _, f := range interop.Formats {
+		options = append(options, f.Description)
+		names[f.Description] = f.Name
+	}
+	formatSelect := widget.NewSelect(options, nil)
+	formatSelect.SetSelected(formatAuto)
+
+	categoryEntry := widget.NewEntry()
+	categoryEntry.SetPlaceHolder("Category for snippets that have none (optional)")
+
+	form := container.NewVBox(
+		widget.NewLabel("File: "+path),
+		widget.NewLabel("Format:"),
+		formatSelect,
+		widget.NewLabel("Category:"),
+		categoryEntry,
+	)
+	dialog.NewCustomConfirm("Import Snippets", "Import", "Cancel", form, func(ok bool) {
+		if !ok {
+			return
+		}
+		var report interop.Report
+		exps, err := interop.ImportFile(path, names[formatSelect.Selected], &report)
+		if err != nil {
+			dialog.ShowError(err, parent)
+			return
+		}
+		for i := range exps {
+			if exps[i].Category == "" {
+				exps[i].Category = categoryEntry.Text
+			}
+		}
+		if interop.AddToConfig(cfg, exps, &report) > 0 {
+			if err := cfg.Save(); err != nil {
+				dialog.ShowError(err, parent)
+				return
+			}
+			if onImport != nil {
+				onImport()
+			}
+		}
+		showInteropReport(parent, "Import Report", &report)
+	}, parent).Show()
+}
+
+// showInteropReport shows an import or export report.
+func showInteropReport(parent fyne.Window, title string, report *interop.Report) {
+	text := widget.NewLabel(report.String())
+	text.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(text)
+	scroll.SetMinSize(fyne.NewSize(600, 400))
+
+	d := dialog.NewCustom(title, "Close", scroll, parent)
+	d.Show()
+}
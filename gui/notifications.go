@@ -42,6 +42,23 @@ func ShowExpansionNotification(trigger, replacement string) {
 	ShowNotification("Text Expanded", message)
 }
 
+// ShowExpansionAbortedNotification shows a notification when an expansion
+// was abandoned before it finished
+func ShowExpansionAbortedNotification(trigger string, err error) {
+	ShowNotification("Expansion Cancelled", fmt.Sprintf("%s: %v", trigger, err))
+}
+
+// ShowConfigErrorNotification shows a notification when the configuration
+// file could not be loaded; loaded says whether an earlier version of it is
+// still in use
+func ShowConfigErrorNotification(err error, loaded bool) {
+	if !loaded {
+		ShowNotification("Configuration Error", fmt.Sprintf("%v\nExpansions are off until the file is fixed.", err))
+		return
+	}
+	ShowNotification("Configuration Error", fmt.Sprintf("%v\nThe last working configuration is still in use.", err))
+}
+
 // escapeForPowerShell escapes special characters for PowerShell
 func escapeForPowerShell(s string) string {
 	s = strings.ReplaceAll(s, `"`, `'`)
@@ -0,0 +1,119 @@
+package gui
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// SuggestionItem is one suggestion listed by the overlay.
+type SuggestionItem struct {
+	Trigger     string `json:"trigger"`
+	Description string `json:"description,omitempty"`
+}
+
+// OverlayUpdate is what the tray application sends the suggestion overlay,
+// one JSON object per line. An update without items hides the overlay.
+type OverlayUpdate struct {
+	Items []SuggestionItem `json:"items"`
+	// X and Y are the screen position of the overlay's top-left corner,
+	// usually just below the caret.
+	X int `json:"x"`
+	Y int `json:"y"`
+	// Accept is the hotkey that inserts the first item.
+	Accept string `json:"accept,omitempty"`
+}
+
+// ErrOverlayUnsupported is returned by RunOverlay on platforms where a
+// window cannot be shown without taking the keyboard focus, which would
+// swallow the very typing it is meant to assist.
+var ErrOverlayUnsupported = errors.New("suggestion overlay is not supported on this platform")
+
+// RunOverlay shows the updates read from r in a borderless window that never
+// takes the focus, until r is closed.
+func RunOverlay(a fyne.App, r io.Reader) error {
+	if !OverlaySupported() {
+		return ErrOverlayUnsupported
+	}
+	drv, ok := a.Driver().(desktop.Driver)
+	if !ok {
+		return ErrOverlayUnsupported
+	}
+
+	w := drv.CreateSplashWindow()
+	rows := container.NewVBox()
+	hint := canvas.NewText("", ColorTextSecondary)
+	hint.TextSize = 12
+	w.SetContent(container.NewPadded(container.NewVBox(rows, widget.NewSeparator(), hint)))
+
+	update := func(u OverlayUpdate) {
+		if len(u.Items) == 0 {
+			hideOverlay(w)
+			return
+		}
+		rows.RemoveAll()
+		for i, item := range u.Items {
+			name := canvas.NewText(item.Trigger, ColorTextPrimary)
+			name.TextStyle = fyne.TextStyle{Bold: i == 0, Monospace: true}
+			desc := canvas.NewText(item.Description, ColorTextSecondary)
+			rows.Add(container.NewHBox(name, desc))
+		}
+		hint.Text = "Keep typing to expand"
+		if u.Accept != "" {
+			hint.Text = "Keep typing, or press " + u.Accept + " for " + u.Items[0].Trigger
+		}
+		hint.Refresh()
+		w.Resize(w.Content().MinSize())
+		placeOverlay(w, u.X, u.Y)
+	}
+
+	// The native window exists once the app runs; turn it into an overlay
+	// that cannot be activated before reading any updates, and hand the
+	// focus back to the application that had it when the window appeared.
+	focus := foregroundWindow()
+	a.Lifecycle().SetOnStarted(func() {
+		go func() {
+			for {
+				ready := make(chan bool)
+				fyne.Do(func() { ready <- prepareOverlay(w) })
+				if <-ready {
+					break
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+			restoreForeground(focus)
+			readOverlayUpdates(r, func(u OverlayUpdate) { fyne.Do(func() { update(u) }) })
+			fyne.Do(a.Quit)
+		}()
+	})
+
+	w.ShowAndRun()
+	return nil
+}
+
+// readOverlayUpdates calls fn for each update in r until it is closed.
+// Malformed lines are logged and skipped.
+func readOverlayUpdates(r io.Reader, fn func(OverlayUpdate)) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var u OverlayUpdate
+		if err := json.Unmarshal(scanner.Bytes(), &u); err != nil {
+			log.Printf("overlay: ignoring malformed update: %v", err)
+			continue
+		}
+		fn(u)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("overlay: reading updates: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package gui
+
+import "fyne.io/fyne/v2"
+
+// OverlaySupported reports whether RunOverlay can show suggestions here. GLFW
+// activates every window it shows, so outside Windows it cannot.
+func OverlaySupported() bool { return false }
+
+// CaretPosition is not available on this platform.
+func CaretPosition() (x, y int, ok bool) { return 0, 0, false }
+
+func foregroundWindow() uintptr { return 0 }
+
+func restoreForeground(hwnd uintptr) {}
+
+func prepareOverlay(w fyne.Window) bool { return true }
+
+func placeOverlay(w fyne.Window, x, y int) {}
+
+func hideOverlay(w fyne.Window) {}
@@ -0,0 +1,133 @@
+package gui
+
+import (
+	"syscall"
+	"unsafe"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver"
+)
+
+var (
+	user32                       = syscall.NewLazyDLL("user32.dll")
+	procGetWindowLongPtrW        = user32.NewProc("GetWindowLongPtrW")
+	procSetWindowLongPtrW        = user32.NewProc("SetWindowLongPtrW")
+	procSetWindowPos             = user32.NewProc("SetWindowPos")
+	procShowWindow               = user32.NewProc("ShowWindow")
+	procGetForegroundWindow      = user32.NewProc("GetForegroundWindow")
+	procSetForegroundWindow      = user32.NewProc("SetForegroundWindow")
+	procGetWindowThreadProcessID = user32.NewProc("GetWindowThreadProcessId")
+	procGetGUIThreadInfo         = user32.NewProc("GetGUIThreadInfo")
+	procClientToScreen           = user32.NewProc("ClientToScreen")
+)
+
+const (
+	gwlExStyle       = ^uintptr(19) // GWL_EXSTYLE (-20)
+	wsExTopmost      = 0x00000008
+	wsExToolWindow   = 0x00000080
+	wsExNoActivate   = 0x08000000
+	hwndTopmost      = ^uintptr(0) // HWND_TOPMOST (-1)
+	swpNoSize        = 0x0001
+	swpNoActivate    = 0x0010
+	swpShowWindow    = 0x0040
+	swHide           = 0
+	caretBelowOffset = 4
+)
+
+type winRect struct{ left, top, right, bottom int32 }
+
+type winPoint struct{ x, y int32 }
+
+// guiThreadInfo mirrors GUITHREADINFO.
+type guiThreadInfo struct {
+	cbSize        uint32
+	flags         uint32
+	hwndActive    uintptr
+	hwndFocus     uintptr
+	hwndCapture   uintptr
+	hwndMenuOwner uintptr
+	hwndMoveSize  uintptr
+	hwndCaret     uintptr
+	rcCaret       winRect
+}
+
+// OverlaySupported reports whether RunOverlay can show suggestions here.
+func OverlaySupported() bool { return true }
+
+// CaretPosition returns the screen position just below the text caret of the
+// focused window. Applications that draw their own caret, such as most
+// browsers, do not report it.
+func CaretPosition() (x, y int, ok bool) {
+	fg, _, _ := procGetForegroundWindow.Call()
+	if fg == 0 {
+		return 0, 0, false
+	}
+	tid, _, _ := procGetWindowThreadProcessID.Call(fg, 0)
+
+	info := guiThreadInfo{}
+	info.cbSize = uint32(unsafe.Sizeof(info))
+	if r, _, _ := procGetGUIThreadInfo.Call(tid, uintptr(unsafe.Pointer(&info))); r == 0 || info.hwndCaret == 0 {
+		return 0, 0, false
+	}
+
+	pt := winPoint{info.rcCaret.left, info.rcCaret.bottom}
+	if r, _, _ := procClientToScreen.Call(info.hwndCaret, uintptr(unsafe.Pointer(&pt))); r == 0 {
+		return 0, 0, false
+	}
+	return int(pt.x), int(pt.y) + caretBelowOffset, true
+}
+
+// foregroundWindow returns the window that has the focus, or 0.
+func foregroundWindow() uintptr {
+	fg, _, _ := procGetForegroundWindow.Call()
+	return fg
+}
+
+// restoreForeground gives the focus back to hwnd, as returned by
+// foregroundWindow.
+func restoreForeground(hwnd uintptr) {
+	if hwnd != 0 {
+		procSetForegroundWindow.Call(hwnd)
+	}
+}
+
+// overlayHandle returns the native handle of w, or 0 before it is created.
+func overlayHandle(w fyne.Window) uintptr {
+	var hwnd uintptr
+	if nw, ok := w.(driver.NativeWindow); ok {
+		nw.RunNative(func(ctx any) {
+			if wc, ok := ctx.(driver.WindowsWindowContext); ok {
+				hwnd = wc.HWND
+			}
+		})
+	}
+	return hwnd
+}
+
+// prepareOverlay makes w a topmost tool window that is left out of the
+// taskbar and never activated, then hides it. It reports false while the
+// native window does not exist yet.
+func prepareOverlay(w fyne.Window) bool {
+	hwnd := overlayHandle(w)
+	if hwnd == 0 {
+		return false
+	}
+	style, _, _ := procGetWindowLongPtrW.Call(hwnd, gwlExStyle)
+	procSetWindowLongPtrW.Call(hwnd, gwlExStyle, style|wsExTopmost|wsExToolWindow|wsExNoActivate)
+	procShowWindow.Call(hwnd, swHide)
+	return true
+}
+
+// placeOverlay shows w at x, y without activating it.
+func placeOverlay(w fyne.Window, x, y int) {
+	if hwnd := overlayHandle(w); hwnd != 0 {
+		procSetWindowPos.Call(hwnd, hwndTopmost, uintptr(x), uintptr(y), 0, 0, swpNoSize|swpNoActivate|swpShowWindow)
+	}
+}
+
+// hideOverlay hides w.
+func hideOverlay(w fyne.Window) {
+	if hwnd := overlayHandle(w); hwnd != 0 {
+		procShowWindow.Call(hwnd, swHide)
+	}
+}
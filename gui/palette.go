@@ -0,0 +1,155 @@
+package gui
+
+import (
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"text-expander/config"
+	"text-expander/expander"
+	"text-expander/utils"
+)
+
+// paletteLimit caps the number of results listed.
+const paletteLimit = 50
+
+// paletteEntry is the search field of the palette. It passes the arrow keys
+// and Escape to the palette instead of moving the text cursor.
+type paletteEntry struct {
+	widget.Entry
+	onUp, onDown, onEscape func()
+}
+
+func newPaletteEntry() *paletteEntry {
+	e := &paletteEntry{}
+	e.ExtendBaseWidget(e)
+	return e
+}
+
+// TypedKey handles navigation keys and leaves the rest to the entry.
+func (e *paletteEntry) TypedKey(ev *fyne.KeyEvent) {
+	switch ev.Name {
+	case fyne.KeyUp:
+		e.onUp()
+	case fyne.KeyDown:
+		e.onDown()
+	case fyne.KeyEscape:
+		e.onEscape()
+	default:
+		e.Entry.TypedKey(ev)
+	}
+}
+
+// ShowPalette fills w with the quick-search palette: a search field over
+// trigger, description, category and replacement, with results ranked by
+// usage. Enter calls onChoose with the selected expansion; Escape calls
+// onCancel.
+func ShowPalette(w fyne.Window, cfg *config.Config, usage map[string]utils.Usage, onChoose func(config.Expansion), onCancel func()) {
+	exps := cfg.AllExpansions()
+	var results []config.Expansion
+	selected := 0
+
+	preview := widget.NewLabel("")
+	preview.Wrapping = fyne.TextWrapWord
+	preview.TextStyle = fyne.TextStyle{Monospace: true}
+
+	list := widget.NewList(
+		func() int { return len(results) },
+		func() fyne.CanvasObject {
+			name := canvas.NewText("", ColorTextPrimary)
+			name.TextStyle = fyne.TextStyle{Bold: true}
+			desc := canvas.NewText("", ColorTextSecondary)
+			return container.NewHBox(name, desc)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			row := obj.(*fyne.Container)
+			exp := results[id]
+			name := row.Objects[0].(*canvas.Text)
+			name.Text = exp.Name()
+			name.Refresh()
+			desc := row.Objects[1].(*canvas.Text)
+			desc.Text = exp.Description
+			if exp.Category != "" {
+				desc.Text += "  [" + exp.Category + "]"
+			}
+			desc.Refresh()
+		},
+	)
+
+	entry := newPaletteEntry()
+	entry.SetPlaceHolder("Search snippets...")
+
+	show := func(i int) {
+		if len(results) == 0 {
+			selected = 0
+			preview.SetText("")
+			list.UnselectAll()
+			return
+		}
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(results) {
+			i = len(results) - 1
+		}
+		selected = i
+		list.Select(i)
+		list.ScrollTo(i)
+		text := []rune(results[i].Replacement)
+		if len(text) > 400 {
+			text = append(text[:400], []rune("...")...)
+		}
+		preview.SetText(string(text))
+	}
+
+	search := func(query string) {
+		results = expander.SearchExpansions(exps, query, usage, time.Now())
+		if len(results) > paletteLimit {
+			results = results[:paletteLimit]
+		}
+		list.Refresh()
+		show(0)
+	}
+
+	choose := func() {
+		if selected < len(results) {
+			onChoose(results[selected])
+		}
+	}
+
+	list.OnSelected = func(id widget.ListItemID) {
+		if id != selected {
+			show(id)
+		}
+		w.Canvas().Focus(entry)
+	}
+	entry.OnChanged = search
+	entry.OnSubmitted = func(string) { choose() }
+	entry.onUp = func() { show(selected - 1) }
+	entry.onDown = func() { show(selected + 1) }
+	entry.onEscape = onCancel
+
+	hint := widget.NewLabel("Enter inserts  ·  ↑↓ select  ·  Esc closes")
+	hint.TextStyle = fyne.TextStyle{Italic: true}
+
+	previewScroll := container.NewVScroll(preview)
+	previewScroll.SetMinSize(fyne.NewSize(0, 90))
+
+	w.SetContent(container.NewBorder(
+		entry,
+		container.NewVBox(widget.NewSeparator(), previewScroll, hint),
+		nil, nil,
+		list,
+	))
+	w.Canvas().SetOnTypedKey(func(ev *fyne.KeyEvent) {
+		if ev.Name == fyne.KeyEscape {
+			onCancel()
+		}
+	})
+
+	search("")
+	w.Canvas().Focus(entry)
+}
@@ -0,0 +1,388 @@
+package interop
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"text-expander/config"
+)
+
+// ahkOptions are the hotstring options the importer understands.
+type ahkOptions struct {
+	immediate     bool // *
+	caseSensitive bool // C
+	omitEnd       bool // O
+	noBackspace   bool // B0
+	raw           bool // R or T
+	execute       bool // X
+}
+
+// parse applies the option string between a hotstring's first two colons,
+// or after #Hotstring.
+func (o *ahkOptions) parse(s string) {
+	s = strings.ToUpper(s)
+	for i := 0; i < len(s); i++ {
+		on := i+1 >= len(s) || s[i+1] != '0'
+		switch s[i] {
+		case '*':
+			o.immediate = on
+		case 'C':
+			// C1 means case-insensitive without case conforming.
+			o.caseSensitive = on && (i+1 >= len(s) || s[i+1] != '1')
+		case 'O':
+			o.omitEnd = on
+		case 'B':
+			o.noBackspace = !on
+		case 'R', 'T':
+			o.raw = on
+		case 'X':
+			o.execute = on
+		}
+	}
+}
+
+// ahkOptionChars are the characters hotstring options are written with.
+const ahkOptionChars = "*?BCEIKOPRSTXZ0123456789- \t"
+
+// hotstringDirective applies the argument of a #Hotstring directive. Only a
+// string of options changes the defaults; EndChars, NoMouse and Reset are
+// recognised first, as their letters would otherwise be read as options.
+func hotstringDirective(defaults *ahkOptions, arg string, r *Report) {
+	word, _, _ := strings.Cut(arg, " ")
+	switch {
+	case strings.EqualFold(word, "EndChars"):
+		r.Change("#Hotstring EndChars", "expansions end with Space, Tab or Enter, as chosen in the settings")
+	case strings.EqualFold(word, "NoMouse"), strings.EqualFold(word, "Reset"):
+		// Only change how AutoHotkey watches for hotstrings.
+	case strings.Trim(strings.ToUpper(arg), ahkOptionChars) == "":
+		defaults.parse(arg)
+	default:
+		r.Skip("#Hotstring "+arg, "is not a hotstring option this importer knows")
+	}
+}
+
+// importAHK converts the hotstrings in an AutoHotkey v1 or v2 script,
+// including continuation sections. Hotstrings that run code are skipped,
+// and Send keys other than Enter, Tab and Space are dropped.
+func importAHK(name string, data []byte, r *Report) ([]config.Expansion, error) {
+	var (
+		exps     []config.Expansion
+		defaults ahkOptions
+		lines    []string
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	inComment := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case inComment:
+			inComment = !strings.HasPrefix(line, "*/")
+			continue
+		case strings.HasPrefix(line, "/*"):
+			inComment = true
+			continue
+		case strings.HasPrefix(strings.ToLower(line), "#hotstring"):
+			hotstringDirective(&defaults, strings.TrimSpace(line[len("#hotstring"):]), r)
+			continue
+		}
+
+		opts, trigger, text, ok := splitHotstring(line)
+		if !ok {
+			continue
+		}
+		o := defaults
+		o.parse(opts)
+
+		if text == "" || o.execute {
+			// The replacement is a continuation section or code.
+			if i+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i+1]), "(") && !o.execute {
+				var section []string
+				for i += 2; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), ")"); i++ {
+					section = append(section, lines[i])
+				}
+				text = strings.Join(section, "\n")
+			} else {
+				r.Skip(trigger, "runs code rather than replacing text")
+				continue
+			}
+		} else if j := strings.Index(text, " ;"); j >= 0 {
+			text = strings.TrimRight(text[:j], " \t")
+		}
+
+		exp := config.Expansion{
+			Trigger:       trigger,
+			CaseSensitive: o.caseSensitive,
+			Description:   "Imported from " + name,
+		}
+		text = unescapeAHK(text)
+		if !o.raw {
+			text = translateAHKSend(text, trigger, r)
+		}
+		exp.Replacement = escapeBraces(text)
+
+		if o.immediate {
+			r.Change(trigger, reasonImmediate)
+		}
+		if o.omitEnd {
+			r.Change(trigger, "the ending character is typed after the replacement")
+		}
+		if o.noBackspace {
+			r.Change(trigger, "the abbreviation is erased before the replacement is typed")
+		}
+		exps = append(exps, exp)
+	}
+	return exps, nil
+}
+
+// splitHotstring splits a ":options:trigger::replacement" line.
+func splitHotstring(line string) (opts, trigger, text string, ok bool) {
+	if !strings.HasPrefix(line, ":") {
+		return "", "", "", false
+	}
+	end := strings.Index(line[1:], ":")
+	if end < 0 {
+		return "", "", "", false
+	}
+	opts, rest := line[1:1+end], line[2+end:]
+	sep := strings.Index(rest, "::")
+	if sep <= 0 {
+		return "", "", "", false
+	}
+	return opts, rest[:sep], rest[sep+2:], true
+}
+
+// unescapeAHK resolves the backtick escape sequences.
+func unescapeAHK(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '`' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+		case 's':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// ahkKeys are the Send keys that have a textual equivalent.
+var ahkKeys = map[string]string{
+	"enter": "\n",
+	"tab":   "\t",
+	"space": " ",
+}
+
+// translateAHKSend interprets Send syntax: {Key} names and the !, ^, +
+// and # modifiers. Key combinations and keys that only make sense as
+// keystrokes are dropped and reported.
+func translateAHKSend(s, trigger string, r *Report) string {
+	var (
+		b       strings.Builder
+		dropped []string
+		mods    string
+	)
+	emit := func(raw, text string, ok bool) {
+		if mods != "" || !ok {
+			dropped = append(dropped, mods+raw)
+		} else {
+			b.WriteString(text)
+		}
+		mods = ""
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '!', '^', '+', '#':
+			mods += string(c)
+		case '{':
+			end := strings.IndexByte(s[i+1:], '}')
+			if end == 0 { // {}}
+				end = 1 + strings.IndexByte(s[i+2:], '}')
+			}
+			if end < 0 {
+				emit("{", "{", true)
+				continue
+			}
+			key := s[i+1 : i+1+end]
+			i += end + 1
+			name, count := key, 1
+			if f := strings.Fields(key); len(f) == 2 {
+				name = f[0]
+				fmt.Sscanf(f[1], "%d", &count)
+			}
+			text, ok := ahkKeys[strings.ToLower(name)]
+			if !ok && len([]rune(name)) == 1 {
+				text, ok = name, true
+			}
+			emit("{"+key+"}", strings.Repeat(text, count), ok)
+		default:
+			emit(s[i:i+1], s[i:i+1], true)
+		}
+	}
+	if mods != "" {
+		dropped = append(dropped, mods)
+	}
+	if len(dropped) > 0 {
+		r.Change(trigger, "keystrokes and key combinations were dropped: "+strings.Join(dropped, " "))
+	}
+	return b.String()
+}
+
+// ahkVariables are the AutoHotkey v2 expressions the built-in template
+// variables become.
+var ahkVariables = map[string]string{
+	"DATE":      `FormatTime(, "yyyy-MM-dd")`,
+	"TIME":      `FormatTime(, "HH:mm:ss")`,
+	"DATETIME":  `FormatTime(, "yyyy-MM-dd HH:mm:ss")`,
+	"CLIPBOARD": "A_Clipboard",
+}
+
+// ahkWidths are the lengths of the variables that always have the same
+// length, so the caret can be moved back over them.
+var ahkWidths = map[string]int{"DATE": 10, "TIME": 8, "DATETIME": 19}
+
+// exportAHK writes AutoHotkey v2 hotstrings. Plain text becomes an
+// auto-replace hotstring; replacements with variables or a cursor marker
+// become a hotstring that sends an expression and moves the caret back.
+func exportAHK(w io.Writer, exps []config.Expansion, vars map[string]string, r *Report) error {
+	var b strings.Builder
+	b.WriteString("#Requires AutoHotkey v2.0\n\n")
+	for _, exp := range exps {
+		if exp.Trigger == "" {
+			r.Skip(exp.Name(), reasonNoTrigger)
+			continue
+		}
+
+		// C1 turns off AutoHotkey's case conforming, which the expander
+		// does not do either.
+		opts := "C1"
+		if exp.CaseSensitive {
+			opts = "C"
+		}
+
+		var (
+			parts   []string // expression operands
+			lit     strings.Builder
+			plain   strings.Builder
+			dynamic bool
+			cursor  = -1 // runes after the cursor marker, or -1
+			fixed   = true
+		)
+		flush := func() {
+			if lit.Len() > 0 {
+				parts = append(parts, ahkString(lit.String()))
+				lit.Reset()
+			}
+		}
+		literal := func(s string) {
+			plain.WriteString(s)
+			lit.WriteString(s)
+			if cursor >= 0 {
+				cursor += utf8.RuneCountInString(s)
+			}
+		}
+		walkTemplate(exp.Replacement, vars, literal, func(name string) {
+			switch expr, ok := ahkVariables[name]; {
+			case name == "CURSOR":
+				dynamic = true
+				cursor, fixed = 0, true
+			case ok:
+				dynamic = true
+				flush()
+				parts = append(parts, expr)
+				if cursor >= 0 {
+					width, known := ahkWidths[name]
+					cursor += width
+					fixed = fixed && known
+				}
+			default:
+				literal(vars[name])
+			}
+		})
+		flush()
+
+		if !dynamic {
+			writeAHKText(&b, opts, exp.Trigger, plain.String())
+		} else {
+			fmt.Fprintf(&b, ":%s:%s::\n{\n", opts, exp.Trigger)
+			if len(parts) > 0 {
+				fmt.Fprintf(&b, "    SendText %s\n", strings.Join(parts, " "))
+			}
+			if !fixed {
+				r.Change(exp.Trigger, "the cursor marker is followed by the clipboard, so the caret is left at the end")
+			} else if cursor > 0 {
+				fmt.Fprintf(&b, "    Send \"{Left %d}\"\n", cursor)
+			}
+			b.WriteString("}\n")
+		}
+
+		if exp.OutputFormat == config.OutputFormatMarkdown || exp.OutputFormat == config.OutputFormatHTML {
+			r.Change(exp.Trigger, "rich text is exported as its source text")
+		}
+		if exp.Hotkey != "" {
+			r.Change(exp.Trigger, "hotkeys are not exported")
+		}
+		r.Exported++
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeAHKText writes an auto-replace hotstring, using a continuation
+// section for text with several lines and text mode when it contains
+// characters Send would treat as keys.
+func writeAHKText(b *strings.Builder, opts, trigger, text string) {
+	if strings.ContainsAny(text, "!^+#{}") {
+		opts += "T"
+	}
+	if strings.Contains(text, "\n") {
+		fmt.Fprintf(b, ":%s:%s::\n(\n%s\n)\n", opts, trigger, escapeAHK(text, false))
+		return
+	}
+	fmt.Fprintf(b, ":%s:%s::%s\n", opts, trigger, escapeAHK(text, true))
+}
+
+// escapeAHK escapes hotstring text. On a single line, a semicolon after a
+// space would start a comment and surrounding blanks would be trimmed.
+func escapeAHK(text string, line bool) string {
+	text = strings.ReplaceAll(text, "`", "``")
+	text = strings.ReplaceAll(text, "\t", "`t")
+	text = strings.ReplaceAll(text, "\r", "`r")
+	if !line {
+		return text
+	}
+	text = strings.ReplaceAll(text, ";", "`;")
+	trimmed := strings.TrimRight(text, " ")
+	text = trimmed + strings.Repeat("`s", len(text)-len(trimmed))
+	if trimmed = strings.TrimLeft(text, " "); len(trimmed) < len(text) {
+		text = strings.Repeat("`s", len(text)-len(trimmed)) + trimmed
+	}
+	return text
+}
+
+// ahkString quotes s as an AutoHotkey v2 string literal.
+func ahkString(s string) string {
+	r := strings.NewReplacer("`", "``", `"`, "`\"", "\n", "`n", "\r", "`r", "\t", "`t")
+	return `"` + r.Replace(s) + `"`
+}
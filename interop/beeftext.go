@@ -0,0 +1,127 @@
+package interop
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"text-expander/config"
+)
+
+// beeftextFile is a Beeftext combo list, as exported or saved in
+// comboList.json.
+type beeftextFile struct {
+	Combos []beeftextCombo `json:"combos"`
+	Groups []struct {
+		UUID string `json:"uuid"`
+		Name string `json:"name"`
+	} `json:"groups"`
+}
+
+type beeftextCombo struct {
+	Name    string `json:"name"`
+	Keyword string `json:"keyword"`
+	Snippet string `json:"snippet"`
+	// Older files call them comboText and substitutionText.
+	ComboText        string `json:"comboText"`
+	SubstitutionText string `json:"substitutionText"`
+	Description      string `json:"description"`
+	Group            string `json:"group"`
+	Enabled          *bool  `json:"enabled"`
+	// CaseSensitivity is 0 for the application default, 1 for
+	// case-sensitive and 2 for case-insensitive.
+	CaseSensitivity int `json:"caseSensitivity"`
+}
+
+// beeftextDateCodes are the Qt date format codes #{dateTime:...} uses.
+var beeftextDateCodes = [6]string{"yyyy", "MM", "dd", "HH", "mm", "ss"}
+
+// beeftextVariable matches #{name} and #{name:argument}.
+var beeftextVariable = regexp.MustCompile(`#\{([A-Za-z]+)(?::([^}]*))?\}`)
+
+// importBeeftext converts a Beeftext combo list. Groups become categories
+// and #{combo:keyword} variables are replaced with the other combo's text.
+func importBeeftext(name string, data []byte, r *Report) ([]config.Expansion, error) {
+	var f beeftextFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]string, len(f.Groups))
+	for _, g := range f.Groups {
+		groups[g.UUID] = g.Name
+	}
+	snippets := make(map[string]string, len(f.Combos))
+	for i := range f.Combos {
+		c := &f.Combos[i]
+		if c.Keyword == "" {
+			c.Keyword = c.ComboText
+		}
+		if c.Snippet == "" {
+			c.Snippet = c.SubstitutionText
+		}
+		snippets[c.Keyword] = c.Snippet
+	}
+
+	var exps []config.Expansion
+	for _, c := range f.Combos {
+		item := c.Keyword
+		if item == "" {
+			r.Skip(c.Name, reasonNoTrigger)
+			continue
+		}
+		if c.Enabled != nil && !*c.Enabled {
+			r.Skip(item, "disabled in Beeftext")
+			continue
+		}
+
+		description := c.Name
+		if c.Description != "" {
+			description = c.Description
+		}
+		exps = append(exps, config.Expansion{
+			Trigger:       c.Keyword,
+			Replacement:   translateBeeftext(c.Snippet, snippets, item, r, 0),
+			CaseSensitive: c.CaseSensitivity == 1,
+			Description:   description,
+			Category:      groups[c.Group],
+		})
+		r.Change(item, reasonImmediate)
+	}
+	return exps, nil
+}
+
+// translateBeeftext replaces Beeftext variables with their template
+// equivalents, reporting any that have none. depth guards against combos
+// that include each other.
+func translateBeeftext(text string, snippets map[string]string, item string, r *Report, depth int) string {
+	return translateMatches(text, beeftextVariable, func(v string) string {
+		m := beeftextVariable.FindStringSubmatch(v)
+		switch strings.ToLower(m[1]) {
+		case "clipboard":
+			return "{CLIPBOARD}"
+		case "cursor":
+			return "{CURSOR}"
+		case "date":
+			return "{DATE}"
+		case "time":
+			return "{TIME}"
+		case "datetime":
+			if m[2] == "" {
+				return "{DATETIME}"
+			}
+			variable, exact := dateVariable(m[2], beeftextDateCodes)
+			if !exact {
+				r.Change(item, fmt.Sprintf("date format %q became %s", m[2], variable))
+			}
+			return variable
+		case "combo":
+			if s, ok := snippets[m[2]]; ok && depth < 8 {
+				return translateBeeftext(s, snippets, item, r, depth+1)
+			}
+		}
+		r.Change(item, fmt.Sprintf("Beeftext variable %s is not supported; left as text", v))
+		return escapeBraces(v)
+	})
+}
@@ -0,0 +1,253 @@
+package interop
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"text-expander/config"
+)
+
+// espansoFile is an espanso match file, such as match/base.yml.
+type espansoFile struct {
+	Matches    []espansoMatch `yaml:"matches"`
+	GlobalVars []espansoVar   `yaml:"global_vars"`
+}
+
+type espansoMatch struct {
+	Trigger       string       `yaml:"trigger"`
+	Triggers      []string     `yaml:"triggers"`
+	Regex         string       `yaml:"regex"`
+	Replace       *string      `yaml:"replace"`
+	Markdown      *string      `yaml:"markdown"`
+	HTML          *string      `yaml:"html"`
+	ImagePath     string       `yaml:"image_path"`
+	Form          string       `yaml:"form"`
+	Label         string       `yaml:"label"`
+	Word          bool         `yaml:"word"`
+	LeftWord      bool         `yaml:"left_word"`
+	RightWord     bool         `yaml:"right_word"`
+	PropagateCase bool         `yaml:"propagate_case"`
+	ForceClip     bool         `yaml:"force_clipboard"`
+	ForceMode     string       `yaml:"force_mode"`
+	Vars          []espansoVar `yaml:"vars"`
+}
+
+type espansoVar struct {
+	Name   string         `yaml:"name"`
+	Type   string         `yaml:"type"`
+	Params map[string]any `yaml:"params,omitempty"`
+}
+
+// espansoDateCodes are the strftime codes espanso date variables use.
+var espansoDateCodes = [6]string{"%Y", "%m", "%d", "%H", "%M", "%S"}
+
+// espansoPlaceholder matches {{name}} and {{form.field}}.
+var espansoPlaceholder = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// importEspanso converts an espanso match file. Triggers are case-sensitive
+// in espanso, so they are imported that way; date, clipboard and echo
+// variables become template variables, and $|$ marks the cursor.
+func importEspanso(name string, data []byte, r *Report) ([]config.Expansion, error) {
+	var f espansoFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	var exps []config.Expansion
+	for i, m := range f.Matches {
+		triggers := m.Triggers
+		if m.Trigger != "" {
+			triggers = append([]string{m.Trigger}, triggers...)
+		}
+		item := fmt.Sprintf("%s match %d", name, i+1)
+		if len(triggers) > 0 {
+			item = strings.Join(triggers, ", ")
+		}
+
+		switch {
+		case m.Regex != "":
+			r.Skip(m.Regex, "regex triggers are not supported")
+			continue
+		case len(triggers) == 0:
+			r.Skip(item, reasonNoTrigger)
+			continue
+		case m.ImagePath != "":
+			r.Skip(item, "image matches are not supported")
+			continue
+		case m.Form != "":
+			r.Skip(item, "form matches are not supported")
+			continue
+		}
+
+		exp := config.Expansion{
+			CaseSensitive: !m.PropagateCase,
+			Description:   m.Label,
+		}
+		var text string
+		switch {
+		case m.Replace != nil:
+			text = *m.Replace
+		case m.Markdown != nil:
+			text, exp.OutputFormat = *m.Markdown, config.OutputFormatMarkdown
+		case m.HTML != nil:
+			text, exp.OutputFormat = *m.HTML, config.OutputFormatHTML
+		default:
+			r.Skip(item, "has no replace, markdown or html text")
+			continue
+		}
+		exp.Replacement = translateEspanso(text, append(append([]espansoVar(nil), f.GlobalVars...), m.Vars...), item, r)
+
+		switch {
+		case m.ForceClip || m.ForceMode == "clipboard":
+			exp.InjectMode = config.InjectModePaste
+		case m.ForceMode == "keys":
+			exp.InjectMode = config.InjectModeType
+		}
+		if m.PropagateCase {
+			r.Change(item, "propagate_case is not supported; the trigger matches any case and the replacement is typed as written")
+		}
+		if !m.Word && !m.LeftWord && !m.RightWord {
+			r.Change(item, reasonImmediate)
+		}
+
+		for _, trigger := range triggers {
+			exp.Trigger = trigger
+			exps = append(exps, exp)
+		}
+	}
+	return exps, nil
+}
+
+// translateEspanso replaces the variables in text with their template
+// equivalents, reporting any that have none.
+func translateEspanso(text string, vars []espansoVar, item string, r *Report) string {
+	byName := make(map[string]espansoVar, len(vars))
+	for _, v := range vars {
+		byName[v.Name] = v
+	}
+
+	parts := strings.Split(text, "$|$")
+	for i, part := range parts {
+		parts[i] = translateMatches(part, espansoPlaceholder, func(ph string) string {
+			return translateEspansoVar(ph, byName, item, r)
+		})
+	}
+	return strings.Join(parts, "{CURSOR}")
+}
+
+// translateEspansoVar returns the template equivalent of the placeholder ph.
+func translateEspansoVar(ph string, byName map[string]espansoVar, item string, r *Report) string {
+	name := espansoPlaceholder.FindStringSubmatch(ph)[1]
+	v, ok := byName[name]
+	if !ok {
+		r.Change(item, fmt.Sprintf("variable {{%s}} is not defined in this file; left as text", name))
+		return escapeBraces(ph)
+	}
+	switch v.Type {
+	case "clipboard":
+		return "{CLIPBOARD}"
+	case "echo":
+		if s, ok := v.Params["echo"].(string); ok {
+			return escapeBraces(s)
+		}
+	case "date":
+		layout, _ := v.Params["format"].(string)
+		variable, exact := dateVariable(layout, espansoDateCodes)
+		if !exact || v.Params["offset"] != nil {
+			r.Change(item, fmt.Sprintf("date format %q became %s", layout, variable))
+		}
+		return variable
+	}
+	r.Change(item, fmt.Sprintf("%s variables are not supported; {{%s}} left as text", v.Type, name))
+	return escapeBraces(ph)
+}
+
+// espansoOutput is a match as exportEspanso writes it.
+type espansoOutput struct {
+	Trigger   string       `yaml:"trigger"`
+	Replace   string       `yaml:"replace,omitempty"`
+	Markdown  string       `yaml:"markdown,omitempty"`
+	HTML      string       `yaml:"html,omitempty"`
+	Label     string       `yaml:"label,omitempty"`
+	Word      bool         `yaml:"word"`
+	ForceMode string       `yaml:"force_mode,omitempty"`
+	Vars      []espansoVar `yaml:"vars,omitempty"`
+}
+
+// espansoVariables are the espanso variables the built-in template
+// variables become.
+var espansoVariables = map[string]espansoVar{
+	"DATE":      {Name: "date", Type: "date", Params: map[string]any{"format": "%Y-%m-%d"}},
+	"TIME":      {Name: "time", Type: "date", Params: map[string]any{"format": "%H:%M:%S"}},
+	"DATETIME":  {Name: "datetime", Type: "date", Params: map[string]any{"format": "%Y-%m-%d %H:%M:%S"}},
+	"CLIPBOARD": {Name: "clipboard", Type: "clipboard"},
+}
+
+// exportEspanso writes a match file. Matches are word matches, since
+// expansions wait for a terminator; custom variables become echo variables.
+func exportEspanso(w io.Writer, exps []config.Expansion, vars map[string]string, r *Report) error {
+	var f struct {
+		Matches []espansoOutput `yaml:"matches"`
+	}
+	for _, exp := range exps {
+		if exp.Trigger == "" {
+			r.Skip(exp.Name(), reasonNoTrigger)
+			continue
+		}
+
+		m := espansoOutput{Trigger: exp.Trigger, Label: exp.Description, Word: true}
+		var (
+			b    strings.Builder
+			used = make(map[string]bool)
+		)
+		walkTemplate(exp.Replacement, vars, func(s string) { b.WriteString(s) }, func(name string) {
+			if name == "CURSOR" {
+				b.WriteString("$|$")
+				return
+			}
+			v, ok := espansoVariables[name]
+			if !ok {
+				v = espansoVar{Name: strings.ToLower(name), Type: "echo", Params: map[string]any{"echo": vars[name]}}
+			}
+			if !used[v.Name] {
+				used[v.Name] = true
+				m.Vars = append(m.Vars, v)
+			}
+			b.WriteString("{{" + v.Name + "}}")
+		})
+
+		switch exp.OutputFormat {
+		case config.OutputFormatMarkdown:
+			m.Markdown = b.String()
+		case config.OutputFormatHTML:
+			m.HTML = b.String()
+		default:
+			m.Replace = b.String()
+		}
+		switch exp.InjectMode {
+		case config.InjectModePaste:
+			m.ForceMode = "clipboard"
+		case config.InjectModeType:
+			m.ForceMode = "keys"
+		}
+		if !exp.CaseSensitive {
+			r.Change(exp.Trigger, "espanso matches triggers case-sensitively")
+		}
+		if exp.Hotkey != "" {
+			r.Change(exp.Trigger, "hotkeys are not exported")
+		}
+		f.Matches = append(f.Matches, m)
+		r.Exported++
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,164 @@
+package interop
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"text-expander/config"
+)
+
+// Exporter writes expansions in another tool's format.
+type Exporter struct {
+	Name        string
+	Description string
+	Extension   string
+	// Export writes exps to w. vars are the custom template variables,
+	// for formats that need their values.
+	Export func(w io.Writer, exps []config.Expansion, vars map[string]string, r *Report) error
+}
+
+// Exporters lists the supported export formats.
+var Exporters = []Exporter{
+	{"csv", "Spreadsheet (.csv)", ".csv", exportCSV},
+	{"espanso", "espanso match file (.yml)", ".yml", exportEspanso},
+	{"ahk", "AutoHotkey v2 hotstrings (.ahk)", ".ahk", exportAHK},
+}
+
+// ExporterByName returns the exporter called name.
+func ExporterByName(name string) (Exporter, error) {
+	for _, e := range Exporters {
+		if strings.EqualFold(e.Name, name) {
+			return e, nil
+		}
+	}
+	return Exporter{}, fmt.Errorf("unknown export format %q", name)
+}
+
+// ExporterForPath returns the exporter for the extension of path.
+func ExporterForPath(path string) (Exporter, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" {
+		ext = ".yml"
+	}
+	for _, e := range Exporters {
+		if e.Extension == ext {
+			return e, nil
+		}
+	}
+	return Exporter{}, fmt.Errorf("cannot tell the export format of %s; use .csv, .yml or .ahk", filepath.Base(path))
+}
+
+// FilterByCategory returns the expansions in any of categories, compared
+// case-insensitively, or all of them when categories is empty.
+func FilterByCategory(exps []config.Expansion, categories []string) []config.Expansion {
+	if len(categories) == 0 {
+		return exps
+	}
+	var out []config.Expansion
+	for _, exp := range exps {
+		for _, c := range categories {
+			if strings.EqualFold(exp.Category, strings.TrimSpace(c)) {
+				out = append(out, exp)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Export writes the user's own expansions in categories (all when empty)
+// to w with e. Pack expansions are not included; packs are shared as files.
+func Export(w io.Writer, cfg *config.Config, e Exporter, categories []string, r *Report) error {
+	r.export = true
+	exps := FilterByCategory(cfg.GetExpansions(), categories)
+	return e.Export(w, exps, cfg.GetCustomVars(), r)
+}
+
+// ExportFile writes the expansions in categories to path in the named
+// format, or the one its extension selects when format is empty.
+func ExportFile(cfg *config.Config, path, format string, categories []string, r *Report) error {
+	var (
+		e   Exporter
+		err error
+	)
+	if format == "" {
+		e, err = ExporterForPath(path)
+	} else {
+		e, err = ExporterByName(format)
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Export(f, cfg, e, categories, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// csvHeader names the columns exportCSV writes.
+var csvHeader = []string{"trigger", "replacement", "description", "category", "case_sensitive", "hotkey", "output_format", "inject_mode"}
+
+// exportCSV writes one row per expansion, with template variables as they
+// are, for review in a spreadsheet.
+func exportCSV(w io.Writer, exps []config.Expansion, _ map[string]string, r *Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, exp := range exps {
+		row := []string{
+			exp.Trigger, exp.Replacement, exp.Description, exp.Category,
+			strconv.FormatBool(exp.CaseSensitive), exp.Hotkey, exp.OutputFormat, exp.InjectMode,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+		r.Exported++
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// walkTemplate splits tmpl the way the expander's template processor does,
+// calling text for literal runs and variable, with the upper-cased name,
+// for each built-in or custom variable. Unknown {names} are literal text, and
+// {{} a literal brace.
+func walkTemplate(tmpl string, vars map[string]string, text, variable func(string)) {
+	for tmpl != "" {
+		i := strings.IndexByte(tmpl, '{')
+		if i < 0 {
+			text(tmpl)
+			return
+		}
+		j := strings.IndexByte(tmpl[i:], '}')
+		if j < 0 {
+			text(tmpl)
+			return
+		}
+		name := strings.ToUpper(tmpl[i+1 : i+j])
+		_, custom := vars[name]
+		switch {
+		case name == "{":
+			text(tmpl[:i+1])
+		case name == "DATE", name == "TIME", name == "DATETIME", name == "CLIPBOARD", name == "CURSOR", custom:
+			if i > 0 {
+				text(tmpl[:i])
+			}
+			variable(name)
+		default:
+			text(tmpl[:i+j+1])
+		}
+		tmpl = tmpl[i+j+1:]
+	}
+}
@@ -0,0 +1,136 @@
+package interop
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+
+	"text-expander/config"
+)
+
+func TestFilterByCategory(t *testing.T) {
+	exps := []config.Expansion{
+		{Trigger: "a", Category: "Python"},
+		{Trigger: "b", Category: "Go"},
+		{Trigger: "c"},
+	}
+	if got := FilterByCategory(exps, nil); len(got) != 3 {
+		t.Errorf("expected no filter to keep everything, got %+v", got)
+	}
+	got := FilterByCategory(exps, []string{"python", " Go"})
+	if len(got) != 2 || got[0].Trigger != "a" || got[1].Trigger != "b" {
+		t.Errorf("unexpected filter result %+v", got)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	exps := []config.Expansion{
+		{Trigger: ";sig", Replacement: "Regards,\n{NAME}", Description: "Signature, short", Category: "Personal", CaseSensitive: true},
+		{Hotkey: "ctrl+alt+d", Replacement: "{DATE}"},
+	}
+	var buf bytes.Buffer
+	var r Report
+	if err := exportCSV(&buf, exps, nil, &r); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		csvHeader,
+		{";sig", "Regards,\n{NAME}", "Signature, short", "Personal", "true", "", "", ""},
+		{"", "{DATE}", "", "", "false", "ctrl+alt+d", "", ""},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got  %q\nwant %q", rows, want)
+	}
+	if r.Exported != 2 {
+		t.Errorf("expected 2 exported, got %d", r.Exported)
+	}
+}
+
+func TestExportEspansoRoundTrips(t *testing.T) {
+	exps := []config.Expansion{
+		{Trigger: ";sig", Replacement: "Regards,\n{name}\n{DATE} {date}", Description: "Signature", CaseSensitive: true},
+		{Trigger: ";cb", Replacement: "<{CLIPBOARD}>{CURSOR} {UNKNOWN}", CaseSensitive: true, InjectMode: config.InjectModePaste},
+		{Trigger: ";b", Replacement: "**bold**", CaseSensitive: true, OutputFormat: config.OutputFormatMarkdown},
+		{Trigger: ";any", Replacement: "x", Hotkey: "ctrl+alt+x"},
+		{Hotkey: "ctrl+alt+y", Replacement: "y"},
+	}
+	var buf bytes.Buffer
+	var r Report
+	if err := exportEspanso(&buf, exps, map[string]string{"NAME": "Ada"}, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Exported != 4 || len(r.Skipped) != 1 {
+		t.Errorf("expected 4 exported and the hotkey-only expansion skipped, got %+v", r)
+	}
+	if got := reasons(r.Changed, ";any"); len(got) != 2 {
+		t.Errorf("expected case and hotkey changes for ;any, got %q", got)
+	}
+
+	var back Report
+	got, err := importEspanso("export.yml", buf.Bytes(), &back)
+	if err != nil {
+		t.Fatalf("import: %v\n%s", err, buf.String())
+	}
+	// {UNKNOWN} is text in the export, so it comes back escaped.
+	want := []config.Expansion{
+		{Trigger: ";sig", Replacement: "Regards,\nAda\n{DATE} {DATE}", Description: "Signature", CaseSensitive: true},
+		{Trigger: ";cb", Replacement: "<{CLIPBOARD}>{CURSOR} {{}UNKNOWN}", CaseSensitive: true, InjectMode: config.InjectModePaste},
+		{Trigger: ";b", Replacement: "**bold**", CaseSensitive: true, OutputFormat: config.OutputFormatMarkdown},
+		{Trigger: ";any", Replacement: "x", CaseSensitive: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v\n%s", got, want, buf.String())
+	}
+	if len(back.Skipped)+len(back.Changed) != 0 {
+		t.Errorf("expected a clean re-import, got %+v", back)
+	}
+}
+
+func TestExportAHK(t *testing.T) {
+	exps := []config.Expansion{
+		{Trigger: "btw", Replacement: "by the way"},
+		{Trigger: "Ahk", Replacement: "AutoHotkey; 100%! ", CaseSensitive: true},
+		{Trigger: "addr", Replacement: "1 Main St\n\tSpringfield"},
+		{Trigger: "sig", Replacement: "Hi {NAME},\n{CURSOR}\n\"{DATE}\""},
+		{Trigger: "cb", Replacement: "{CURSOR}{CLIPBOARD}"},
+	}
+	var buf bytes.Buffer
+	var r Report
+	if err := exportAHK(&buf, exps, map[string]string{"NAME": "Ada"}, &r); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"#Requires AutoHotkey v2.0\n",
+		":C1:btw::by the way\n",
+		":CT:Ahk::AutoHotkey`; 100%!`s\n",
+		":C1:addr::\n(\n1 Main St\n`tSpringfield\n)\n",
+		":C1:sig::\n{\n    SendText \"Hi Ada,`n`n`\"\" FormatTime(, \"yyyy-MM-dd\") \"`\"\"\n    Send \"{Left 13}\"\n}\n",
+		":C1:cb::\n{\n    SendText A_Clipboard\n}\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if got := reasons(r.Changed, "cb"); len(got) != 1 {
+		t.Errorf("expected the unplaceable cursor of cb to be reported, got %q", got)
+	}
+
+	// Plain hotstrings survive a round trip.
+	var back Report
+	got, err := importAHK("export.ahk", buf.Bytes(), &back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, exp := range got[:3] {
+		if exp.Trigger != exps[i].Trigger || exp.Replacement != exps[i].Replacement || exp.CaseSensitive != exps[i].CaseSensitive {
+			t.Errorf("round trip changed %+v into %+v", exps[i], exp)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Package interop imports snippets from other text expanders and exports
+// expansions to them. Each importer maps the source's triggers, options and
+// variables onto config.Expansion, each exporter does the reverse, and both
+// record in a Report whatever had no equivalent.
+package interop
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"text-expander/config"
+)
+
+// Format is a snippet format that can be imported.
+type Format struct {
+	Name        string
+	Description string
+	// Import converts the contents of the file called name.
+	Import func(name string, data []byte, r *Report) ([]config.Expansion, error)
+}
+
+// Formats lists the supported formats.
+var Formats = []Format{
+	{"espanso", "espanso match files (.yml)", importEspanso},
+	{"ahk", "AutoHotkey hotstrings (.ahk)", importAHK},
+	{"beeftext", "Beeftext combo export (.json)", importBeeftext},
+	{"textexpander", "TextExpander group export (.csv)", importTextExpander},
+	{"alfred", "Alfred snippet collection (.alfredsnippets)", importAlfred},
+}
+
+// FormatByName returns the format called name.
+func FormatByName(name string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(f.Name, name) {
+			return f, nil
+		}
+	}
+	return Format{}, fmt.Errorf("unknown import format %q", name)
+}
+
+// DetectFormat guesses the format of the file called name from its
+// extension and, for JSON, its contents.
+func DetectFormat(name string, data []byte) (Format, error) {
+	var format string
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yml", ".yaml":
+		format = "espanso"
+	case ".ahk":
+		format = "ahk"
+	case ".csv":
+		format = "textexpander"
+	case ".alfredsnippets":
+		format = "alfred"
+	case ".json":
+		switch {
+		case bytes.Contains(data, []byte(`"alfredsnippet"`)):
+			format = "alfred"
+		case bytes.Contains(data, []byte(`"combos"`)):
+			format = "beeftext"
+		}
+	}
+	if format == "" {
+		return Format{}, fmt.Errorf("cannot tell the format of %s; choose one of %s", filepath.Base(name), formatNames())
+	}
+	return FormatByName(format)
+}
+
+func formatNames() string {
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = f.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// ImportFile reads the file at path in the named format, or the detected one
+// when format is empty, and adds what it finds to r.
+func ImportFile(path, format string, r *Report) ([]config.Expansion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f Format
+	if format == "" {
+		f, err = DetectFormat(path, data)
+	} else {
+		f, err = FormatByName(format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	exps, err := f.Import(filepath.Base(path), data, r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return exps, nil
+}
+
+// AddToConfig adds exps to cfg, reporting those it refuses, such as
+// duplicated triggers, as skipped. It returns the number added.
+func AddToConfig(cfg *config.Config, exps []config.Expansion, r *Report) int {
+	added := 0
+	for _, exp := range exps {
+		if err := cfg.AddExpansion(exp); err != nil {
+			r.Skip(exp.Name(), err.Error())
+			continue
+		}
+		added++
+	}
+	r.Imported += added
+	return added
+}
+
+// Issue is a problem with one source item.
+type Issue struct {
+	Item   string // the item's trigger or name
+	Reason string
+}
+
+// Report describes what an import or export could not carry over.
+type Report struct {
+	Imported int
+	Exported int
+	// Skipped items were left out.
+	Skipped []Issue
+	// Changed items were carried over without some of their features, or
+	// with them approximated.
+	Changed []Issue
+
+	export bool // set by ExportFile
+}
+
+// Skip records that item was left out.
+func (r *Report) Skip(item, reason string) {
+	r.Skipped = append(r.Skipped, Issue{item, reason})
+}
+
+// Change records that item was imported imperfectly.
+func (r *Report) Change(item, reason string) {
+	r.Changed = append(r.Changed, Issue{item, reason})
+}
+
+// Write prints the report, grouping items that share a reason.
+func (r *Report) Write(w io.Writer) {
+	verb, n := "Imported", r.Imported
+	if r.export {
+		verb, n = "Exported", r.Exported
+	}
+	fmt.Fprintf(w, "%s %d expansions.\n", verb, n)
+	for _, section := range []struct {
+		title  string
+		issues []Issue
+	}{
+		{"Skipped", r.Skipped},
+		{verb + " with changes", r.Changed},
+	} {
+		if len(section.issues) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s (%d):\n", section.title, len(section.issues))
+		var reasons []string
+		items := make(map[string][]string)
+		for _, issue := range section.issues {
+			if _, ok := items[issue.Reason]; !ok {
+				reasons = append(reasons, issue.Reason)
+			}
+			items[issue.Reason] = append(items[issue.Reason], issue.Item)
+		}
+		for _, reason := range reasons {
+			fmt.Fprintf(w, "  - %s: %s\n", reason, summarize(items[reason]))
+		}
+	}
+}
+
+// String returns the report as Write prints it.
+func (r *Report) String() string {
+	var b strings.Builder
+	r.Write(&b)
+	return b.String()
+}
+
+// summarize lists up to a handful of items.
+func summarize(items []string) string {
+	const max = 8
+	if len(items) <= max {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:max], ", "), len(items)-max)
+}
+
+// Reasons shared by several importers.
+const (
+	reasonImmediate = "expanded as soon as it was typed; here it expands after Space, Tab or Enter"
+	reasonNoTrigger = "has no trigger"
+)
+
+// escapeBraces escapes the braces in literal text that the expander would
+// read as a variable, writing them as {{}. Braces around anything but a name,
+// as in code, are left alone; so is text without braces.
+func escapeBraces(s string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '{' {
+			b.WriteByte(s[i])
+			continue
+		}
+		j := strings.IndexByte(s[i+1:], '}')
+		if j < 0 || s[i+1:i+1+j] == "{" || templateName.MatchString(s[i+1:i+1+j]) {
+			// Text that follows an unclosed brace may close it.
+			b.WriteString("{{}")
+			continue
+		}
+		b.WriteString(s[i : i+j+2])
+		i += j + 1
+	}
+	return b.String()
+}
+
+// templateName matches what the expander may take for a variable name.
+var templateName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// translateMatches escapes the literal text around the matches of re in s
+// and replaces each match with what repl returns, which is template text.
+func translateMatches(s string, re *regexp.Regexp, repl func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		b.WriteString(escapeBraces(s[last:m[0]]))
+		b.WriteString(repl(s[m[0]:m[1]]))
+		last = m[1]
+	}
+	b.WriteString(escapeBraces(s[last:]))
+	return b.String()
+}
+
+// dateVariable returns the variable for a date layout written with the
+// given year, month, day, hour, minute and second codes, and whether it
+// matches exactly; other layouts are approximated by {DATE} or {DATETIME}.
+func dateVariable(layout string, codes [6]string) (string, bool) {
+	date := codes[0] + "-" + codes[1] + "-" + codes[2]
+	clock := codes[3] + ":" + codes[4] + ":" + codes[5]
+	switch layout {
+	case date:
+		return "{DATE}", true
+	case clock:
+		return "{TIME}", true
+	case date + " " + clock:
+		return "{DATETIME}", true
+	}
+	if strings.Contains(layout, codes[3]) || strings.Contains(layout, codes[4]) {
+		return "{DATETIME}", false
+	}
+	return "{DATE}", false
+}
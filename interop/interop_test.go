@@ -0,0 +1,346 @@
+package interop
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"text-expander/config"
+)
+
+// reasons returns the reasons reported for item.
+func reasons(issues []Issue, item string) []string {
+	var out []string
+	for _, issue := range issues {
+		if issue.Item == item {
+			out = append(out, issue.Reason)
+		}
+	}
+	return out
+}
+
+func TestImportEspanso(t *testing.T) {
+	const src = `
+global_vars:
+  - name: today
+    type: date
+    params:
+      format: "%Y-%m-%d"
+matches:
+  - trigger: ":btw"
+    replace: "by the way"
+    word: true
+  - triggers: [":sig", ":signature"]
+    replace: "Regards,\n$|$\n{{today}}"
+    label: Signature
+  - trigger: ":us"
+    replace: "{{d}} {{clip}} {{out}}"
+    propagate_case: true
+    word: true
+    vars:
+      - name: d
+        type: date
+        params:
+          format: "%m/%d/%Y"
+      - name: clip
+        type: clipboard
+      - name: out
+        type: shell
+        params:
+          cmd: "echo hi"
+  - trigger: ":b"
+    markdown: "**bold**"
+    force_clipboard: true
+    word: true
+  - regex: ":(?P<n>\\d+)x"
+    replace: "{{n}}"
+  - trigger: ":pic"
+    image_path: "/tmp/a.png"
+`
+	var r Report
+	exps, err := importEspanso("base.yml", []byte(src), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []config.Expansion{
+		{Trigger: ":btw", Replacement: "by the way", CaseSensitive: true},
+		{Trigger: ":sig", Replacement: "Regards,\n{CURSOR}\n{DATE}", CaseSensitive: true, Description: "Signature"},
+		{Trigger: ":signature", Replacement: "Regards,\n{CURSOR}\n{DATE}", CaseSensitive: true, Description: "Signature"},
+		{Trigger: ":us", Replacement: "{DATE} {CLIPBOARD} {{out}}"},
+		{Trigger: ":b", Replacement: "**bold**", CaseSensitive: true, OutputFormat: config.OutputFormatMarkdown, InjectMode: config.InjectModePaste},
+	}
+	if !reflect.DeepEqual(exps, want) {
+		t.Fatalf("got  %+v\nwant %+v", exps, want)
+	}
+
+	if len(r.Skipped) != 2 {
+		t.Errorf("expected the regex and image matches to be skipped, got %+v", r.Skipped)
+	}
+	if got := reasons(r.Changed, ":sig, :signature"); !reflect.DeepEqual(got, []string{reasonImmediate}) {
+		t.Errorf("unexpected changes for :sig: %q", got)
+	}
+	if got := reasons(r.Changed, ":us"); len(got) != 3 {
+		t.Errorf("expected the date format, shell variable and propagate_case to be reported, got %q", got)
+	}
+	if got := reasons(r.Changed, ":btw"); got != nil {
+		t.Errorf("expected :btw to import cleanly, got %q", got)
+	}
+}
+
+func TestImportAHK(t *testing.T) {
+	const src = "; my hotstrings\r\n" +
+		"::btw::by the way\r\n" +
+		":*C:Ahk::AutoHotkey ; trailing comment\r\n" +
+		"::addr::\r\n" +
+		"(\r\n" +
+		"1 Main St\r\n" +
+		"Springfield\r\n" +
+		")\r\n" +
+		"::sel::{Home}+{End}copied{Enter}{! 2}\r\n" +
+		":R:raw::50% off! `n{Tab}\r\n" +
+		"::run::\r\n" +
+		"    Run notepad\r\n" +
+		"return\r\n" +
+		"/*\r\n" +
+		"::old::commented out\r\n" +
+		"*/\r\n" +
+		"#Hotstring *\r\n" +
+		"::fast::immediately\r\n"
+
+	var r Report
+	exps, err := importAHK("work.ahk", []byte(src), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]config.Expansion)
+	var order []string
+	for _, exp := range exps {
+		got[exp.Trigger] = exp
+		order = append(order, exp.Trigger)
+	}
+	if want := []string{"btw", "Ahk", "addr", "sel", "raw", "fast"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("imported %q, want %q", order, want)
+	}
+
+	for trigger, want := range map[string]string{
+		"btw":  "by the way",
+		"Ahk":  "AutoHotkey",
+		"addr": "1 Main St\nSpringfield",
+		"sel":  "copied\n!!",
+		"raw":  "50% off! \n{{}Tab}",
+	} {
+		if got[trigger].Replacement != want {
+			t.Errorf("%s: replacement %q, want %q", trigger, got[trigger].Replacement, want)
+		}
+	}
+	if !got["Ahk"].CaseSensitive || got["btw"].CaseSensitive {
+		t.Error("expected only the C option to make a hotstring case-sensitive")
+	}
+
+	if reasons(r.Skipped, "run") == nil {
+		t.Error("expected the hotstring that runs code to be skipped")
+	}
+	for _, trigger := range []string{"Ahk", "fast"} {
+		if got := reasons(r.Changed, trigger); !reflect.DeepEqual(got, []string{reasonImmediate}) {
+			t.Errorf("%s: unexpected changes %q", trigger, got)
+		}
+	}
+	if got := reasons(r.Changed, "sel"); len(got) != 1 || !strings.Contains(got[0], "{Home} +{End}") {
+		t.Errorf("expected the dropped keys of sel to be listed, got %q", got)
+	}
+}
+
+func TestImportAHKHotstringDirectives(t *testing.T) {
+	tests := []struct {
+		name, directive string
+		caseSensitive   bool
+		replacement     string
+		changed         bool // the directive is listed as changed
+		skipped         bool // the directive is listed as skipped
+	}{
+		{name: "options", directive: "#Hotstring C R", caseSensitive: true, replacement: "{Tab}"},
+		{name: "EndChars", directive: "#Hotstring EndChars -()[]{}:;", replacement: "\t", changed: true},
+		{name: "NoMouse", directive: "#Hotstring NoMouse", replacement: "\t"},
+		{name: "Reset", directive: "#Hotstring Reset", replacement: "\t"},
+		{name: "unknown", directive: "#Hotstring Whatever", replacement: "\t", skipped: true},
+	}
+	for _, tt := range tests {
+		var r Report
+		exps, err := importAHK("t.ahk", []byte(tt.directive+"\n::tb::{Tab}\n"), &r)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(exps) != 1 {
+			t.Fatalf("%s: imported %+v", tt.name, exps)
+		}
+		// {Tab} stays literal only in raw mode, where its brace is escaped.
+		want := tt.replacement
+		if want == "{Tab}" {
+			want = "{{}Tab}"
+		}
+		if exps[0].Replacement != want || exps[0].CaseSensitive != tt.caseSensitive {
+			t.Errorf("%s: got %+v", tt.name, exps[0])
+		}
+		if changed := len(r.Changed) > 0; changed != tt.changed {
+			t.Errorf("%s: changes %+v", tt.name, r.Changed)
+		}
+		if skipped := len(r.Skipped) > 0; skipped != tt.skipped {
+			t.Errorf("%s: skipped %+v", tt.name, r.Skipped)
+		}
+	}
+}
+
+func TestImportEscapesBraces(t *testing.T) {
+	var r Report
+	exps, err := importAHK("t.ahk", []byte(":R:d::{DATE} is {date}, {x + 1} {} {{}\n::cb::{{}CLIPBOARD{}}\n"), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{{}DATE} is {{}date}, {x + 1} {} {{}{}"; exps[0].Replacement != want {
+		t.Errorf("raw text: got %q, want %q", exps[0].Replacement, want)
+	}
+	if want := "{{}CLIPBOARD}"; exps[1].Replacement != want {
+		t.Errorf("sent braces: got %q, want %q", exps[1].Replacement, want)
+	}
+
+	const src = "matches:\n  - trigger: \":c\"\n    replace: \"{NAME}: $|$ {{n}} {{none}}\"\n    vars:\n      - name: n\n        type: echo\n        params:\n          echo: \"{TIME}\"\n"
+	exps, err = importEspanso("m.yml", []byte(src), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{{}NAME}: {CURSOR} {{}TIME} {{none}}"; exps[0].Replacement != want {
+		t.Errorf("espanso: got %q, want %q", exps[0].Replacement, want)
+	}
+
+	if got, want := translateTextExpander("{DATE} %Y {x", "te", &r), "{{}DATE} {DATE} {{}x"; got != want {
+		t.Errorf("TextExpander: got %q, want %q", got, want)
+	}
+}
+
+func TestImportBeeftext(t *testing.T) {
+	const src = `{
+  "fileFormatVersion": 7,
+  "groups": [{"uuid": "{g1}", "name": "Work"}],
+  "combos": [
+    {"name": "Email", "keyword": "@@", "snippet": "ada@example.com", "group": "{g1}", "caseSensitivity": 1, "enabled": true},
+    {"name": "Sig", "keyword": ";sig", "snippet": "#{combo:@@}\n#{dateTime:dd/MM/yyyy} #{cursor} #{input:Who?}"},
+    {"name": "Off", "keyword": ";off", "snippet": "x", "enabled": false}
+  ]
+}`
+	var r Report
+	exps, err := importBeeftext("comboList.json", []byte(src), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []config.Expansion{
+		{Trigger: "@@", Replacement: "ada@example.com", CaseSensitive: true, Description: "Email", Category: "Work"},
+		{Trigger: ";sig", Replacement: "ada@example.com\n{DATE} {CURSOR} #{input:Who?}", Description: "Sig"},
+	}
+	if !reflect.DeepEqual(exps, want) {
+		t.Fatalf("got  %+v\nwant %+v", exps, want)
+	}
+	if reasons(r.Skipped, ";off") == nil {
+		t.Error("expected the disabled combo to be skipped")
+	}
+	if got := reasons(r.Changed, ";sig"); len(got) != 3 {
+		t.Errorf("expected the date format, input variable and immediate expansion to be reported, got %q", got)
+	}
+}
+
+func TestImportTextExpander(t *testing.T) {
+	const src = "abbreviation,content,label\n" +
+		"ddate,\"Today is %Y-%m-%d, 100% sure\",Date\n" +
+		"paste,%clipboard then %| and %fill:name%,\n" +
+		",orphan,\n"
+	var r Report
+	exps, err := importTextExpander("group.csv", []byte(src), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []config.Expansion{
+		{Trigger: "ddate", Replacement: "Today is {DATE}, 100% sure", CaseSensitive: true, Description: "Date"},
+		{Trigger: "paste", Replacement: "{CLIPBOARD} then {CURSOR} and %fill:name%", CaseSensitive: true},
+	}
+	if !reflect.DeepEqual(exps, want) {
+		t.Fatalf("got  %+v\nwant %+v", exps, want)
+	}
+	if len(r.Skipped) != 1 || len(r.Changed) != 1 {
+		t.Errorf("expected one skipped row and one unsupported macro, got %+v", r)
+	}
+}
+
+func TestImportAlfred(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"info.plist": `<plist><dict><key>snippetkeywordprefix</key><string>!</string>` +
+			`<key>snippetkeywordsuffix</key><string></string></dict></plist>`,
+		"Hello [a1].json": `{"alfredsnippet": {"snippet": "Hello {clipboard}{cursor} {date:short}", "name": "Hello", "keyword": "hi", "dontautoexpand": true}}`,
+		"Empty [a2].json": `{"alfredsnippet": {"snippet": "x", "name": "Empty", "keyword": ""}}`,
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var r Report
+	exps, err := importAlfred("Mine.alfredsnippets", buf.Bytes(), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []config.Expansion{
+		{Trigger: "!hi", Replacement: "Hello {CLIPBOARD}{CURSOR} {date:short}", CaseSensitive: true, Description: "Hello"},
+	}
+	if !reflect.DeepEqual(exps, want) {
+		t.Fatalf("got  %+v\nwant %+v", exps, want)
+	}
+	if len(r.Skipped) != 1 || len(reasons(r.Changed, "!hi")) != 1 {
+		t.Errorf("expected the snippet without keyword to be skipped and {date:short} reported, got %+v", r)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name, data, want string
+	}{
+		{"base.yml", "", "espanso"},
+		{"hotstrings.AHK", "", "ahk"},
+		{"comboList.json", `{"combos": []}`, "beeftext"},
+		{"snippet.json", `{"alfredsnippet": {}}`, "alfred"},
+		{"Group.csv", "", "textexpander"},
+	} {
+		f, err := DetectFormat(tt.name, []byte(tt.data))
+		if err != nil || f.Name != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, f.Name, err, tt.want)
+		}
+	}
+	if _, err := DetectFormat("other.json", []byte(`{}`)); err == nil {
+		t.Error("expected an error for JSON that is neither Beeftext nor Alfred")
+	}
+}
+
+func TestAddToConfigReportsDuplicates(t *testing.T) {
+	cfg := &config.Config{Expansions: []config.Expansion{{Trigger: "btw", Replacement: "mine"}}}
+	var r Report
+	added := AddToConfig(cfg, []config.Expansion{
+		{Trigger: "btw", Replacement: "theirs"},
+		{Trigger: "omw", Replacement: "on my way"},
+	}, &r)
+	if added != 1 || len(cfg.Expansions) != 2 || cfg.Expansions[0].Replacement != "mine" {
+		t.Fatalf("expected only omw to be added, got %d: %+v", added, cfg.Expansions)
+	}
+	if reasons(r.Skipped, "btw") == nil {
+		t.Error("expected the duplicate to be reported")
+	}
+	if out := r.String(); !strings.Contains(out, "Imported 1 expansions.") || !strings.Contains(out, "btw") {
+		t.Errorf("unexpected report:\n%s", out)
+	}
+}
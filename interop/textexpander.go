@@ -0,0 +1,206 @@
+package interop
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"text-expander/config"
+)
+
+// textExpanderDate matches a run of date macros such as %Y-%m-%d, with the
+// separators between them.
+var textExpanderDate = regexp.MustCompile(`^%[A-Za-z](?:[-/:. ]*%[A-Za-z])*`)
+
+// textExpanderMacro matches named macros such as %fill:name% and %key:tab%.
+var textExpanderMacro = regexp.MustCompile(`^%[a-z]{2,}(?::[^%]*)?%`)
+
+// textExpanderDateCodes are the codes TextExpander's date macros use.
+var textExpanderDateCodes = [6]string{"%Y", "%m", "%d", "%H", "%M", "%S"}
+
+// importTextExpander converts a TextExpander group exported as CSV: one
+// snippet per row, with the abbreviation, the content and an optional label.
+func importTextExpander(name string, data []byte, r *Report) ([]config.Expansion, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var exps []config.Expansion
+	for i, row := range rows {
+		if i == 0 && len(row) > 0 && strings.EqualFold(row[0], "abbreviation") {
+			continue // header
+		}
+		if len(row) < 2 || strings.TrimSpace(row[0]) == "" {
+			r.Skip(fmt.Sprintf("%s row %d", name, i+1), reasonNoTrigger)
+			continue
+		}
+		exp := config.Expansion{
+			Trigger:       row[0],
+			Replacement:   translateTextExpander(row[1], row[0], r),
+			CaseSensitive: true,
+		}
+		if len(row) > 2 {
+			exp.Description = row[2]
+		}
+		exps = append(exps, exp)
+	}
+	return exps, nil
+}
+
+// translateTextExpander replaces the clipboard, cursor and date macros with
+// template variables. Other macros, such as fill-ins and key presses, are
+// left as text and reported.
+func translateTextExpander(text, item string, r *Report) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(text, '%')
+		if i < 0 || i+1 == len(text) {
+			b.WriteString(escapeBraces(text))
+			return b.String()
+		}
+		b.WriteString(escapeBraces(text[:i]))
+		text = text[i:]
+
+		switch {
+		case strings.HasPrefix(text, "%%"):
+			b.WriteByte('%')
+			text = text[2:]
+		case strings.HasPrefix(text, "%|"):
+			b.WriteString("{CURSOR}")
+			text = text[2:]
+		case strings.HasPrefix(text, "%clipboard"):
+			b.WriteString("{CLIPBOARD}")
+			text = text[len("%clipboard"):]
+		default:
+			if macro := textExpanderMacro.FindString(text); macro != "" {
+				r.Change(item, fmt.Sprintf("TextExpander macro %s is not supported; left as text", macro))
+				b.WriteString(escapeBraces(macro))
+				text = text[len(macro):]
+				continue
+			}
+			layout := textExpanderDate.FindString(text)
+			if layout == "" {
+				// Date arithmetic such as %@+1D, and a % that is just a %.
+				if strings.IndexByte(`@<>\^`, text[1]) >= 0 {
+					r.Change(item, fmt.Sprintf("TextExpander macro %s is not supported; left as text", text[:2]))
+				}
+				b.WriteByte('%')
+				text = text[1:]
+				continue
+			}
+			variable, exact := dateVariable(layout, textExpanderDateCodes)
+			if !exact {
+				r.Change(item, fmt.Sprintf("date format %q became %s", layout, variable))
+			}
+			b.WriteString(variable)
+			text = text[len(layout):]
+		}
+	}
+}
+
+// alfredSnippet is one snippet in an Alfred collection.
+type alfredSnippet struct {
+	Snippet struct {
+		Name           string `json:"name"`
+		Keyword        string `json:"keyword"`
+		Snippet        string `json:"snippet"`
+		DontAutoExpand bool   `json:"dontautoexpand"`
+	} `json:"alfredsnippet"`
+}
+
+// alfredPlaceholder matches Alfred's {name} and {name:argument} dynamic
+// placeholders.
+var alfredPlaceholder = regexp.MustCompile(`\{(clipboard|cursor|date|time|datetime|random|snippet|isodate|var)(?:[:| ][^}]*)?\}`)
+
+// importAlfred converts an Alfred .alfredsnippets collection, a zip of one
+// JSON file per snippet plus an info.plist holding the keyword prefix and
+// suffix, or a single snippet's JSON file.
+func importAlfred(name string, data []byte, r *Report) ([]config.Expansion, error) {
+	var (
+		files          [][]byte
+		prefix, suffix string
+	)
+	if zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		sort.Slice(zr.File, func(i, j int) bool { return zr.File[i].Name < zr.File[j].Name })
+		for _, zf := range zr.File {
+			base := path.Base(zf.Name)
+			if base != "info.plist" && path.Ext(base) != ".json" {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, err
+			}
+			b, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			if base == "info.plist" {
+				prefix, suffix = plistString(b, "snippetkeywordprefix"), plistString(b, "snippetkeywordsuffix")
+				continue
+			}
+			files = append(files, b)
+		}
+	} else {
+		files = [][]byte{data}
+	}
+
+	var exps []config.Expansion
+	for _, b := range files {
+		var s alfredSnippet
+		if err := json.Unmarshal(b, &s); err != nil {
+			return nil, err
+		}
+		sn := s.Snippet
+		if sn.Keyword == "" {
+			r.Skip(sn.Name, "has no keyword")
+			continue
+		}
+		trigger := prefix + sn.Keyword + suffix
+		exps = append(exps, config.Expansion{
+			Trigger:       trigger,
+			Replacement:   translateAlfred(sn.Snippet, trigger, r),
+			CaseSensitive: true,
+			Description:   sn.Name,
+		})
+		if !sn.DontAutoExpand {
+			r.Change(trigger, reasonImmediate)
+		}
+	}
+	return exps, nil
+}
+
+// translateAlfred upper-cases the placeholders that match template
+// variables and reports the rest.
+func translateAlfred(text, item string, r *Report) string {
+	return translateMatches(text, alfredPlaceholder, func(ph string) string {
+		switch strings.ToLower(ph) {
+		case "{clipboard}", "{cursor}", "{date}", "{time}", "{datetime}":
+			return strings.ToUpper(ph)
+		}
+		r.Change(item, fmt.Sprintf("Alfred placeholder %s is not supported; left as text", ph))
+		return escapeBraces(ph)
+	})
+}
+
+// plistString returns the string value of key in an XML property list.
+func plistString(plist []byte, key string) string {
+	re := regexp.MustCompile(`<key>` + regexp.QuoteMeta(key) + `</key>\s*<string>([^<]*)</string>`)
+	m := re.FindSubmatch(plist)
+	if m == nil {
+		return ""
+	}
+	return html.UnescapeString(string(m[1]))
+}
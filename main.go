@@ -1,12 +1,16 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -14,6 +18,7 @@ import (
 	"github.com/getlantern/systray"
 	"github.com/go-vgo/robotgo"
 
+	"text-expander/cli"
 	"text-expander/config"
 	"text-expander/expander"
 	"text-expander/gui"
@@ -26,6 +31,10 @@ const version = "0.1.0"
 var fyneApp fyne.App
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	if err := os.MkdirAll("config", 0o755); err != nil {
 		log.Fatalf("failed to create config directory: %v", err)
 	}
@@ -35,9 +44,23 @@ func main() {
 
 	cfgPath := defaultConfigPath()
 	cfg, err := config.LoadConfig(cfgPath)
-	if err != nil {
+	var pe *config.ParseError
+	var ve *config.ValidationError
+	switch {
+	case errors.As(err, &pe) || errors.As(err, &ve):
+		// The file needs fixing by hand. Run with expansion off so the tray
+		// can point to the problem, and pick the file up once it is fixed.
+		log.Printf("failed to load config: %v", err)
+		cfg = config.NewUnloadedConfig(cfgPath)
+	case err != nil:
+		// Without a tray icon yet, a notification is the only place the
+		// user will see why nothing started.
+		gui.ShowNotification("Configuration Error", err.Error())
 		log.Fatalf("failed to load config: %v", err)
 	}
+	for _, w := range cfg.Warnings() {
+		log.Printf("%s: warning: %s", cfgPath, w)
+	}
 
 	exp := expander.NewExpander(cfg)
 	logger := utils.NewLogger(defaultLogPath())
@@ -46,18 +69,21 @@ func main() {
 	// Initialize Fyne app before systray
 	fyneApp = app.NewWithID("com.textexpander.manager")
 
-	systray.Run(func() { onReady(exp, cfg, logger) }, func() { onExit(exp, logger) })
+	systray.Run(func() { onReady(exp, cfg, logger, ve != nil) }, func() { onExit(exp, logger) })
 }
 
 func defaultConfigPath() string {
-	return filepath.Join("config", "expansions.json")
+	return config.DefaultPath("config")
 }
 
 func defaultLogPath() string {
 	return filepath.Join("logs", "expander.log")
 }
 
-func onReady(exp *expander.Expander, cfg *config.Config, logger *utils.Logger) {
+// onReady sets up the tray. When the configuration file could not be loaded
+// at startup, its error is shown as for a failed reload, and openEditor opens
+// the configuration window to repair invalid fields.
+func onReady(exp *expander.Expander, cfg *config.Config, logger *utils.Logger, openEditor bool) {
 	// Load custom icon if available
 	loadIcon()
 
@@ -66,16 +92,46 @@ func onReady(exp *expander.Expander, cfg *config.Config, logger *utils.Logger) {
 
 	// Set up notification callback
 	exp.SetNotificationCallback(gui.ShowExpansionNotification)
+	exp.SetAbortCallback(gui.ShowExpansionAbortedNotification)
+
+	// Inline suggestions are drawn by the GUI executable, on platforms where
+	// it can show them without taking the focus from the text being typed.
+	if gui.OverlaySupported() {
+		overlayCh := make(chan []expander.Expansion, 16)
+		go runSuggestionOverlay(cfg, overlayCh)
+		exp.SetSuggestionCallback(func(partial string, exps []expander.Expansion) {
+			select {
+			case overlayCh <- exps:
+			default:
+			}
+		})
+	}
+
+	// Conflicting expansion hotkeys and pack triggers are skipped; say so
+	// once at startup.
+	if conflicts := cfg.HotkeyConflicts(); len(conflicts) > 0 {
+		go gui.ShowNotification("Hotkey Conflicts", conflictSummary(conflicts))
+	}
+	if conflicts := cfg.PackConflicts(); len(conflicts) > 0 {
+		go gui.ShowNotification("Snippet Pack Conflicts", conflictSummary(conflicts))
+	}
 
 	// Check for first run and show welcome dialog
 	go checkFirstRun()
 
-	if err := exp.Start(); err != nil {
+	if err := exp.Start(); errors.Is(err, expander.ErrInputFallback) {
+		log.Printf("%v", err)
+		go gui.ShowNotification("Input Backend Unavailable", err.Error())
+	} else if err != nil {
 		log.Printf("failed to start keyboard hook: %v", err)
 	} else {
 		log.Printf("keyboard hook started successfully")
 	}
 
+	// configErrorItem is shown while the configuration file on disk has an
+	// error, and opens the file to fix it.
+	configErrorItem := systray.AddMenuItem("", "Open the configuration file")
+	configErrorItem.Hide()
 	toggleItem := systray.AddMenuItem("Disable", "Enable or disable expansions")
 	configureItem := systray.AddMenuItem("Configure...", "Open configuration editor")
 	statsItem := systray.AddMenuItem("Statistics", "Show expansion statistics")
@@ -86,48 +142,85 @@ func onReady(exp *expander.Expander, cfg *config.Config, logger *utils.Logger) {
 	systray.AddSeparator()
 	quitItem := systray.AddMenuItem("Quit", "Quit the application")
 
-	updateTrayTooltip(cfg)
+	updateTrayTooltip(cfg, exp)
 	updateToggleTitle(cfg, toggleItem)
 
+	// Global hotkeys arrive on the hook goroutine; hand them to the tray loop
+	// so they share the menu's code paths.
+	hotkeyCh := make(chan string, 4)
+	pauseEndCh := make(chan struct{}, 1)
+	exp.SetHotkeyHandler(func(action string) {
+		select {
+		case hotkeyCh <- action:
+		default:
+		}
+	})
+	// Reload failures arrive on the watcher goroutine.
+	configErrCh := make(chan error, 4)
+	exp.SetConfigErrorCallback(func(err error) {
+		configErrCh <- err
+	})
+	if !cfg.Loaded() {
+		// Reloading reports the error, or loads the file if it has been
+		// fixed in the meantime.
+		exp.ReloadConfig()
+		if openEditor {
+			go openConfigWindow()
+		}
+	}
+
 	go func() {
 		for {
 			select {
 			case <-toggleItem.ClickedCh:
-				toggleEnabled(cfg, toggleItem)
-				updateTrayTooltip(cfg)
+				toggleEnabled(cfg, exp, toggleItem)
+				updateTrayTooltip(cfg, exp)
 			case <-configureItem.ClickedCh:
-				// Launch GUI as separate process
-				go func() {
-					defer func() {
-						if r := recover(); r != nil {
-							log.Printf("Error launching GUI: %v", r)
+				go openConfigWindow()
+			case action := <-hotkeyCh:
+				switch action {
+				case config.HotkeyToggle:
+					toggleEnabled(cfg, exp, toggleItem)
+				case config.HotkeyPause:
+					minutes := cfg.GetSettings().Hotkeys.PauseDuration()
+					exp.Pause(time.Duration(minutes) * time.Minute)
+					time.AfterFunc(time.Duration(minutes)*time.Minute, func() {
+						select {
+						case pauseEndCh <- struct{}{}:
+						default:
 						}
-					}()
-
-					// Get executable directory
-					exePath, err := os.Executable()
-					if err != nil {
-						log.Printf("Failed to get executable path: %v", err)
-						robotgo.Alert("Error", "Failed to open configuration window")
-						return
-					}
-					exeDir := filepath.Dir(exePath)
-					guiPath := filepath.Join(exeDir, "gui-config.exe")
-
-					// Launch the separate GUI executable
-					cmd := exec.Command(guiPath)
-					cmd.Dir = exeDir // Set working directory
-					if err := cmd.Start(); err != nil {
-						log.Printf("Failed to launch GUI: %v", err)
-						robotgo.Alert("Error", fmt.Sprintf("Failed to open configuration window: %v", err))
-					}
-				}()
+					})
+				case config.HotkeyOpenManager:
+					go openConfigWindow()
+				case config.HotkeyReload:
+					exp.ReloadConfig()
+					updateToggleTitle(cfg, toggleItem)
+				case config.HotkeyPalette:
+					go openPalette(exp)
+				}
+				updateTrayTooltip(cfg, exp)
+			case <-pauseEndCh:
+				updateTrayTooltip(cfg, exp)
+			case err := <-configErrCh:
+				if err != nil {
+					configErrorItem.SetTitle(configErrorTitle(err))
+					configErrorItem.Show()
+					go gui.ShowConfigErrorNotification(err, cfg.Loaded())
+				} else {
+					configErrorItem.Hide()
+				}
+				// A file fixed after a failed start brings its settings.
+				updateToggleTitle(cfg, toggleItem)
+				updateTrayTooltip(cfg, exp)
+			case <-configErrorItem.ClickedCh:
+				openFile(defaultConfigPath())
 			case <-statsItem.ClickedCh:
 				showStats(logger)
 			case <-viewLogsItem.ClickedCh:
-				openLogFile(defaultLogPath())
+				openFile(defaultLogPath())
 			case <-reloadItem.ClickedCh:
 				exp.ReloadConfig()
+				updateTrayTooltip(cfg, exp)
 			case <-aboutItem.ClickedCh:
 				showAbout()
 			case <-quitItem.ClickedCh:
@@ -139,17 +232,150 @@ func onReady(exp *expander.Expander, cfg *config.Config, logger *utils.Logger) {
 }
 
 func onExit(exp *expander.Expander, logger *utils.Logger) {
-	exp.Stop()
+	exp.Close()
 	if logger != nil {
 		logger.Close()
 	}
 }
 
-func toggleEnabled(cfg *config.Config, item *systray.MenuItem) {
+// guiCommand returns a command running the separate GUI executable, which
+// lives next to this one, with the given arguments.
+func guiCommand(args ...string) (*exec.Cmd, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("get executable path: %w", err)
+	}
+	exeDir := filepath.Dir(exePath)
+	guiPath := filepath.Join(exeDir, "gui-config.exe")
+
+	cmd := exec.Command(guiPath, args...)
+	cmd.Dir = exeDir // Set working directory
+	return cmd, nil
+}
+
+// openConfigWindow launches the configuration GUI as a separate process.
+func openConfigWindow() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error launching GUI: %v", r)
+		}
+	}()
+
+	cmd, err := guiCommand()
+	if err != nil {
+		log.Printf("Failed to launch GUI: %v", err)
+		robotgo.Alert("Error", "Failed to open configuration window")
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		log.Printf("Failed to launch GUI: %v", err)
+		robotgo.Alert("Error", fmt.Sprintf("Failed to open configuration window: %v", err))
+	}
+}
+
+// openPalette shows the snippet palette and inserts the chosen expansion
+// into the window that was focused when it was opened.
+func openPalette(exp *expander.Expander) {
+	pid := robotgo.GetPid()
+
+	cmd, err := guiCommand("-palette")
+	if err != nil {
+		log.Printf("Failed to launch palette: %v", err)
+		return
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		log.Printf("Palette failed: %v", err)
+		return
+	}
+	name := strings.TrimSpace(string(out))
+	if name == "" {
+		return
+	}
+
+	// Closing the palette usually gives focus back, but not on every
+	// platform; make sure before typing.
+	if err := robotgo.ActivePid(pid); err != nil {
+		log.Printf("Failed to refocus window: %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	if err := exp.InsertExpansion(name); err != nil {
+		log.Printf("Palette: %v", err)
+	}
+}
+
+// suggestionUpdate describes exps for the suggestion overlay, placed below
+// the caret or, when the focused application does not report it, the mouse
+// pointer.
+func suggestionUpdate(cfg *config.Config, exps []expander.Expansion) gui.OverlayUpdate {
+	u := gui.OverlayUpdate{Accept: cfg.GetSettings().Hotkeys.AcceptSuggestion}
+	if len(exps) == 0 {
+		return u
+	}
+	for _, e := range exps {
+		u.Items = append(u.Items, gui.SuggestionItem{Trigger: e.Trigger, Description: e.Description})
+	}
+	x, y, ok := gui.CaretPosition()
+	if !ok {
+		x, y = robotgo.Location()
+		y += 20
+	}
+	u.X, u.Y = x, y
+	return u
+}
+
+// runSuggestionOverlay sends suggestions to the overlay process, starting it
+// on the first suggestion and again if it exits. Only the latest of several
+// queued updates is sent. The caret is looked up here rather than on the
+// expander's worker, which must not wait for other applications.
+func runSuggestionOverlay(cfg *config.Config, updates <-chan []expander.Expansion) {
+	var stdin io.WriteCloser
+	var enc *json.Encoder
+	for exps := range updates {
+	drain:
+		for {
+			select {
+			case exps = <-updates:
+			default:
+				break drain
+			}
+		}
+
+		if enc == nil && len(exps) == 0 {
+			continue
+		}
+		u := suggestionUpdate(cfg, exps)
+		if enc == nil {
+			cmd, err := guiCommand("-overlay")
+			if err == nil {
+				stdin, err = cmd.StdinPipe()
+			}
+			if err == nil {
+				err = cmd.Start()
+			}
+			if err != nil {
+				log.Printf("Failed to start suggestion overlay: %v", err)
+				continue
+			}
+			go func() { _ = cmd.Wait() }()
+			enc = json.NewEncoder(stdin)
+		}
+		if err := enc.Encode(u); err != nil {
+			log.Printf("Suggestion overlay stopped: %v", err)
+			_ = stdin.Close()
+			enc = nil
+		}
+	}
+}
+
+func toggleEnabled(cfg *config.Config, exp *expander.Expander, item *systray.MenuItem) {
 	s := cfg.GetSettings()
 	s.Enabled = !s.Enabled
 	cfg.UpdateSettings(s)
 	_ = cfg.Save()
+	// Toggling always ends a pause so the result matches the menu title.
+	exp.Resume()
 	updateToggleTitle(cfg, item)
 }
 
@@ -162,9 +388,15 @@ func updateToggleTitle(cfg *config.Config, item *systray.MenuItem) {
 	}
 }
 
-func updateTrayTooltip(cfg *config.Config) {
+func updateTrayTooltip(cfg *config.Config, exp *expander.Expander) {
 	s := cfg.GetSettings()
-	if s.Enabled {
+	if exp.ConfigError() != nil && !cfg.Loaded() {
+		systray.SetTooltip("Text Expander (Configuration error, expansions off)")
+	} else if exp.ConfigError() != nil {
+		systray.SetTooltip("Text Expander (Configuration error, using the last working configuration)")
+	} else if until := exp.PausedUntil(); s.Enabled && !until.IsZero() {
+		systray.SetTooltip("Text Expander (Paused until " + until.Format("15:04") + ")")
+	} else if s.Enabled {
 		systray.SetTooltip("Text Expander (Enabled)")
 	} else {
 		systray.SetTooltip("Text Expander (Disabled)")
@@ -193,7 +425,33 @@ func formatTime(t time.Time) string {
 	return t.Format("2006-01-02 15:04:05")
 }
 
-func openLogFile(path string) {
+// conflictSummary describes conflicts for a notification, pointing to the
+// log for the rest when there is more than one.
+func conflictSummary(conflicts []error) string {
+	if len(conflicts) == 1 {
+		return conflicts[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more, see the log)", conflicts[0], len(conflicts)-1)
+}
+
+// configErrorTitle shortens a configuration error to fit a menu item.
+func configErrorTitle(err error) string {
+	msg := err.Error()
+	var pe *config.ParseError
+	var ve *config.ValidationError
+	switch {
+	case errors.As(err, &pe) && pe.Line > 0:
+		msg = fmt.Sprintf("line %d: %v", pe.Line, pe.Err)
+	case errors.As(err, &ve):
+		msg = ve.Problems[0].String()
+	}
+	if r := []rune(msg); len(r) > 60 {
+		msg = string(r[:57]) + "..."
+	}
+	return "Configuration error: " + msg
+}
+
+func openFile(path string) {
 	if _, err := os.Stat(path); err != nil {
 		return
 	}
@@ -1,20 +1,26 @@
 package utils
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
 // Logger handles expansion logging and usage statistics.
 type Logger struct {
-	file   *os.File
-	path   string
-	mu     sync.Mutex
-	stats  Statistics
-	counts map[string]int
+	file  *os.File
+	path  string
+	mu    sync.Mutex
+	stats Statistics
+	// counts are keyed by expansion ID, and names holds the latest name
+	// logged for each, so a renamed expansion keeps its count.
+	counts   map[string]int
+	names    map[string]string
+	mostUsed string
 }
 
 // Statistics summarises expansion usage.
@@ -47,6 +53,7 @@ func NewLogger(path string) *Logger {
 		file:   f,
 		path:   path,
 		counts: make(map[string]int),
+		names:  make(map[string]string),
 	}
 }
 
@@ -66,7 +73,9 @@ func (l *Logger) Close() {
 }
 
 // LogExpansion records an expansion event, without logging the expanded text.
-func (l *Logger) LogExpansion(trigger string) {
+// id is the expansion's ID and name its trigger or hotkey; usage is counted
+// by ID when there is one.
+func (l *Logger) LogExpansion(id, name string) {
 	if l == nil {
 		return
 	}
@@ -84,14 +93,24 @@ func (l *Logger) LogExpansion(trigger string) {
 	l.stats.TodayExpansions++
 	l.stats.LastExpansion = now
 
-	l.counts[trigger]++
-	if l.stats.MostUsedTrigger == "" || l.counts[trigger] > l.counts[l.stats.MostUsedTrigger] {
-		l.stats.MostUsedTrigger = trigger
+	key := id
+	if key == "" {
+		key = name
 	}
+	l.counts[key]++
+	l.names[key] = name
+	if l.mostUsed == "" || l.counts[key] > l.counts[l.mostUsed] {
+		l.mostUsed = key
+	}
+	l.stats.MostUsedTrigger = l.names[l.mostUsed]
 
 	if l.file != nil {
 		l.rotateIfNeeded()
-		fmt.Fprintf(l.file, "%s\ttrigger=%s\n", now.Format(time.RFC3339), trigger)
+		if id != "" {
+			fmt.Fprintf(l.file, "%s\ttrigger=%s\tid=%s\n", now.Format(time.RFC3339), name, id)
+		} else {
+			fmt.Fprintf(l.file, "%s\ttrigger=%s\n", now.Format(time.RFC3339), name)
+		}
 	}
 }
 
@@ -121,6 +140,79 @@ func (l *Logger) GetStats() Statistics {
 	return l.stats
 }
 
+// Usage describes how often and how recently a trigger was expanded.
+type Usage struct {
+	Count int
+	Last  time.Time
+}
+
+// ReadUsage parses the expansion log at path and returns usage per expansion
+// ID. Lines logged before expansions had IDs are counted by trigger instead;
+// UsageFor adds the two together. A missing log yields an empty map.
+func ReadUsage(path string) (map[string]Usage, error) {
+	usage := make(map[string]Usage)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return usage, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		stamp, rest, ok := strings.Cut(sc.Text(), "\t")
+		if !ok {
+			continue
+		}
+		if !strings.HasPrefix(rest, "trigger=") {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, stamp)
+		if err != nil {
+			continue
+		}
+
+		var trigger, id string
+		for _, field := range strings.Split(rest, "\t") {
+			if v, ok := strings.CutPrefix(field, "trigger="); ok {
+				trigger = v
+			} else if v, ok := strings.CutPrefix(field, "id="); ok {
+				id = v
+			}
+		}
+		key := id
+		if key == "" {
+			key = trigger
+		}
+
+		u := usage[key]
+		u.Count++
+		if t.After(u.Last) {
+			u.Last = t
+		}
+		usage[key] = u
+	}
+	return usage, sc.Err()
+}
+
+// UsageFor returns the usage ReadUsage found for the expansion with the
+// given ID and name, including what was logged under its name alone.
+func UsageFor(usage map[string]Usage, id, name string) Usage {
+	u := usage[name]
+	if id == "" || id == name {
+		return u
+	}
+	byID := usage[id]
+	u.Count += byID.Count
+	if byID.Last.After(u.Last) {
+		u.Last = byID.Last
+	}
+	return u
+}
+
 func (l *Logger) rotateIfNeeded() {
 	if l.file == nil {
 		return
@@ -153,4 +245,4 @@ func sameDay(a, b time.Time) bool {
 	ay, am, ad := a.Date()
 	by, bm, bd := b.Date()
 	return ay == by && am == bm && ad == bd
-}
\ No newline at end of file
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expander.log")
+	log := "2026-03-01T10:00:00Z\ttrigger=;sig\n" +
+		"2026-03-01T10:05:00Z\tERROR: expansion \";x\" aborted\n" +
+		"2026-03-02T09:00:00Z\ttrigger=;sig\n" +
+		"2026-03-01T11:00:00Z\ttrigger=;addr\n"
+	if err := os.WriteFile(path, []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := ReadUsage(path)
+	if err != nil {
+		t.Fatalf("ReadUsage: %v", err)
+	}
+	sig := usage[";sig"]
+	if sig.Count != 2 || sig.Last.Day() != 2 {
+		t.Fatalf("unexpected usage for ;sig: %+v", sig)
+	}
+	if usage[";addr"].Count != 1 || len(usage) != 2 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	if usage, err := ReadUsage(filepath.Join(t.TempDir(), "missing.log")); err != nil || len(usage) != 0 {
+		t.Fatalf("expected empty usage for a missing log, got %v, %v", usage, err)
+	}
+}
+
+func TestUsageFollowsIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expander.log")
+	l := NewLogger(path)
+	if l == nil {
+		t.Fatal("NewLogger returned nil")
+	}
+	l.LogExpansion("a1", ";addr")
+	l.LogExpansion("b2", ";sig")
+	// ;addr renamed to ;home keeps its count.
+	l.LogExpansion("a1", ";home")
+	if got := l.GetStats().MostUsedTrigger; got != ";home" {
+		t.Errorf("most used = %q, want ;home", got)
+	}
+	l.Close()
+
+	// An older line logged by trigger only.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("2026-03-01T10:00:00Z\ttrigger=;addr\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	usage, err := ReadUsage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := usage["a1"]; u.Count != 2 {
+		t.Errorf("expected 2 uses of a1 across the rename, got %+v", u)
+	}
+	if u := UsageFor(usage, "a1", ";addr"); u.Count != 3 || u.Last.Year() < 2026 {
+		t.Errorf("expected the older line added in, got %+v", u)
+	}
+	if u := UsageFor(usage, "b2", ";sig"); u.Count != 1 {
+		t.Errorf("unexpected usage for b2: %+v", u)
+	}
+}